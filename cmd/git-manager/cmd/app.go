@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// App holds everything a command reads from or writes to the outside world.
+// Commands never touch os.Stdout, os.Getwd or os.Getenv directly, so the
+// whole command tree can be driven in-process from tests.
+type App struct {
+	// Stdin, Stdout and Stderr are the command's standard streams
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Dir is the directory the command runs in
+	Dir string
+
+	// Env is the environment in os.Environ form. It is also passed to every
+	// git subprocess.
+	Env []string
+
+	// Now returns the current time
+	Now func() time.Time
+
+	// Git runs git. A nil Git runs the git binary with Env.
+	Git git.GitRunner
+
+	// Trace, when set, receives a log entry for every git invocation. It is
+	// set from --verbose or GIT_MANAGER_TRACE before a command runs.
+	Trace io.Writer
+
+	// Timeout, when set, limits how long each git invocation may run. It is
+	// set from --timeout and overrides the timeouts in the config file.
+	Timeout time.Duration
+
+	// m is the Manager built by manager on first use
+	m *gitmanager.Manager
+}
+
+// NewApp returns an App wired to the current process.
+func NewApp() (*App, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine your current directory. Please ensure you have permissions to access this directory and try again: %w", err)
+	}
+
+	return &App{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Dir:    dir,
+		Env:    os.Environ(),
+		Now:    time.Now,
+	}, nil
+}
+
+// Getenv returns the value of the environment variable key, or "" if unset.
+// Later entries win, matching how exec treats duplicate keys.
+func (a *App) Getenv(key string) string {
+	value := ""
+	for _, kv := range a.Env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == key {
+			value = v
+		}
+	}
+	return value
+}
+
+// runner returns the git runner commands use, with tracing applied
+func (a *App) runner() git.GitRunner {
+	r := a.Git
+	if r == nil {
+		r = &git.ExecRunner{Env: a.Env, ProcessGroup: !a.interactive()}
+	}
+	if a.Trace != nil {
+		r = git.WithTrace(r, a.Trace)
+	}
+	return r
+}
+
+// interactive reports whether stdin is a terminal, so git may prompt on it
+func (a *App) interactive() bool {
+	return isTerminal(a.Stdin)
+}
+
+// liveOutput reports whether stdout is a terminal that output can be redrawn
+// on, for live progress views
+func (a *App) liveOutput() bool {
+	return isTerminal(a.Stdout) && a.Getenv("TERM") != "dumb"
+}
+
+// isTerminal reports whether f is a terminal
+func isTerminal(f any) bool {
+	file, ok := f.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// manager returns the git-manager API the commands are built on. Progress
+// and git's output go to the app's output streams. It is built on first use,
+// after the root command has applied --verbose and --timeout, and shared by
+// every later call.
+func (a *App) manager() *gitmanager.Manager {
+	if a.m == nil {
+		a.m = gitmanager.New(gitmanager.Options{
+			Git:         a.runner(),
+			Env:         a.Env,
+			Progress:    a.Stdout,
+			ProgressErr: a.Stderr,
+			Timeout:     a.Timeout,
+		})
+	}
+	return a.m
+}
+
+// workspace resolves the workspace the app's directory is in
+func (a *App) workspace(ctx context.Context) (*gitmanager.Workspace, error) {
+	return a.manager().Resolve(ctx, gitmanager.Target{Dir: a.Dir})
+}
+
+// registeredWorkspaces resolves every registered repository. Repositories
+// that cannot be resolved are skipped with a warning, so commands that work
+// on all of them still handle the rest.
+func (a *App) registeredWorkspaces(ctx context.Context, m *gitmanager.Manager) ([]*gitmanager.Workspace, error) {
+	repos, err := m.Repositories()
+	if err != nil {
+		return nil, err
+	}
+	workspaces := make([]*gitmanager.Workspace, 0, len(repos))
+	for _, repo := range repos {
+		ws, err := m.Resolve(ctx, gitmanager.Target{Repo: repo.Name})
+		if err != nil {
+			fmt.Fprintf(a.Stderr, "warning: skipping %s: %v\n", repo.Name, err)
+			continue
+		}
+		workspaces = append(workspaces, ws)
+	}
+	return workspaces, nil
+}
+
+// setupTrace enables tracing of git invocations when verbose is set or
+// GIT_MANAGER_TRACE asks for it. Like GIT_TRACE, GIT_MANAGER_TRACE may be
+// "1", "2" or "true" to trace to stderr, or an absolute path to append the
+// trace to a file.
+func (a *App) setupTrace(verbose bool) {
+	switch value := a.Getenv("GIT_MANAGER_TRACE"); {
+	case verbose:
+		a.Trace = a.Stderr
+	case value == "" || value == "0" || strings.EqualFold(value, "false"):
+		a.Trace = nil
+	case filepath.IsAbs(value):
+		a.Trace = appendFile(value)
+	default:
+		a.Trace = a.Stderr
+	}
+}
+
+// appendFile is a writer that appends each write to a file, opening and
+// closing it every time so nothing is left open between commands
+type appendFile string
+
+// Write implements io.Writer
+func (f appendFile) Write(p []byte) (int, error) {
+	file, err := os.OpenFile(string(f), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := file.Write(p)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/internal/plan"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newCarryCmd returns the carry command
+func newCarryCmd(app *App) *cobra.Command {
+	var opts gitmanager.CarryOptions
+
+	carryCmd := &cobra.Command{
+		Use:   "carry <target>",
+		Short: "Move uncommitted changes to another worktree",
+		Long: `Move the uncommitted changes of the current worktree, staged and unstaged,
+to another worktree, named by directory or branch. --untracked also moves
+untracked files and --paths limits the move to some files.
+
+The changes are applied in the target with a 3-way merge, so the target may
+be on another branch. They are removed from the current worktree only when
+they apply cleanly; on a conflict nothing changes and the exit code is the
+conflict exit code (7). Staged changes arrive unstaged.
+
+--from-stash moves stash entry n instead, and drops it once it is applied.`,
+		Args:              usageArgs(cobra.ExactArgs(1)),
+		PersistentPreRunE: requireRepository(app),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Target = args[0]
+			opts.Source = app.Dir
+			if opts.FromStash = cmd.Flags().Changed("from-stash"); opts.FromStash && (opts.Untracked || len(opts.Paths) > 0) {
+				return errs.New(errs.Usage, "--from-stash cannot be used with --untracked or --paths")
+			}
+			return carryChanges(cmd.Context(), app, opts)
+		},
+	}
+
+	carryCmd.Flags().StringSliceVar(&opts.Paths, "paths", nil, "Only move changes to these paths, comma-separated")
+	carryCmd.Flags().BoolVarP(&opts.Untracked, "untracked", "u", false, "Also move untracked files")
+	carryCmd.Flags().IntVar(&opts.Stash, "from-stash", 0, "Move the changes of stash entry n instead")
+	carryCmd.Flags().BoolVarP(&opts.DryRun, "dry-run", "n", false, "Print the git commands that would move the changes, without changing anything")
+
+	return carryCmd
+}
+
+func carryChanges(ctx context.Context, app *App, opts gitmanager.CarryOptions) error {
+	ws, err := app.workspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := app.manager().Carry(ctx, ws, opts)
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		plan.PrintSteps(app.Stdout, res.Plan)
+		return nil
+	}
+
+	fmt.Fprintf(app.Stdout, "Carried changes to %s to %s\n", plural(len(res.Files), "file"), res.Target)
+	for _, f := range res.Files {
+		fmt.Fprintf(app.Stdout, "  %s\n", f)
+	}
+	return nil
+}
@@ -0,0 +1,817 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/internal/git"
+	"github.com/ingshtrom/git-manager/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	// Never let tests touch the real user configuration
+	dir, err := os.MkdirTemp("", "git-manager-config-*")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("GIT_MANAGER_CONFIG", filepath.Join(dir, "config.json"))
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// isolateConfig gives the test its own empty configuration file
+func isolateConfig(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("GIT_MANAGER_CONFIG", path)
+	return path
+}
+
+// runCommand runs git-manager in-process in dir and returns what it wrote to
+// stdout and stderr
+func runCommand(t *testing.T, dir string, args ...string) (string, string, error) {
+	t.Helper()
+	return runCommandWithGit(t, nil, dir, args...)
+}
+
+// runCommandWithGit is runCommand with git invocations going to r
+func runCommandWithGit(t *testing.T, r git.GitRunner, dir string, args ...string) (string, string, error) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	app := &App{
+		Stdin:  strings.NewReader(""),
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Dir:    dir,
+		Env:    os.Environ(),
+		Now:    func() time.Time { return time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC) },
+		Git:    r,
+	}
+
+	root := NewRootCmd(app)
+	root.SetArgs(args)
+	err := root.Execute()
+
+	return stdout.String(), stderr.String(), err
+}
+
+// TestAddEmitsShellDirective tests that add creates the worktree and tells
+// the shell wrapper to cd into it
+func TestAddEmitsShellDirective(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	stdout, _, err := runCommand(t, ws.Worktree("main").Path, "add", "feature")
+	if err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+
+	worktreePath := filepath.Join(ws.Root, "feature")
+	if _, err := os.Stat(worktreePath); err != nil {
+		t.Fatalf("Expected worktree at %s: %v", worktreePath, err)
+	}
+
+	directive := `git-manager-eval:cd "` + worktreePath + `"`
+	if !strings.Contains(stdout, directive+"\n") {
+		t.Errorf("Expected output to contain %q, got:\n%s", directive, stdout)
+	}
+
+	// Adding it again conflicts with the existing directory
+	_, _, err = runCommand(t, ws.Worktree("main").Path, "worktree", "add", "feature")
+	if !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected a conflict error, got %v", err)
+	}
+}
+
+// TestAddWithoutSwitch tests that --switch=false suppresses the directive
+func TestAddWithoutSwitch(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	stdout, _, err := runCommand(t, ws.Worktree("main").Path, "add", "--switch=false", "feature")
+	if err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if strings.Contains(stdout, "git-manager-eval:") {
+		t.Errorf("Expected no shell directive, got:\n%s", stdout)
+	}
+}
+
+// TestSwitch tests switching by directory name and the not-found error
+func TestSwitch(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	feature := ws.AddWorktree(t, "feature")
+
+	stdout, _, err := runCommand(t, ws.Worktree("main").Path, "worktree", "switch", "feature")
+	if err != nil {
+		t.Fatalf("switch failed: %v", err)
+	}
+	directive := `git-manager-eval:cd "` + feature.Path + `"`
+	if !strings.Contains(stdout, directive+"\n") {
+		t.Errorf("Expected output to contain %q, got:\n%s", directive, stdout)
+	}
+
+	_, _, err = runCommand(t, ws.Worktree("main").Path, "worktree", "switch", "missing")
+	if errs.ExitCode(err) != errs.ExitNotFound {
+		t.Errorf("Expected exit code %d, got %d (%v)", errs.ExitNotFound, errs.ExitCode(err), err)
+	}
+}
+
+// TestRemove tests that dirty worktrees are only removed with --force
+func TestRemove(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	feature := ws.AddWorktree(t, "feature")
+	feature.CreateFile(t, "wip.txt", "not committed\n")
+
+	_, _, err := runCommand(t, ws.Worktree("main").Path, "worktree", "remove", "feature")
+	if errs.ExitCode(err) != errs.ExitDirty {
+		t.Fatalf("Expected exit code %d, got %d (%v)", errs.ExitDirty, errs.ExitCode(err), err)
+	}
+	if _, err := os.Stat(feature.Path); err != nil {
+		t.Fatalf("Expected dirty worktree to be kept: %v", err)
+	}
+
+	if _, _, err := runCommand(t, ws.Worktree("main").Path, "worktree", "remove", "--force", "--delete-branch", "feature"); err != nil {
+		t.Fatalf("remove --force failed: %v", err)
+	}
+	if _, err := os.Stat(feature.Path); !os.IsNotExist(err) {
+		t.Errorf("Expected worktree directory to be removed, got %v", err)
+	}
+}
+
+// TestList tests listing the worktrees of a workspace
+func TestList(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	ws.AddWorktree(t, "feature")
+
+	stdout, _, err := runCommand(t, ws.Root, "list")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	for _, want := range []string{"PATH", filepath.Join(ws.Root, "main"), filepath.Join(ws.Root, "feature")} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected list output to contain %q, got:\n%s", want, stdout)
+		}
+	}
+}
+
+// TestInit tests creating a workspace from a repository URL
+func TestInit(t *testing.T) {
+	isolateConfig(t)
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	if _, _, err := runCommand(t, dir, "repository", "init", ws.Origin.Path); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "origin", ".git", "HEAD")); err != nil {
+		t.Errorf("Expected a bare repository: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "origin", "main", "README.md")); err != nil {
+		t.Errorf("Expected a main worktree: %v", err)
+	}
+
+	_, _, err := runCommand(t, dir, "repository", "init", ws.Origin.Path)
+	if !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected a conflict error, got %v", err)
+	}
+}
+
+// TestErrorKinds tests the kinds returned for common mistakes
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want errs.Kind
+	}{
+		{"unknown command", []string{"bogus"}, errs.Usage},
+		{"unknown flag", []string{"list", "--bogus"}, errs.Usage},
+		{"missing argument", []string{"tool", "shell"}, errs.Usage},
+		{"unsupported shell", []string{"tool", "shell", "tcsh"}, errs.Usage},
+		{"not in a repository", []string{"worktree", "ls"}, errs.NotInRepo},
+		{"hoisted list outside a repository", []string{"list"}, errs.NotInRepo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := runCommand(t, t.TempDir(), tt.args...)
+			if !errs.Is(err, tt.want) {
+				t.Errorf("Expected %v error, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// TestShellIntegration tests that the shell script goes to the app's stdout
+func TestShellIntegration(t *testing.T) {
+	stdout, _, err := runCommand(t, t.TempDir(), "tool", "shell", "bash")
+	if err != nil {
+		t.Fatalf("tool shell failed: %v", err)
+	}
+	if !strings.Contains(stdout, "git-manager-eval:") {
+		t.Errorf("Expected the bash wrapper to handle git-manager-eval lines, got:\n%s", stdout)
+	}
+}
+
+// TestAddIndexLocked tests that a locked index is reported as a git failure
+// with git's explanation
+func TestAddIndexLocked(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	fake := testutil.NewFakeRunner(t)
+	fake.Fallback = &git.ExecRunner{}
+	fake.On("worktree", "add").IndexLocked(ws.GitDir)
+
+	_, _, err := runCommandWithGit(t, fake, ws.Root, "add", "feature")
+	if errs.ExitCode(err) != errs.ExitGitFailure {
+		t.Fatalf("Expected exit code %d, got %d (%v)", errs.ExitGitFailure, errs.ExitCode(err), err)
+	}
+	if !strings.Contains(err.Error(), "index.lock': File exists") {
+		t.Errorf("Expected the lock message in the error, got %q", err.Error())
+	}
+}
+
+// TestStatus tests the per-worktree state table
+func TestStatus(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	feature := ws.AddWorktree(t, "feature")
+	feature.CreateFile(t, "wip.txt", "not committed\n")
+
+	stdout, _, err := runCommand(t, ws.Root, "status")
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	for _, want := range []string{"STATE", "clean", "dirty (1 changed)"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected status output to contain %q, got:\n%s", want, stdout)
+		}
+	}
+
+	_, _, err = runCommand(t, t.TempDir(), "status")
+	if !errs.Is(err, errs.NotInRepo) {
+		t.Errorf("Expected a not-in-repo error, got %v", err)
+	}
+}
+
+// TestDoctorReportsCapabilities tests that tool doctor reports the git
+// version and what an old git is missing
+func TestDoctorReportsCapabilities(t *testing.T) {
+	stdout, _, err := runCommandWithGit(t, testutil.GitVersion(t, "2.40.1"), t.TempDir(), "tool", "doctor")
+	if err != nil {
+		t.Fatalf("tool doctor failed: %v", err)
+	}
+	for _, want := range []string{"git version 2.40.1", "merge-tree-write-tree  available", "requires git >= 2.42.0"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected doctor output to contain %q, got:\n%s", want, stdout)
+		}
+	}
+}
+
+// TestDoctorFix tests that tool doctor reports workspace problems and
+// repairs them with --fix, asking before removing directories
+func TestDoctorFix(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	stray := filepath.Join(ws.Root, "stray")
+	if err := os.Mkdir(stray, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, err := runCommand(t, ws.Root, "tool", "doctor")
+	if err != nil {
+		t.Fatalf("tool doctor failed: %v", err)
+	}
+	for _, want := range []string{"orphan-dir: " + stray, "missing-refspec", "fix: $ git -C " + ws.GitDir + " config remote.origin.fetch"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected doctor output to contain %q, got:\n%s", want, stdout)
+		}
+	}
+
+	// Without --yes and nobody to answer, the directory is kept
+	stdout, _, err = runCommand(t, ws.Root, "tool", "doctor", "--fix")
+	if err != nil {
+		t.Fatalf("tool doctor --fix failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Remove "+stray+"? [y/N]") || !strings.Contains(stdout, "skipped  $ rm -rf "+stray) {
+		t.Errorf("Expected the removal to be asked for and skipped, got:\n%s", stdout)
+	}
+	if _, err := os.Stat(stray); err != nil {
+		t.Errorf("Expected %s to be kept: %v", stray, err)
+	}
+	if refspec := ws.Worktree("main").RunGit(t, "config", "remote.origin.fetch"); !strings.Contains(refspec, "+refs/heads/*:refs/remotes/origin/*") {
+		t.Errorf("Expected the fetch refspec to be set, got %q", refspec)
+	}
+
+	stdout, _, err = runCommand(t, ws.Root, "tool", "doctor", "--fix", "--yes")
+	if err != nil {
+		t.Fatalf("tool doctor --fix --yes failed: %v", err)
+	}
+	if _, err := os.Stat(stray); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got %v\n%s", stray, err, stdout)
+	}
+
+	stdout, _, err = runCommand(t, ws.Root, "tool", "doctor")
+	if err != nil {
+		t.Fatalf("tool doctor failed: %v", err)
+	}
+	if !strings.Contains(stdout, "no problems found") {
+		t.Errorf("Expected no problems after the fixes, got:\n%s", stdout)
+	}
+}
+
+// TestRepositoryMove tests that repository move relocates the workspace and
+// takes the shell along when it was inside
+func TestRepositoryMove(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	dest := filepath.Join(ws.TempDir, "src", "api")
+	stdout, _, err := runCommand(t, filepath.Join(ws.Root, "main"), "repository", "move", dest)
+	if err != nil {
+		t.Fatalf("repository move failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Moved workspace "+ws.Root+" to "+dest) {
+		t.Errorf("Expected a move message, got:\n%s", stdout)
+	}
+	if want := fmt.Sprintf("git-manager-eval:cd %q", filepath.Join(dest, "main")); !strings.Contains(stdout, want) {
+		t.Errorf("Expected %q in the output, got:\n%s", want, stdout)
+	}
+
+	stdout, _, err = runCommand(t, filepath.Join(dest, "main"), "worktree", "list")
+	if err != nil {
+		t.Fatalf("worktree list failed after the move: %v", err)
+	}
+	if !strings.Contains(stdout, filepath.Join(dest, "main")) {
+		t.Errorf("Expected the moved worktree to be listed, got:\n%s", stdout)
+	}
+}
+
+// TestRepositoryAdoptScan tests adopting every clone under a directory
+func TestRepositoryAdoptScan(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	t.Setenv("GIT_MANAGER_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+
+	clones := filepath.Join(ws.TempDir, "clones")
+	for _, name := range []string{"api", "web"} {
+		if out, err := testutil.NewCommand("git", "clone", ws.Origin.Path, filepath.Join(clones, name)).CombinedOutput(); err != nil {
+			t.Fatalf("Failed to clone: %v\nOutput: %s", err, out)
+		}
+	}
+
+	stdout, stderr, err := runCommand(t, ws.TempDir, "repository", "adopt", "--scan", clones, "--dry-run")
+	if err != nil {
+		t.Fatalf("repository adopt --scan --dry-run failed: %v\n%s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Would adopt 2 of 2 clones") {
+		t.Errorf("Expected the dry run to say what it would adopt, got:\n%s", stdout)
+	}
+
+	stdout, stderr, err = runCommand(t, ws.TempDir, "repository", "adopt", "--scan", clones)
+	if err != nil {
+		t.Fatalf("repository adopt --scan failed: %v\n%s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Adopted 2 of 2 clones") {
+		t.Errorf("Expected both clones to be adopted, got:\n%s", stdout)
+	}
+
+	stdout, _, err = runCommand(t, ws.TempDir, "--repo", "web", "worktree", "list")
+	if err != nil {
+		t.Fatalf("worktree list failed: %v", err)
+	}
+	if !strings.Contains(stdout, filepath.Join(clones, "web", "main")) {
+		t.Errorf("Expected the adopted checkout to be listed, got:\n%s", stdout)
+	}
+}
+
+// TestRepositoryEject tests that eject refuses to drop uncommitted work and
+// then turns the workspace into a clone in place
+func TestRepositoryEject(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	feature := ws.AddWorktree(t, "feature")
+	feature.CreateFile(t, "wip.txt", "wip\n")
+
+	_, _, err := runCommand(t, filepath.Join(ws.Root, "main"), "repository", "eject")
+	if errs.ExitCode(err) != errs.ExitDirty {
+		t.Fatalf("Expected exit code %d for a dirty worktree, got %v", errs.ExitDirty, err)
+	}
+
+	stdout, _, err := runCommand(t, filepath.Join(ws.Root, "main"), "repository", "eject", "--keep", "main", "--stash")
+	if err != nil {
+		t.Fatalf("repository eject failed: %v", err)
+	}
+	if want := fmt.Sprintf("git-manager-eval:cd %q", ws.Root); !strings.Contains(stdout, want) {
+		t.Errorf("Expected %q in the output, got:\n%s", want, stdout)
+	}
+	clone := &testutil.GitRepo{Path: ws.Root}
+	clone.AssertFileContent(t, "README.md", "# Test Repository\n")
+	if stash := clone.RunGit(t, "stash", "list"); stash == "" {
+		t.Errorf("Expected the feature worktree's changes to be stashed")
+	}
+}
+
+// TestRepositoryImportGhq tests importing the checkouts of a ghq root
+func TestRepositoryImportGhq(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	t.Setenv("GIT_MANAGER_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+
+	root := filepath.Join(ws.TempDir, "ghq")
+	clone := filepath.Join(root, "github.com", "org", "api")
+	if out, err := testutil.NewCommand("git", "clone", ws.Origin.Path, clone).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to clone: %v\nOutput: %s", err, out)
+	}
+
+	stdout, _, err := runCommand(t, ws.TempDir, "repository", "import", "--from", "ghq", "--dry-run", root)
+	if err != nil {
+		t.Fatalf("repository import --dry-run failed: %v", err)
+	}
+	if !strings.Contains(stdout, clone+", to be adopted:") {
+		t.Errorf("Expected the clone to be planned for adoption, got:\n%s", stdout)
+	}
+
+	stdout, _, err = runCommand(t, ws.TempDir, "repository", "import", "--from", "ghq", root)
+	if err != nil {
+		t.Fatalf("repository import failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Imported 1 of 1 repositories") {
+		t.Errorf("Expected the clone to be imported, got:\n%s", stdout)
+	}
+	if _, _, err := runCommand(t, ws.TempDir, "--repo", "api", "worktree", "list"); err != nil {
+		t.Errorf("Expected api to be registered: %v", err)
+	}
+
+	if _, _, err := runCommand(t, ws.TempDir, "repository", "import", "--from", "svn", root); errs.ExitCode(err) != errs.ExitUsage {
+		t.Errorf("Expected a usage error for an unknown source, got %v", err)
+	}
+}
+
+// TestWorkspaceExportApply tests that a manifest exported on one machine
+// recreates the setup on another, and that applying it twice is a no-op
+func TestWorkspaceExportApply(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	(&testutil.GitRepo{Path: ws.GitDir}).RunGit(t, "remote", "set-url", "origin", "file://"+ws.Origin.Path)
+	t.Setenv("HOME", ws.TempDir)
+	t.Setenv("GIT_MANAGER_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+	if _, _, err := runCommand(t, ws.Root, "repository", "register", "--name", "api"); err != nil {
+		t.Fatalf("repository register failed: %v", err)
+	}
+
+	manifest := filepath.Join(ws.TempDir, "manifest.yaml")
+	if _, _, err := runCommand(t, ws.TempDir, "workspace", "export", "--worktrees", "-o", manifest); err != nil {
+		t.Fatalf("workspace export failed: %v", err)
+	}
+
+	fresh := filepath.Join(ws.TempDir, "fresh")
+	t.Setenv("HOME", fresh)
+	t.Setenv("GIT_MANAGER_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+
+	stdout, _, err := runCommand(t, ws.TempDir, "workspace", "apply", manifest)
+	if err != nil {
+		t.Fatalf("workspace apply failed: %v\n%s", err, stdout)
+	}
+	if !strings.Contains(stdout, "clone file://"+ws.Origin.Path) {
+		t.Errorf("Expected the repository to be cloned, got:\n%s", stdout)
+	}
+
+	stdout, _, err = runCommand(t, ws.TempDir, "workspace", "apply", manifest)
+	if err != nil {
+		t.Fatalf("workspace apply failed: %v\n%s", err, stdout)
+	}
+	if !strings.Contains(stdout, "up to date") {
+		t.Errorf("Expected the second apply to change nothing, got:\n%s", stdout)
+	}
+}
+
+// TestFetchAllRepos tests fetching every registered repository, with a
+// summary table and a failure exit code when one of them cannot be fetched
+func TestFetchAllRepos(t *testing.T) {
+	good, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	bad, cleanup2 := testutil.SetupWorkspace(t)
+	defer cleanup2()
+	t.Setenv("GIT_MANAGER_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+
+	for name, ws := range map[string]*testutil.Workspace{"good": good, "bad": bad} {
+		if _, _, err := runCommand(t, ws.Root, "repository", "register", "--name", name); err != nil {
+			t.Fatalf("repository register failed: %v", err)
+		}
+	}
+	good.Origin.RunGit(t, "branch", "topic")
+	(&testutil.GitRepo{Path: bad.GitDir}).RunGit(t, "remote", "set-url", "origin", filepath.Join(bad.TempDir, "gone"))
+
+	stdout, stderr, err := runCommand(t, good.TempDir, "fetch", "--all-repos", "--prune")
+	if errs.ExitCode(err) != errs.ExitUnknown {
+		t.Errorf("Expected a failure exit code, got %v", err)
+	}
+	if !regexp.MustCompile(`good\s+2\s+0\s+0\s+ok`).MatchString(stdout) || !regexp.MustCompile(`bad\s+0\s+0\s+0\s+failed`).MatchString(stdout) {
+		t.Errorf("Expected a summary table with 2 new refs for good and bad failed, got:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, "Failed to fetch bad") {
+		t.Errorf("Expected the failure to be reported, got:\n%s", stderr)
+	}
+
+	// Fetching only the current repository finds nothing new
+	stdout, _, err = runCommand(t, good.Worktree("main").Path, "fetch")
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if !regexp.MustCompile(`good\s+0\s+0\s+0\s+ok`).MatchString(stdout) {
+		t.Errorf("Expected nothing new, got:\n%s", stdout)
+	}
+}
+
+// TestUpdate tests fetching and fast-forwarding, then rebasing with a
+// conflict that is reported and rolled back
+func TestUpdate(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	clash := ws.AddWorktree(t, "clash")
+	clash.CreateFile(t, "README.md", "# Clash\n")
+	clash.AddAndCommit(t, "Change README", "README.md")
+	ws.Origin.CreateFile(t, "README.md", "# Upstream\n")
+	ws.Origin.AddAndCommit(t, "Update README", "README.md")
+
+	stdout, _, err := runCommand(t, ws.Worktree("main").Path, "update", "--fetch")
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if !regexp.MustCompile(`main\s+main\s+updated\s+1 commit\(s\) from origin/main`).MatchString(stdout) {
+		t.Errorf("Expected main to be fast-forwarded, got:\n%s", stdout)
+	}
+
+	stdout, _, err = runCommand(t, ws.Worktree("main").Path, "update", "--rebase")
+	if errs.ExitCode(err) != errs.ExitConflict {
+		t.Errorf("Expected a conflict exit code, got %v", err)
+	}
+	if !regexp.MustCompile(`clash\s+clash\s+conflict\s+rebase aborted, README.md conflict\(s\) with main`).MatchString(stdout) {
+		t.Errorf("Expected the conflict to be reported, got:\n%s", stdout)
+	}
+	clash.AssertFileContent(t, "README.md", "# Clash\n")
+}
+
+// TestConflictsJSON tests that predicted conflicts are printed as JSON and
+// reflected in the exit code
+func TestConflictsJSON(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	clash := ws.AddWorktree(t, "clash")
+	clash.CreateFile(t, "README.md", "# Clash\n")
+	clash.AddAndCommit(t, "Change README", "README.md")
+	ws.AddWorktree(t, "quiet")
+	main := ws.Worktree("main")
+	main.CreateFile(t, "README.md", "# Main\n")
+	main.AddAndCommit(t, "Change README on main", "README.md")
+
+	stdout, _, err := runCommand(t, main.Path, "conflicts", "--json")
+	if errs.ExitCode(err) != errs.ExitConflict {
+		t.Errorf("Expected a conflict exit code, got %v", err)
+	}
+
+	var got []map[string]any
+	if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+		t.Fatalf("Expected JSON, got %v:\n%s", err, stdout)
+	}
+	if len(got) != 2 || got[0]["branch"] != "clash" || got[0]["conflicts"] != true || fmt.Sprint(got[0]["files"]) != "[README.md]" {
+		t.Errorf("Expected clash to conflict on README.md, got %v", got)
+	}
+	if got[1]["branch"] != "quiet" || got[1]["conflicts"] != false || got[1]["against"] != "main" {
+		t.Errorf("Expected quiet to merge cleanly with main, got %v", got[1])
+	}
+}
+
+// TestOverlap tests reporting two worktrees that change the same lines
+func TestOverlap(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	ws.AddWorktree(t, "left").CreateFile(t, "README.md", "# Left\n")
+	right := ws.AddWorktree(t, "right")
+	right.CreateFile(t, "README.md", "# Right\n")
+	right.AddAndCommit(t, "Change README", "README.md")
+
+	stdout, _, err := runCommand(t, ws.Worktree("main").Path, "overlap")
+	if err != nil {
+		t.Fatalf("overlap failed: %v", err)
+	}
+	if !regexp.MustCompile(`left, right\s+README.md\s+lines 1\n`).MatchString(stdout) {
+		t.Errorf("Expected left and right to overlap on line 1, got:\n%s", stdout)
+	}
+}
+
+// TestExec tests running a command in selected worktrees, with prefixed
+// output and a summary table
+func TestExec(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	ws.AddWorktree(t, "feature")
+	ws.AddWorktree(t, "fix")
+
+	stdout, _, err := runCommand(t, ws.Worktree("main").Path, "exec", "--worktrees", "f*", "git", "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		t.Fatalf("exec failed: %v", err)
+	}
+	for _, want := range []string{"[feature] feature\n", "[fix] fix\n"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in the output, got:\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, "[main]") || !regexp.MustCompile(`feature\s+ok\s+0\s+\S+s\n`).MatchString(stdout) {
+		t.Errorf("Expected only the selected worktrees and a summary, got:\n%s", stdout)
+	}
+
+	// The first failure skips the rest
+	stdout, _, err = runCommand(t, ws.Worktree("main").Path, "exec", "-j", "1", "--fail-fast", "--buffer", "--", "git", "rev-parse", "--verify", "--quiet", "nope")
+	if errs.ExitCode(err) != errs.ExitUnknown {
+		t.Errorf("Expected a failure exit code, got %v", err)
+	}
+	if !regexp.MustCompile(`feature\s+failed\s+1\s`).MatchString(stdout) || !regexp.MustCompile(`main\s+skipped\s+-\s`).MatchString(stdout) {
+		t.Errorf("Expected feature to fail and main to be skipped, got:\n%s", stdout)
+	}
+}
+
+// TestGrep tests searching every worktree, as text and JSON
+func TestGrep(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	ws.AddWorktree(t, "same")
+	edit := ws.AddWorktree(t, "edit")
+	edit.CreateFile(t, "README.md", "# Edited\n\nTest Repository\n")
+
+	stdout, _, err := runCommand(t, ws.Worktree("main").Path, "grep", "-i", "test", "--", "*.md")
+	if err != nil {
+		t.Fatalf("grep failed: %v", err)
+	}
+	for _, want := range []string{"==> edit <==\nREADME.md:3:Test Repository\n", "==> main <==\nREADME.md:1:# Test Repository\n  (also in: same)\n"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in the output, got:\n%s", want, stdout)
+		}
+	}
+
+	stdout, _, err = runCommand(t, ws.Worktree("main").Path, "grep", "--json", "Edited")
+	if err != nil {
+		t.Fatalf("grep --json failed: %v", err)
+	}
+	var matches []map[string]any
+	if err := json.Unmarshal([]byte(stdout), &matches); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", stdout, err)
+	}
+	if len(matches) != 1 || matches[0]["source"] != "edit" || matches[0]["path"] != "README.md" || matches[0]["line"] != float64(1) {
+		t.Errorf("Unexpected matches: %v", matches)
+	}
+
+	if _, _, err := runCommand(t, ws.Worktree("main").Path, "grep", "test", "README.md"); errs.ExitCode(err) != errs.ExitUsage {
+		t.Errorf("Expected a usage error for pathspecs without --, got %v", err)
+	}
+}
+
+// TestReport tests the Markdown and JSON reports of registered repositories
+func TestReport(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	t.Setenv("GIT_MANAGER_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+	if _, _, err := runCommand(t, ws.Root, "repository", "register", "--name", "api"); err != nil {
+		t.Fatalf("repository register failed: %v", err)
+	}
+	feature := ws.AddWorktree(t, "feature")
+	feature.CreateFile(t, "a.txt", "one\ntwo\n")
+	feature.AddAndCommit(t, "Add a", "a.txt")
+	ws.AddWorktree(t, "fix").CreateFile(t, "README.md", "# Fixing\n")
+
+	stdout, _, err := runCommand(t, ws.TempDir, "report", "--since", "yesterday")
+	if err != nil {
+		t.Fatalf("report failed: %v", err)
+	}
+	for _, want := range []string{
+		"# Since Wed Jan 1 00:00\n",
+		"## api\n",
+		"### feature\n\n1 commit, +2 -0\n\n- `",
+		" Add a (1 file, +2 -0)\n",
+		"### fix (in progress)\n\nNo commits yet",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in the report, got:\n%s", want, stdout)
+		}
+	}
+
+	stdout, _, err = runCommand(t, ws.TempDir, "report", "--json", "--author", "nobody")
+	if err != nil {
+		t.Fatalf("report --json failed: %v", err)
+	}
+	var reports []map[string]any
+	if err := json.Unmarshal([]byte(stdout), &reports); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", stdout, err)
+	}
+	if len(reports) != 1 || reports[0]["repository"] != "api" || len(reports[0]["branches"].([]any)) != 1 {
+		t.Errorf("Expected only the in progress branch, got:\n%s", stdout)
+	}
+
+	if _, _, err := runCommand(t, ws.TempDir, "report", "--since", "soon"); errs.ExitCode(err) != errs.ExitUsage {
+		t.Errorf("Expected a usage error for an invalid --since, got %v", err)
+	}
+}
+
+// TestDiff tests comparing two worktrees, and handing off to a difftool
+func TestDiff(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	feature := ws.AddWorktree(t, "feature")
+	feature.CreateFile(t, "new.txt", "one\ntwo\n")
+
+	stdout, _, err := runCommand(t, ws.Worktree("main").Path, "diff", "--stat", "main", "feature")
+	if err != nil {
+		t.Fatalf("diff failed: %v", err)
+	}
+	if !regexp.MustCompile(`new\.txt \| 2 \+\+\n 1 file changed, 2 insertions`).MatchString(stdout) {
+		t.Errorf("Expected the untracked file in the diffstat, got:\n%s", stdout)
+	}
+
+	// The difftool gets both versions of the file
+	log := filepath.Join(ws.TempDir, "difftool.log")
+	t.Setenv("GIT_CONFIG_COUNT", "3")
+	t.Setenv("GIT_CONFIG_KEY_0", "diff.tool")
+	t.Setenv("GIT_CONFIG_VALUE_0", "record")
+	t.Setenv("GIT_CONFIG_KEY_1", "difftool.record.cmd")
+	t.Setenv("GIT_CONFIG_VALUE_1", `cat "$REMOTE" >> `+log)
+	t.Setenv("GIT_CONFIG_KEY_2", "difftool.prompt")
+	t.Setenv("GIT_CONFIG_VALUE_2", "false")
+	if _, _, err := runCommand(t, ws.Worktree("main").Path, "diff", "--tool", "main", "feature"); err != nil {
+		t.Fatalf("diff --tool failed: %v", err)
+	}
+	if data, err := os.ReadFile(log); err != nil || string(data) != "one\ntwo\n" {
+		t.Errorf("Expected the difftool to get new.txt, got %q (%v)", data, err)
+	}
+
+	if _, _, err := runCommand(t, ws.Worktree("main").Path, "diff", "--stat", "--tool", "main", "feature"); errs.ExitCode(err) != errs.ExitUsage {
+		t.Errorf("Expected a usage error for --stat with --tool, got %v", err)
+	}
+}
+
+// TestCarry tests moving changes to another worktree, on a conflict and into
+// a new worktree
+func TestCarry(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	main := ws.Worktree("main")
+	feature := ws.AddWorktree(t, "feature")
+	main.CreateFile(t, "README.md", "# Carried\n")
+	main.CreateFile(t, "notes.txt", "notes\n")
+
+	stdout, _, err := runCommand(t, main.Path, "carry", "feature", "--paths", "README.md")
+	if err != nil {
+		t.Fatalf("carry failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Carried changes to 1 file to "+feature.Path+"\n  README.md\n") {
+		t.Errorf("Expected the carried file, got:\n%s", stdout)
+	}
+	feature.AssertFileContent(t, "README.md", "# Carried\n")
+	main.AssertFileContent(t, "README.md", "# Test Repository\n")
+
+	// The changes conflict with the ones just carried
+	feature.AddAndCommit(t, "Carried", "README.md")
+	main.CreateFile(t, "README.md", "# Again\n")
+	if _, _, err := runCommand(t, main.Path, "carry", "feature"); errs.ExitCode(err) != errs.ExitConflict {
+		t.Errorf("Expected a conflict exit code, got %v", err)
+	}
+	main.AssertFileContent(t, "README.md", "# Again\n")
+
+	stdout, _, err = runCommand(t, main.Path, "add", "--carry", "--untracked", "fix")
+	if err != nil {
+		t.Fatalf("add --carry failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Carried changes to 2 files\n") {
+		t.Errorf("Expected two carried files, got:\n%s", stdout)
+	}
+	ws.Worktree("fix").AssertFileContent(t, "README.md", "# Again\n")
+	ws.Worktree("fix").AssertFileContent(t, "notes.txt", "notes\n")
+	if status := main.RunGit(t, "status", "--porcelain"); status != "" {
+		t.Errorf("Expected main to be clean, got:\n%s", status)
+	}
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newConflictsCmd returns the conflicts command
+func newConflictsCmd(app *App) *cobra.Command {
+	var (
+		opts    gitmanager.ConflictOptions
+		jsonOut bool
+	)
+
+	conflictsCmd := &cobra.Command{
+		Use:   "conflicts",
+		Short: "Predict which worktree branches would conflict with the default branch",
+		Long: `Predict which worktree branches would conflict when merged with, or
+rebased onto, the default branch, and on which files. With --pairwise the
+worktree branches are also checked against each other.
+
+The merges happen in memory with "git merge-tree --write-tree", so no worktree
+is touched. This needs git 2.38 or newer.
+
+The exit code is the conflict exit code (7) when any merge would conflict.
+--json prints the predictions as a JSON array for scripts and CI.`,
+		Args:              usageArgs(cobra.NoArgs),
+		PersistentPreRunE: requireRepository(app),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return predictConflicts(cmd.Context(), app, opts, jsonOut)
+		},
+	}
+
+	conflictsCmd.Flags().StringVarP(&opts.Base, "base", "b", "", "Branch to merge with (default: the default branch)")
+	conflictsCmd.Flags().BoolVarP(&opts.Pairwise, "pairwise", "p", false, "Also check the worktree branches against each other")
+	conflictsCmd.Flags().BoolVar(&jsonOut, "json", false, "Print the predictions as JSON")
+
+	return conflictsCmd
+}
+
+// mergePredictionJSON is the JSON form of a merge prediction
+type mergePredictionJSON struct {
+	Branch    string   `json:"branch"`
+	Against   string   `json:"against"`
+	Conflicts bool     `json:"conflicts"`
+	Files     []string `json:"files"`
+	Messages  []string `json:"messages"`
+	Error     string   `json:"error,omitempty"`
+}
+
+func predictConflicts(ctx context.Context, app *App, opts gitmanager.ConflictOptions, jsonOut bool) error {
+	ws, err := app.workspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	predictions, err := app.manager().PredictConflicts(ctx, ws, opts)
+	if err != nil {
+		return err
+	}
+
+	conflicts, failed := 0, 0
+	for _, p := range predictions {
+		switch {
+		case p.Err != nil:
+			failed++
+		case p.Conflicts:
+			conflicts++
+		}
+	}
+
+	if jsonOut {
+		out := make([]mergePredictionJSON, len(predictions))
+		for i, p := range predictions {
+			out[i] = mergePredictionJSON{
+				Branch:    p.Branch,
+				Against:   p.Against,
+				Conflicts: p.Conflicts,
+				Files:     append([]string{}, p.Files...),
+				Messages:  append([]string{}, p.Messages...),
+			}
+			if p.Err != nil {
+				out[i].Error = p.Err.Error()
+			}
+		}
+		enc := json.NewEncoder(app.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	} else if len(predictions) == 0 {
+		fmt.Fprintln(app.Stdout, "No worktree branches to check")
+	} else {
+		w := tabwriter.NewWriter(app.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "BRANCH\tAGAINST\tRESULT\tFILES")
+		for _, p := range predictions {
+			result, files := "clean", "-"
+			switch {
+			case p.Err != nil:
+				result, files = "error", p.Err.Error()
+			case p.Conflicts:
+				result, files = "conflict", strings.Join(p.Files, ", ")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Branch, p.Against, result, files)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case failed > 0:
+		return errs.New(errs.Unknown, "%d merge(s) could not be predicted", failed)
+	case conflicts > 0:
+		return errs.New(errs.Conflict, "%d merge(s) would conflict", conflicts)
+	}
+	return nil
+}
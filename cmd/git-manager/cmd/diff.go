@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newDiffCmd returns the diff command
+func newDiffCmd(app *App) *cobra.Command {
+	var opts gitmanager.DiffOptions
+
+	diffCmd := &cobra.Command{
+		Use:   "diff <from> <to> [-- <pathspec>...]",
+		Short: "Compare what is in two worktrees right now",
+		Long: `Compare the full working state of two worktrees, named by directory or
+branch: their commits along with staged, unstaged and untracked changes.
+Ignored files are left out. The diff shows what turns <from> into <to>, so
+"git-manager diff feature experiment" shows what the experiment changes
+compared to the feature worktree.
+
+--stat prints a diffstat, --name-only only the changed files and --tool opens
+the changes in the difftool configured with diff.tool. Neither worktree nor
+its index is changed. Pathspecs after -- are relative to the worktrees.`,
+		Args:              usageArgs(cobra.MinimumNArgs(2)),
+		PersistentPreRunE: requireRepository(app),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dash := cmd.ArgsLenAtDash(); dash >= 0 && dash != 2 || dash < 0 && len(args) > 2 {
+				return errs.New(errs.Usage, "pathspecs must come after --")
+			}
+			if opts.Stat && opts.NameOnly || opts.Tool && (opts.Stat || opts.NameOnly) {
+				return errs.New(errs.Usage, "--stat, --name-only and --tool cannot be used together")
+			}
+			ws, err := app.workspace(cmd.Context())
+			if err != nil {
+				return err
+			}
+			opts.Paths = args[2:]
+			opts.Color = app.liveOutput()
+			opts.Stdin, opts.Stdout = app.Stdin, app.Stdout
+			return app.manager().DiffWorktrees(cmd.Context(), ws, args[0], args[1], opts)
+		},
+	}
+
+	diffCmd.Flags().BoolVar(&opts.Stat, "stat", false, "Print a diffstat instead of a patch")
+	diffCmd.Flags().BoolVar(&opts.NameOnly, "name-only", false, "Print only the names of the changed files")
+	diffCmd.Flags().BoolVarP(&opts.Tool, "tool", "t", false, "Open the changes in the configured git difftool")
+
+	return diffCmd
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/config"
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/internal/testutil"
+)
+
+// TestInitDryRun tests that init --dry-run prints the plan and changes nothing
+func TestInitDryRun(t *testing.T) {
+	configPath := isolateConfig(t)
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	stdout, _, err := runCommand(t, dir, "repository", "init", "--dry-run", ws.Origin.Path)
+	if err != nil {
+		t.Fatalf("init --dry-run failed: %v", err)
+	}
+
+	repoDir := filepath.Join(dir, "origin")
+	for _, want := range []string{
+		"create directory " + filepath.Join(repoDir, "main"),
+		"$ git clone --bare " + ws.Origin.Path + " " + filepath.Join(repoDir, ".git"),
+		"$ git -C " + filepath.Join(repoDir, ".git") + " worktree add " + filepath.Join(repoDir, "main"),
+		"register repository 'origin' at " + repoDir,
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected dry run output to contain %q, got:\n%s", want, stdout)
+		}
+	}
+
+	if _, err := os.Stat(repoDir); !os.IsNotExist(err) {
+		t.Errorf("Expected %s not to be created, got %v", repoDir, err)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(cfg.Repositories) != 0 {
+		t.Errorf("Expected nothing to be registered, got %v", cfg.Repositories)
+	}
+}
+
+// TestAddDryRun tests that add --dry-run prints the git command and changes nothing
+func TestAddDryRun(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	stdout, _, err := runCommand(t, ws.Root, "add", "--dry-run", "feature")
+	if err != nil {
+		t.Fatalf("add --dry-run failed: %v", err)
+	}
+
+	want := "$ git -C " + ws.GitDir + " worktree add -b feature " + filepath.Join(ws.Root, "feature") + " main"
+	if !strings.Contains(stdout, want) {
+		t.Errorf("Expected dry run output to contain %q, got:\n%s", want, stdout)
+	}
+	if strings.Contains(stdout, "git-manager-eval:") {
+		t.Errorf("Expected no shell directive during a dry run, got:\n%s", stdout)
+	}
+	if _, err := os.Stat(filepath.Join(ws.Root, "feature")); !os.IsNotExist(err) {
+		t.Errorf("Expected no worktree to be created, got %v", err)
+	}
+
+	// Problems are still reported, because everything is resolved first
+	ws.AddWorktree(t, "taken")
+	_, _, err = runCommand(t, ws.Root, "add", "--dry-run", "taken")
+	if !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected a conflict error, got %v", err)
+	}
+}
+
+// TestRemoveDryRun tests that remove --dry-run prints the git commands and changes nothing
+func TestRemoveDryRun(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	feature := ws.AddWorktree(t, "feature")
+
+	stdout, _, err := runCommand(t, ws.Root, "worktree", "remove", "--dry-run", "--delete-branch", "feature")
+	if err != nil {
+		t.Fatalf("remove --dry-run failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"$ git -C " + ws.GitDir + " worktree remove " + feature.Path,
+		"$ git -C " + ws.GitDir + " branch -D feature",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected dry run output to contain %q, got:\n%s", want, stdout)
+		}
+	}
+
+	if _, err := os.Stat(feature.Path); err != nil {
+		t.Errorf("Expected the worktree to be kept: %v", err)
+	}
+	(&testutil.GitRepo{Path: ws.GitDir}).AssertBranchExists(t, "feature")
+}
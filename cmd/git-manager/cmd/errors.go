@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/spf13/cobra"
+)
+
+// usageArgs wraps a positional argument validator so that its failures are
+// reported as usage errors
+func usageArgs(validate cobra.PositionalArgs) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := validate(cmd, args); err != nil {
+			return errs.Wrap(errs.Usage, err, "")
+		}
+		return nil
+	}
+}
+
+// usageFlagError reports flag parsing failures as usage errors
+func usageFlagError(cmd *cobra.Command, err error) error {
+	return errs.Wrap(errs.Usage, err, "")
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// execOptions configures exec
+type execOptions struct {
+	Repos     []string
+	AllRepos  bool
+	Worktrees []string
+	Jobs      int
+	Buffer    bool
+	FailFast  bool
+}
+
+// newExecCmd returns the exec command
+func newExecCmd(app *App) *cobra.Command {
+	var opts execOptions
+
+	execCmd := &cobra.Command{
+		Use:   "exec [flags] [--] <command> [args...]",
+		Short: "Run a command in every worktree",
+		Long: `Run a command in every worktree of the current repository, of the
+repositories named with --repos, or of every registered repository with
+--all-repos. --worktrees limits it to the worktrees whose directory name or
+branch matches one of the given glob patterns.
+
+Up to --jobs commands run at once. Their output is printed as it comes, each
+line prefixed with the worktree, or with --buffer all at once when a command
+finishes. A table at the end lists the exit status and duration of every
+command. With --fail-fast the first failure stops the commands still running
+and skips those not started yet.
+
+Put -- before the command when it has flags of its own:
+
+  git-manager exec --all-repos -j 4 -- make test`,
+		Args: usageArgs(cobra.MinimumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.Jobs < 1 {
+				return errs.New(errs.Usage, "--jobs must be at least 1")
+			}
+			if opts.AllRepos && len(opts.Repos) > 0 {
+				return errs.New(errs.Usage, "--repos and --all-repos cannot be used together")
+			}
+			return execInWorktrees(cmd.Context(), app, opts, args)
+		},
+	}
+
+	// Flags after the command belong to the command
+	execCmd.Flags().SetInterspersed(false)
+
+	execCmd.Flags().StringSliceVar(&opts.Repos, "repos", nil, "Run in these registered repositories, comma-separated")
+	execCmd.Flags().BoolVarP(&opts.AllRepos, "all-repos", "a", false, "Run in every registered repository")
+	execCmd.Flags().StringSliceVarP(&opts.Worktrees, "worktrees", "w", nil, "Only run in worktrees whose name or branch matches one of these glob patterns, comma-separated")
+	execCmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", gitmanager.DefaultExecJobs, "How many commands to run at once")
+	execCmd.Flags().BoolVar(&opts.Buffer, "buffer", false, "Print the output of each command at once when it finishes")
+	execCmd.Flags().BoolVar(&opts.FailFast, "fail-fast", false, "Stop at the first command that fails")
+
+	return execCmd
+}
+
+func execInWorktrees(ctx context.Context, app *App, opts execOptions, argv []string) error {
+	m := app.manager()
+
+	workspaces, err := execWorkspaces(ctx, app, m, opts)
+	if err != nil {
+		return err
+	}
+	targets, err := m.ExecTargets(ctx, workspaces, opts.Worktrees)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return errs.New(errs.NotFound, "no worktrees match")
+	}
+
+	labels := make([]string, len(targets))
+	for i, t := range targets {
+		labels[i] = filepath.Base(t.Worktree.Path)
+		if len(workspaces) > 1 {
+			labels[i] = workspaceLabel(t.Workspace) + "/" + labels[i]
+		}
+	}
+
+	out := &lockedWriter{w: app.Stdout}
+	results := m.Exec(ctx, targets, argv, gitmanager.ExecOptions{
+		Jobs:     opts.Jobs,
+		FailFast: opts.FailFast,
+		Output: func(i int) io.Writer {
+			if opts.Buffer {
+				return &blockWriter{w: out, header: "==> " + labels[i] + " <==\n"}
+			}
+			return &prefixWriter{w: out, prefix: "[" + labels[i] + "] "}
+		},
+	})
+
+	fmt.Fprintln(app.Stdout)
+	w := tabwriter.NewWriter(app.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WORKTREE\tSTATUS\tEXIT\tDURATION")
+	failed, stopped := 0, 0
+	for i, res := range results {
+		exit := "-"
+		if res.ExitCode >= 0 {
+			exit = fmt.Sprint(res.ExitCode)
+		}
+		switch res.Status {
+		case gitmanager.ExecFailed:
+			failed++
+		case gitmanager.ExecCancelled, gitmanager.ExecSkipped:
+			stopped++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", labels[i], res.Status, exit, res.Duration.Round(time.Millisecond))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			fmt.Fprintf(app.Stderr, "%s: %v\n", labels[i], res.Err)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap(errs.Interrupted, err, "exec was interrupted")
+	}
+	switch {
+	case failed > 0 && stopped > 0:
+		return errs.New(errs.Unknown, "%d of %d commands failed, %d were cancelled or skipped", failed, len(targets), stopped)
+	case failed > 0:
+		return errs.New(errs.Unknown, "%d of %d commands failed", failed, len(targets))
+	}
+	return nil
+}
+
+// execWorkspaces returns the workspaces selected by opts
+func execWorkspaces(ctx context.Context, app *App, m *gitmanager.Manager, opts execOptions) ([]*gitmanager.Workspace, error) {
+	switch {
+	case opts.AllRepos:
+		return app.registeredWorkspaces(ctx, m)
+	case len(opts.Repos) > 0:
+		var workspaces []*gitmanager.Workspace
+		for _, name := range opts.Repos {
+			ws, err := m.Resolve(ctx, gitmanager.Target{Repo: name})
+			if err != nil {
+				return nil, err
+			}
+			workspaces = append(workspaces, ws)
+		}
+		return workspaces, nil
+	default:
+		ws, err := app.workspace(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []*gitmanager.Workspace{ws}, nil
+	}
+}
+
+// lockedWriter serializes writes to w
+type lockedWriter struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// Write implements io.Writer
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// prefixWriter writes complete lines to w, each prefixed with prefix, so
+// lines of commands running at once do not mix
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+// Write implements io.Writer
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := p.w.Write([]byte(p.prefix + string(p.buf[:i+1]))); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Close writes a last line that did not end with a newline
+func (p *prefixWriter) Close() error {
+	if len(p.buf) > 0 {
+		_, err := p.w.Write([]byte(p.prefix + strings.TrimRight(string(p.buf), "\r") + "\n"))
+		p.buf = nil
+		return err
+	}
+	return nil
+}
+
+// blockWriter collects the output of a command and writes it to w in one
+// block, under header, when closed
+type blockWriter struct {
+	w      io.Writer
+	header string
+	buf    bytes.Buffer
+}
+
+// Write implements io.Writer
+func (b *blockWriter) Write(p []byte) (int, error) {
+	return b.buf.Write(p)
+}
+
+// Close writes the collected output
+func (b *blockWriter) Close() error {
+	_, err := b.w.Write(append([]byte(b.header), b.buf.Bytes()...))
+	return err
+}
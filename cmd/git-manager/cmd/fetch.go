@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newFetchCmd returns the fetch command
+func newFetchCmd(app *App) *cobra.Command {
+	var (
+		allRepos bool
+		prune    bool
+		jobs     int
+	)
+
+	fetchCmd := &cobra.Command{
+		Use:   "fetch",
+		Short: "Fetch every remote of the current or all registered repositories",
+		Long: `Fetch every remote of the current repository, or of every registered
+repository with --all-repos. Repositories are fetched in parallel, --jobs at a
+time. Remotes of bare clones that have no fetch refspec still get their
+branches fetched into remote-tracking branches.
+
+On a terminal the progress of each running fetch is shown live. At the end a
+table lists the new, updated and deleted refs of each repository. The exit
+code is non-zero when any repository could not be fetched.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if jobs < 1 {
+				return errs.New(errs.Usage, "--jobs must be at least 1")
+			}
+			return fetchRepositories(cmd.Context(), app, allRepos, gitmanager.FetchOptions{Prune: prune, Jobs: jobs})
+		},
+	}
+
+	fetchCmd.Flags().BoolVarP(&allRepos, "all-repos", "a", false, "Fetch every registered repository")
+	fetchCmd.Flags().BoolVarP(&prune, "prune", "p", false, "Delete remote-tracking branches whose branch is gone from the remote")
+	fetchCmd.Flags().IntVarP(&jobs, "jobs", "j", gitmanager.DefaultFetchJobs, "How many repositories to fetch at once")
+
+	return fetchCmd
+}
+
+func fetchRepositories(ctx context.Context, app *App, allRepos bool, opts gitmanager.FetchOptions) error {
+	m := app.manager()
+
+	var workspaces []*gitmanager.Workspace
+	if allRepos {
+		var err error
+		if workspaces, err = app.registeredWorkspaces(ctx, m); err != nil {
+			return err
+		}
+		if len(workspaces) == 0 {
+			fmt.Fprintln(app.Stdout, "No repositories registered")
+			return nil
+		}
+	} else {
+		ws, err := m.Resolve(ctx, gitmanager.Target{Dir: app.Dir})
+		if err != nil {
+			return err
+		}
+		workspaces = []*gitmanager.Workspace{ws}
+	}
+
+	view := newProgressView(app.Stdout, app.liveOutput())
+	opts.OnProgress = func(p gitmanager.FetchProgress) {
+		label := workspaceLabel(p.Workspace)
+		switch {
+		case p.Done && p.Err != nil:
+			view.finish(label, fmt.Sprintf("%s: failed", label))
+		case p.Done:
+			view.finish(label, fmt.Sprintf("%s: done", label))
+		case p.Phase == "":
+			view.update(label, fmt.Sprintf("%s: fetching %s...", label, p.Remote))
+		default:
+			view.update(label, fmt.Sprintf("%s: fetching %s, %s %d%%", label, p.Remote, p.Phase, p.Percent))
+		}
+	}
+
+	results := m.Fetch(ctx, workspaces, opts)
+
+	fmt.Fprintln(app.Stdout)
+	w := tabwriter.NewWriter(app.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPOSITORY\tNEW\tUPDATED\tDELETED\tSTATUS")
+	failed := 0
+	for _, res := range results {
+		status := "ok"
+		switch {
+		case res.Err != nil:
+			status = "failed"
+			failed++
+		case len(res.Remotes) == 0:
+			status = "no remotes"
+		}
+		updated := res.Count(gitmanager.RefUpdated) + res.Count(gitmanager.RefForced)
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", workspaceLabel(res.Workspace), res.Count(gitmanager.RefNew), updated, res.Count(gitmanager.RefDeleted), status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, res := range results {
+		if res.Err == nil {
+			continue
+		}
+		// One line per failed remote
+		failures := []error{res.Err}
+		if joined, ok := res.Err.(interface{ Unwrap() []error }); ok {
+			failures = joined.Unwrap()
+		}
+		for _, err := range failures {
+			fmt.Fprintf(app.Stderr, "Failed to fetch %s: %v\n", workspaceLabel(res.Workspace), err)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap(errs.Interrupted, err, "fetch was interrupted")
+	}
+	if failed > 0 {
+		return errs.New(errs.Unknown, "%d of %d repositories could not be fetched", failed, len(results))
+	}
+	return nil
+}
+
+// workspaceLabel names ws in output: its registered name, or its directory
+// when it is not registered
+func workspaceLabel(ws *gitmanager.Workspace) string {
+	if ws.Name != "" {
+		return ws.Name
+	}
+	return ws.Root
+}
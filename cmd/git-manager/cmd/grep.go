@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newGrepCmd returns the grep command
+func newGrepCmd(app *App) *cobra.Command {
+	var (
+		opts     gitmanager.GrepOptions
+		allRepos bool
+		jsonOut  bool
+	)
+
+	grepCmd := &cobra.Command{
+		Use:   "grep <pattern> [-- <pathspec>...]",
+		Short: "Search every worktree with git grep",
+		Long: `Search the checkout of every worktree of the current repository with
+"git grep", including untracked files that are not ignored. With --committed
+the committed tree of every local branch is searched instead. --all-repos
+searches every registered repository. Searches run in parallel, --jobs at a
+time.
+
+Matches are grouped by worktree, or branch. A file with the same path and
+content in several worktrees is listed once, with the others named after it.
+--json prints the matches as a JSON array for editors and scripts.
+
+Pathspecs after -- limit the search:
+
+  git-manager grep -i todo -- '*.go'`,
+		Args: usageArgs(cobra.MinimumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.Jobs < 1 {
+				return errs.New(errs.Usage, "--jobs must be at least 1")
+			}
+			if dash := cmd.ArgsLenAtDash(); dash > 1 || (dash < 0 && len(args) > 1) {
+				return errs.New(errs.Usage, "pathspecs must come after --")
+			}
+			opts.Pattern, opts.Paths = args[0], args[1:]
+			return grepWorktrees(cmd.Context(), app, allRepos, opts, jsonOut)
+		},
+	}
+
+	grepCmd.Flags().BoolVarP(&opts.IgnoreCase, "ignore-case", "i", false, "Match without regard to case")
+	grepCmd.Flags().BoolVarP(&opts.FixedStrings, "fixed-strings", "F", false, "Take the pattern literally instead of as a regular expression")
+	grepCmd.Flags().BoolVar(&opts.Committed, "committed", false, "Search the committed tree of every local branch instead of the worktrees")
+	grepCmd.Flags().BoolVarP(&allRepos, "all-repos", "a", false, "Search every registered repository")
+	grepCmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", gitmanager.DefaultGrepJobs, "How many searches to run at once")
+	grepCmd.Flags().BoolVar(&jsonOut, "json", false, "Print the matches as JSON")
+
+	return grepCmd
+}
+
+// grepMatchJSON is the JSON form of a matching line
+type grepMatchJSON struct {
+	Repository string   `json:"repository"`
+	Source     string   `json:"source"`
+	Path       string   `json:"path"`
+	Line       int      `json:"line"`
+	Text       string   `json:"text"`
+	Also       []string `json:"also"`
+}
+
+func grepWorktrees(ctx context.Context, app *App, allRepos bool, opts gitmanager.GrepOptions, jsonOut bool) error {
+	m := app.manager()
+
+	var workspaces []*gitmanager.Workspace
+	if allRepos {
+		var err error
+		if workspaces, err = app.registeredWorkspaces(ctx, m); err != nil {
+			return err
+		}
+	} else {
+		ws, err := app.workspace(ctx)
+		if err != nil {
+			return err
+		}
+		workspaces = []*gitmanager.Workspace{ws}
+	}
+
+	results, err := m.Grep(ctx, workspaces, opts)
+	if err != nil {
+		return err
+	}
+
+	failed, matches := 0, 0
+	for _, res := range results {
+		if res.Err != nil {
+			failed++
+		}
+		matches += len(res.Files)
+	}
+
+	if jsonOut {
+		out := []grepMatchJSON{}
+		for _, res := range results {
+			for _, f := range res.Files {
+				for _, line := range f.Matches {
+					out = append(out, grepMatchJSON{
+						Repository: workspaceLabel(res.Workspace),
+						Source:     res.Source,
+						Path:       f.Path,
+						Line:       line.Line,
+						Text:       line.Text,
+						Also:       append([]string{}, f.Also...),
+					})
+				}
+			}
+		}
+		enc := json.NewEncoder(app.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	} else if matches == 0 && failed == 0 {
+		fmt.Fprintln(app.Stdout, "No matches")
+	} else {
+		first := true
+		for _, res := range results {
+			if len(res.Files) == 0 {
+				continue
+			}
+			if !first {
+				fmt.Fprintln(app.Stdout)
+			}
+			first = false
+
+			label := res.Source
+			if len(workspaces) > 1 {
+				label = workspaceLabel(res.Workspace) + "/" + label
+			}
+			fmt.Fprintf(app.Stdout, "==> %s <==\n", label)
+			for _, f := range res.Files {
+				for _, line := range f.Matches {
+					fmt.Fprintf(app.Stdout, "%s:%d:%s\n", f.Path, line.Line, line.Text)
+				}
+				if len(f.Also) > 0 {
+					fmt.Fprintf(app.Stdout, "  (also in: %s)\n", strings.Join(f.Also, ", "))
+				}
+			}
+		}
+	}
+
+	for _, res := range results {
+		if res.Err != nil {
+			fmt.Fprintf(app.Stderr, "Failed to search %s: %v\n", res.Source, res.Err)
+		}
+	}
+	if failed > 0 {
+		return errs.New(errs.Unknown, "%d of %d searches failed", failed, len(results))
+	}
+	return nil
+}
@@ -4,16 +4,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
-// hoist the worktreeListCmd to the rootCmd for convenience
-var listCmd = &cobra.Command{
-	Use:               worktreeListCmd.Use,
-	Aliases:           worktreeListCmd.Aliases,
-	Short:             worktreeListCmd.Short,
-	Long:              worktreeListCmd.Long,
-	PersistentPreRunE: worktreeListCmd.PersistentPreRunE,
-	Run:               worktreeListCmd.Run,
-}
-
-func init() {
-	rootCmd.AddCommand(listCmd)
+// newListCmd hoists the worktree list command to the root command for convenience
+func newListCmd(app *App) *cobra.Command {
+	listCmd := newWorktreeListCmd(app)
+	// the hoisted command needs the same repository check as its parent
+	listCmd.PersistentPreRunE = requireRepository(app)
+	return listCmd
 }
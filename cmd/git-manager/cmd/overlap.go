@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newOverlapCmd returns the overlap command
+func newOverlapCmd(app *App) *cobra.Command {
+	var opts gitmanager.OverlapOptions
+
+	overlapCmd := &cobra.Command{
+		Use:   "overlap",
+		Short: "Find worktrees that change the same files",
+		Long: `Find pairs of worktrees that change the same files.
+The changes of a worktree are everything that differs from its merge base with
+the default branch, or --base: its commits, staged and unstaged changes and
+untracked files.
+
+For each file two worktrees both change, this prints the lines of the merge
+base version that both change, "different lines" when they change different
+parts of the file, or "whole file" when one of them adds the file or changes
+it as a whole.`,
+		Args:              usageArgs(cobra.NoArgs),
+		PersistentPreRunE: requireRepository(app),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return findOverlaps(cmd.Context(), app, opts)
+		},
+	}
+
+	overlapCmd.Flags().StringVarP(&opts.Base, "base", "b", "", "Branch to measure changes from (default: the default branch)")
+
+	return overlapCmd
+}
+
+func findOverlaps(ctx context.Context, app *App, opts gitmanager.OverlapOptions) error {
+	ws, err := app.workspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	overlaps, err := app.manager().FindOverlaps(ctx, ws, opts)
+	if err != nil {
+		return err
+	}
+	if len(overlaps) == 0 {
+		fmt.Fprintln(app.Stdout, "No overlapping changes")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(app.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WORKTREES\tFILE\tOVERLAP")
+	for _, o := range overlaps {
+		pair := filepath.Base(o.A.Path) + ", " + filepath.Base(o.B.Path)
+		for _, f := range o.Files {
+			overlap := "different lines"
+			switch {
+			case f.Whole:
+				overlap = "whole file"
+			case len(f.Lines) > 0:
+				ranges := make([]string, len(f.Lines))
+				for i, r := range f.Lines {
+					ranges[i] = r.String()
+				}
+				overlap = "lines " + strings.Join(ranges, ", ")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", pair, f.Path, overlap)
+		}
+	}
+	return w.Flush()
+}
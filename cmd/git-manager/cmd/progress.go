@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// progressView shows progress for several tasks at once. On a terminal it
+// keeps one line per running task at the bottom and redraws them in place,
+// with finished tasks printed above. Otherwise it prints a line when a task
+// starts and when it finishes. It is safe for concurrent use.
+type progressView struct {
+	w    io.Writer
+	live bool
+
+	mu     sync.Mutex
+	order  []string
+	lines  map[string]string
+	drawn  int
+	last   time.Time
+	redraw time.Duration
+}
+
+// newProgressView returns a view writing to w, redrawn in place when live
+func newProgressView(w io.Writer, live bool) *progressView {
+	return &progressView{w: w, live: live, lines: map[string]string{}, redraw: 100 * time.Millisecond}
+}
+
+// update sets the progress line of task, starting the task if needed
+func (v *progressView) update(task string, line string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	_, running := v.lines[task]
+	if !running {
+		v.order = append(v.order, task)
+	}
+	v.lines[task] = line
+
+	if !v.live {
+		if !running {
+			fmt.Fprintln(v.w, line)
+		}
+		return
+	}
+
+	// Progress can arrive far faster than it is worth drawing
+	if running && time.Since(v.last) < v.redraw {
+		return
+	}
+	v.draw("")
+}
+
+// finish removes task from the running tasks and prints its final line
+func (v *progressView) finish(task string, line string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	delete(v.lines, task)
+	for i, t := range v.order {
+		if t == task {
+			v.order = append(v.order[:i], v.order[i+1:]...)
+			break
+		}
+	}
+
+	if !v.live {
+		fmt.Fprintln(v.w, line)
+		return
+	}
+	v.draw(line)
+}
+
+// draw clears the running tasks, prints done, if any, above them, and draws
+// them again
+func (v *progressView) draw(done string) {
+	if v.drawn > 0 {
+		fmt.Fprintf(v.w, "\x1b[%dA", v.drawn)
+	}
+	if done != "" {
+		fmt.Fprintf(v.w, "\r\x1b[2K%s\n", done)
+	}
+	for _, task := range v.order {
+		fmt.Fprintf(v.w, "\r\x1b[2K%s\n", v.lines[task])
+	}
+	// Clear what is left of lines drawn before
+	fmt.Fprint(v.w, "\x1b[J")
+	v.drawn = len(v.order)
+	v.last = time.Now()
+}
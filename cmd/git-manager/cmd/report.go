@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newReportCmd returns the report command
+func newReportCmd(app *App) *cobra.Command {
+	var (
+		since   string
+		author  string
+		jsonOut bool
+	)
+
+	reportCmd := &cobra.Command{
+		Use:   "report",
+		Short: "Summarize your recent commits across all registered repositories",
+		Long: `Summarize the commits you made on the local branches of every registered
+repository, for a standup or a status update. Commits are grouped by
+repository and branch, with their subjects and diffstats. A commit on several
+branches is listed under the default branch, or else the first by name.
+Branches checked out in a worktree with uncommitted changes are marked "in
+progress".
+
+--since takes "today", "yesterday", a weekday such as "friday" for the last
+one, a date (2006-01-02), a time (RFC 3339) or a duration such as "36h" or
+"3d". Commits count from when they were authored, so rebased old work is
+left out. --author takes a "git log --author" pattern; the default "me" is the
+user.email configured for each repository.
+
+The summary is Markdown, or JSON with --json.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			start, err := parseSince(since, app.Now())
+			if err != nil {
+				return err
+			}
+			return reportCommits(cmd.Context(), app, gitmanager.ReportOptions{Since: start, Author: author}, jsonOut)
+		},
+	}
+
+	reportCmd.Flags().StringVar(&since, "since", "yesterday", "Only include commits since this day, time or duration ago")
+	reportCmd.Flags().StringVar(&author, "author", "me", "Only include commits whose author matches this pattern")
+	reportCmd.Flags().BoolVar(&jsonOut, "json", false, "Print the report as JSON")
+
+	return reportCmd
+}
+
+// parseSince parses a --since value relative to now. Days start at midnight
+// in now's location.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	switch value {
+	case "today":
+		return midnight, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if value == strings.ToLower(day.String()) {
+			back := (int(now.Weekday()) - int(day) + 7) % 7
+			if back == 0 {
+				back = 7
+			}
+			return midnight.AddDate(0, 0, -back), nil
+		}
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, now.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, strings.ToUpper(value)); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errs.New(errs.Usage, "invalid --since %q, use a day such as \"yesterday\" or \"friday\", a date or a duration such as \"36h\"", value)
+}
+
+// repositoryReport is the report of one repository
+type repositoryReport struct {
+	Workspace *gitmanager.Workspace
+	Branches  []gitmanager.BranchReport
+	Err       error
+}
+
+// reportJSON is the JSON form of a repository's report
+type reportJSON struct {
+	Repository string             `json:"repository"`
+	Branches   []branchReportJSON `json:"branches"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// branchReportJSON is the JSON form of a branch's report
+type branchReportJSON struct {
+	Branch     string             `json:"branch"`
+	InProgress bool               `json:"in_progress"`
+	Insertions int                `json:"insertions"`
+	Deletions  int                `json:"deletions"`
+	Commits    []reportCommitJSON `json:"commits"`
+}
+
+// reportCommitJSON is the JSON form of a commit in a report
+type reportCommitJSON struct {
+	Hash       string    `json:"hash"`
+	Subject    string    `json:"subject"`
+	Time       time.Time `json:"time"`
+	Files      int       `json:"files"`
+	Insertions int       `json:"insertions"`
+	Deletions  int       `json:"deletions"`
+}
+
+func reportCommits(ctx context.Context, app *App, opts gitmanager.ReportOptions, jsonOut bool) error {
+	m := app.manager()
+
+	workspaces, err := app.registeredWorkspaces(ctx, m)
+	if err != nil {
+		return err
+	}
+
+	reports := make([]repositoryReport, len(workspaces))
+	failed := 0
+	for i, ws := range workspaces {
+		reports[i].Workspace = ws
+		reports[i].Branches, reports[i].Err = m.Report(ctx, ws, opts)
+		if reports[i].Err != nil {
+			if err := ctx.Err(); err != nil {
+				return errs.Wrap(errs.Interrupted, err, "report was interrupted")
+			}
+			failed++
+		}
+	}
+
+	if jsonOut {
+		if err := printReportJSON(app, reports); err != nil {
+			return err
+		}
+	} else {
+		printReportMarkdown(app, reports, opts.Since)
+	}
+
+	for _, r := range reports {
+		if r.Err != nil {
+			fmt.Fprintf(app.Stderr, "Failed to report on %s: %v\n", workspaceLabel(r.Workspace), r.Err)
+		}
+	}
+	if failed > 0 {
+		return errs.New(errs.Unknown, "%d of %d repositories could not be reported on", failed, len(reports))
+	}
+	return nil
+}
+
+// printReportMarkdown prints the reports as Markdown, leaving out
+// repositories without commits or work in progress
+func printReportMarkdown(app *App, reports []repositoryReport, since time.Time) {
+	fmt.Fprintf(app.Stdout, "# Since %s\n", since.Format("Mon Jan 2 15:04"))
+
+	empty := true
+	for _, r := range reports {
+		if len(r.Branches) == 0 {
+			continue
+		}
+		empty = false
+		fmt.Fprintf(app.Stdout, "\n## %s\n", workspaceLabel(r.Workspace))
+
+		for _, b := range r.Branches {
+			title := b.Branch
+			if b.InProgress {
+				title += " (in progress)"
+			}
+			fmt.Fprintf(app.Stdout, "\n### %s\n\n", title)
+			if len(b.Commits) == 0 {
+				fmt.Fprintln(app.Stdout, "No commits yet, only uncommitted changes")
+				continue
+			}
+			fmt.Fprintf(app.Stdout, "%s, +%d -%d\n\n", plural(len(b.Commits), "commit"), b.Insertions(), b.Deletions())
+			for _, c := range b.Commits {
+				fmt.Fprintf(app.Stdout, "- `%.7s` %s (%s, +%d -%d)\n", c.Hash, c.Subject, plural(c.Files, "file"), c.Insertions, c.Deletions)
+			}
+		}
+	}
+	if empty {
+		fmt.Fprintln(app.Stdout, "\nNo commits")
+	}
+}
+
+// printReportJSON prints the reports as a JSON array
+func printReportJSON(app *App, reports []repositoryReport) error {
+	out := make([]reportJSON, len(reports))
+	for i, r := range reports {
+		out[i] = reportJSON{Repository: workspaceLabel(r.Workspace), Branches: []branchReportJSON{}}
+		if r.Err != nil {
+			out[i].Error = r.Err.Error()
+		}
+		for _, b := range r.Branches {
+			branch := branchReportJSON{
+				Branch:     b.Branch,
+				InProgress: b.InProgress,
+				Insertions: b.Insertions(),
+				Deletions:  b.Deletions(),
+				Commits:    []reportCommitJSON{},
+			}
+			for _, c := range b.Commits {
+				branch.Commits = append(branch.Commits, reportCommitJSON(c))
+			}
+			out[i].Branches = append(out[i].Branches, branch)
+		}
+	}
+	enc := json.NewEncoder(app.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// plural returns "<n> <noun>" with an "s" unless n is 1
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
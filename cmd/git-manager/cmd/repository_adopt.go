@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/internal/plan"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newAdoptCmd returns the repository adopt command
+func newAdoptCmd(app *App) *cobra.Command {
+	var (
+		name        string
+		allBranches bool
+		scan        string
+		dryRun      bool
+	)
+
+	adoptCmd := &cobra.Command{
+		Use:   "adopt [path]",
+		Short: "Convert a normal clone into a git-manager workspace",
+		Long: `Convert a normal clone into a git-manager workspace, in place.
+The clone at path (default: the current directory) keeps its directory. Its
+.git directory becomes the workspace's bare repository, and the checkout moves
+into the worktree for its branch, with all uncommitted and staged changes.
+Stashes and branches are kept. The workspace is registered, so it can be
+targeted from anywhere with --repo. If anything fails, the clone is restored.
+
+With --all-branches, every other local branch gets a worktree too. Branches
+whose worktree would overlap the checkout's are reported and skipped.
+
+With --scan, every clone found under the given directory is adopted. Clones
+that cannot be adopted are reported and skipped.`,
+		Args: usageArgs(cobra.MaximumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if scan != "" {
+				if len(args) > 0 || name != "" {
+					return errs.New(errs.Usage, "--scan cannot be combined with a path or --name")
+				}
+				return adoptClones(cmd.Context(), app, absPath(app, scan), allBranches, dryRun)
+			}
+
+			dir := app.Dir
+			if len(args) == 1 {
+				dir = absPath(app, args[0])
+			}
+			return adoptClone(cmd.Context(), app, gitmanager.AdoptOptions{
+				Dir:         dir,
+				Name:        name,
+				AllBranches: allBranches,
+				DryRun:      dryRun,
+			})
+		},
+	}
+
+	adoptCmd.Flags().StringVar(&name, "name", "", "Name to register the repository under (default: the clone's directory name)")
+	adoptCmd.Flags().BoolVar(&allBranches, "all-branches", false, "Add a worktree for every other local branch")
+	adoptCmd.Flags().StringVar(&scan, "scan", "", "Adopt every clone found under this directory")
+	adoptCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Print the steps that would be run, without changing anything")
+
+	return adoptCmd
+}
+
+// absPath resolves path against the app's directory
+func absPath(app *App, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(app.Dir, path)
+}
+
+func adoptClone(ctx context.Context, app *App, opts gitmanager.AdoptOptions) error {
+	res, err := app.manager().AdoptRepository(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for _, branch := range res.Skipped {
+		fmt.Fprintf(app.Stderr, "Skipped branch '%s', its worktree %s would overlap %s\n", branch, filepath.Join(res.Workspace.Root, branch), res.Worktree)
+	}
+	if opts.DryRun {
+		plan.PrintSteps(app.Stdout, res.Plan)
+		return nil
+	}
+
+	fmt.Fprintf(app.Stdout, "Adopted %s, registered as repository '%s'\n", res.Workspace.Root, res.Workspace.Name)
+	fmt.Fprintf(app.Stdout, "Your checkout is now in %s\n", res.Worktree)
+	for _, path := range res.Added {
+		fmt.Fprintf(app.Stdout, "Added worktree %s\n", path)
+	}
+
+	// The shell's directory moved into the worktree
+	if rel, err := filepath.Rel(res.Workspace.Root, app.Dir); err == nil && !strings.HasPrefix(rel, "..") {
+		fmt.Fprintf(app.Stdout, "git-manager-eval:cd %q\n", filepath.Join(res.Worktree, rel))
+	}
+	return nil
+}
+
+func adoptClones(ctx context.Context, app *App, dir string, allBranches bool, dryRun bool) error {
+	clones, err := gitmanager.FindClones(dir)
+	if err != nil {
+		return err
+	}
+	if len(clones) == 0 {
+		fmt.Fprintf(app.Stdout, "No clones found under %s\n", dir)
+		return nil
+	}
+
+	failed := 0
+	for _, clone := range clones {
+		err := adoptClone(ctx, app, gitmanager.AdoptOptions{Dir: clone, AllBranches: allBranches, DryRun: dryRun})
+		if errs.Is(err, errs.Interrupted) || errs.Is(err, errs.Timeout) {
+			return err
+		}
+		if err != nil {
+			fmt.Fprintf(app.Stderr, "Skipped %s: %v\n", clone, err)
+			failed++
+		}
+	}
+
+	if dryRun {
+		fmt.Fprintf(app.Stdout, "\nWould adopt %d of %d clones\n", len(clones)-failed, len(clones))
+	} else {
+		fmt.Fprintf(app.Stdout, "\nAdopted %d of %d clones\n", len(clones)-failed, len(clones))
+	}
+	if failed > 0 {
+		return errs.New(errs.Unknown, "%d clone(s) could not be adopted", failed)
+	}
+	return nil
+}
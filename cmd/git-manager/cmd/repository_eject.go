@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/plan"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newEjectCmd returns the repository eject command
+func newEjectCmd(app *App) *cobra.Command {
+	var opts gitmanager.EjectOptions
+
+	ejectCmd := &cobra.Command{
+		Use:   "eject [name]",
+		Short: "Turn a workspace back into a normal clone",
+		Long: `Turn a workspace back into a normal clone.
+The workspace registered as name (default: the current workspace) becomes a
+single clone: the kept worktree's checkout, with its uncommitted changes, moves
+to the top of the workspace, and the bare repository becomes its .git
+directory. All other worktrees are removed. Branches and stashes are kept,
+remotes get the fetch refspec a normal clone has, and the workspace is
+unregistered. If anything fails, the workspace is restored.
+
+The kept worktree is chosen with --keep, by directory or branch name. By
+default it is the worktree on the repository's default branch.
+
+Other worktrees with uncommitted changes or ignored files, such as .env,
+stop the eject, as removing them would delete those files. --stash stashes
+everything in them first, ignored files included, with "git stash --all".`,
+		Args: usageArgs(cobra.MaximumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			if opts.Dest != "" {
+				opts.Dest = absPath(app, opts.Dest)
+			}
+			return ejectWorkspace(cmd.Context(), app, name, opts)
+		},
+	}
+
+	ejectCmd.Flags().StringVar(&opts.Keep, "keep", "", "Worktree that becomes the clone's checkout (default: the one on the default branch)")
+	ejectCmd.Flags().StringVar(&opts.Dest, "dest", "", "Directory to put the clone in (default: the workspace directory)")
+	ejectCmd.Flags().BoolVar(&opts.Stash, "stash", false, "Stash uncommitted changes and ignored files in the other worktrees instead of stopping")
+	ejectCmd.Flags().BoolVarP(&opts.DryRun, "dry-run", "n", false, "Print the steps that would be run, without changing anything")
+
+	return ejectCmd
+}
+
+func ejectWorkspace(ctx context.Context, app *App, name string, opts gitmanager.EjectOptions) error {
+	m := app.manager()
+
+	ws, err := m.Resolve(ctx, gitmanager.Target{Dir: app.Dir, Repo: name})
+	if err != nil {
+		return err
+	}
+
+	res, err := m.EjectRepository(ctx, ws, opts)
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		plan.PrintSteps(app.Stdout, res.Plan)
+		return nil
+	}
+
+	fmt.Fprintf(app.Stdout, "%s is now a normal clone", res.Path)
+	if res.Kept.Branch != "" {
+		fmt.Fprintf(app.Stdout, " on branch %s", res.Kept.Branch)
+	}
+	fmt.Fprintln(app.Stdout)
+	for _, wt := range res.Stashed {
+		fmt.Fprintf(app.Stdout, "Stashed the changes in %s, see `git stash list`\n", wt.Path)
+	}
+	if ws.Name != "" {
+		fmt.Fprintf(app.Stdout, "Unregistered repository '%s'\n", ws.Name)
+	}
+
+	// The shell's directory may be gone
+	if rel, err := filepath.Rel(ws.Root, app.Dir); err == nil && !strings.HasPrefix(rel, "..") {
+		fmt.Fprintf(app.Stdout, "git-manager-eval:cd %q\n", res.Path)
+	}
+	return nil
+}
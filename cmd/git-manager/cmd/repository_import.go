@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/internal/importer"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newImportCmd returns the repository import command
+func newImportCmd(app *App) *cobra.Command {
+	var (
+		from   string
+		dryRun bool
+	)
+
+	importCmd := &cobra.Command{
+		Use:   "import --from <source> <path>",
+		Short: "Import repositories tracked by ghq, myrepos or a repo manifest",
+		Long: `Import the repositories tracked by another checkout manager.
+The source is one of:
+  ghq            path is a ghq root, laid out as <host>/<owner>/<repo>
+  mr             path is a myrepos .mrconfig file
+  repo-manifest  path is an Android-style repo tool manifest
+
+Existing clones are adopted like "repository adopt" does, missing ones are
+cloned like "repository init" does, and existing workspaces are registered.
+Each repository is registered under its directory name, with as many parent
+directories in front as it takes to tell repositories of the same name apart,
+such as "a/api" and "b/api". Repositories that cannot be imported are
+reported and skipped.`,
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if from == "" {
+				return errs.New(errs.Usage, "--from is required, use one of: %s", strings.Join(importer.Sources, ", "))
+			}
+			return importRepositories(cmd.Context(), app, from, absPath(app, args[0]), dryRun)
+		},
+	}
+
+	importCmd.Flags().StringVar(&from, "from", "", "Where to import from: "+strings.Join(importer.Sources, ", "))
+	importCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Print the steps that would be run, without changing anything")
+
+	return importCmd
+}
+
+func importRepositories(ctx context.Context, app *App, from string, path string, dryRun bool) error {
+	results, err := app.manager().ImportRepositories(ctx, gitmanager.ImportOptions{
+		From:   from,
+		Path:   path,
+		DryRun: dryRun,
+	})
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Fprintf(app.Stdout, "No repositories found in %s\n", path)
+		return nil
+	}
+
+	if dryRun {
+		fmt.Fprintln(app.Stdout, "Dry run, nothing will be changed. Planned steps:")
+	}
+
+	failed := 0
+	for _, res := range results {
+		if res.Err != nil {
+			fmt.Fprintf(app.Stderr, "Skipped %s: %v\n", res.Path, res.Err)
+			failed++
+			continue
+		}
+		if dryRun {
+			fmt.Fprintf(app.Stdout, "  %s, to be %s:\n", res.Path, res.Action)
+			for _, step := range res.Plan {
+				fmt.Fprintf(app.Stdout, "    %s\n", step)
+			}
+			continue
+		}
+		fmt.Fprintf(app.Stdout, "%-10s %s (%s)\n", res.Action, res.Name, res.Path)
+	}
+
+	fmt.Fprintf(app.Stdout, "\nImported %d of %d repositories\n", len(results)-failed, len(results))
+	if failed > 0 {
+		return errs.New(errs.Unknown, "%d repositories could not be imported", failed)
+	}
+	return nil
+}
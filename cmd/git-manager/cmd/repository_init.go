@@ -1,76 +1,62 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
 
+	"github.com/ingshtrom/git-manager/internal/plan"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
 	"github.com/spf13/cobra"
 )
 
-var initCmd = &cobra.Command{
-	Use:   "init [repository-url]",
-	Short: "Initialize a new git repository with worktrees setup",
-	Long: `Initialize a new git repository with worktrees setup.
-This command will clone the repository and set up the initial worktree structure.`,
-	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		repoURL := args[0]
-		initWorkspace(repoURL)
-	},
-}
-
-func init() {
-	repositoryCmd.AddCommand(initCmd)
-}
-
-func initWorkspace(repoURL string) {
-	// Get current directory
-	currentDir, err := os.Getwd()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting current directory: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Extract repository name from URL
-	repoName := filepath.Base(repoURL)
-	if len(repoName) > 4 && repoName[len(repoName)-4:] == ".git" {
-		repoName = repoName[:len(repoName)-4]
+// newInitCmd returns the repository init command
+func newInitCmd(app *App) *cobra.Command {
+	var (
+		name   string
+		dryRun bool
+	)
+
+	initCmd := &cobra.Command{
+		Use:   "init [repository-url]",
+		Short: "Initialize a new git repository with worktrees setup",
+		Long: `Initialize a new git repository with worktrees setup.
+This command will clone the repository and set up the initial worktree structure.
+The new workspace is registered, so it can be targeted from anywhere with --repo.`,
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repoURL := args[0]
+			return initWorkspace(cmd.Context(), app, repoURL, name, dryRun)
+		},
 	}
 
-	// Create directory structure
-	repoDir := filepath.Join(currentDir, repoName)
-	mainDir := filepath.Join(repoDir, "main")
+	initCmd.Flags().StringVar(&name, "name", "", "Name of the workspace directory and registered repository (default: derived from the URL)")
+	initCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Print the directories and git commands that would be created and run, without changing anything")
 
-	// Create directories
-	if err := os.MkdirAll(mainDir, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating directories: %v\n", err)
-		os.Exit(1)
-	}
+	return initCmd
+}
 
-	// Clone the repository
-	fmt.Printf("Cloning repository %s...\n", repoURL)
-	cloneCmd := exec.Command("git", "clone", "--bare", repoURL, filepath.Join(repoDir, ".git"))
-	cloneCmd.Stdout = os.Stdout
-	cloneCmd.Stderr = os.Stderr
-	if err := cloneCmd.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error cloning repository: %v\n", err)
-		os.Exit(1)
+func initWorkspace(ctx context.Context, app *App, repoURL string, repoName string, dryRun bool) error {
+	res, err := app.manager().InitRepository(ctx, gitmanager.InitOptions{
+		URL:    repoURL,
+		Dir:    app.Dir,
+		Name:   repoName,
+		DryRun: dryRun,
+	})
+	if err != nil {
+		return err
 	}
-
-	// Create initial worktree
-	fmt.Println("Creating initial worktree...")
-	worktreeCmd := exec.Command("git", "-C", filepath.Join(repoDir, ".git"), "worktree", "add", mainDir)
-	worktreeCmd.Stdout = os.Stdout
-	worktreeCmd.Stderr = os.Stderr
-	if err := worktreeCmd.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating worktree: %v\n", err)
-		os.Exit(1)
+	if dryRun {
+		plan.PrintSteps(app.Stdout, res.Plan)
+		return nil
 	}
-
-	fmt.Printf("\nGit Manager workspace initialized successfully in %s\n", repoDir)
-	fmt.Printf("Main worktree created at %s\n", mainDir)
-	fmt.Println("\nYou can now cd into the main directory and start working:")
-	fmt.Printf("  cd %s\n", mainDir)
+	repoDir := res.Workspace.Root
+	repoName = res.Workspace.Name
+	mainDir := res.MainWorktree
+
+	fmt.Fprintf(app.Stdout, "\nGit Manager workspace initialized successfully in %s\n", repoDir)
+	fmt.Fprintf(app.Stdout, "Main worktree created at %s\n", mainDir)
+	fmt.Fprintf(app.Stdout, "Registered as repository '%s', use --repo %s to target it from anywhere\n", repoName, repoName)
+	fmt.Fprintln(app.Stdout, "\nYou can now cd into the main directory and start working:")
+	fmt.Fprintf(app.Stdout, "  cd %s\n", mainDir)
+	return nil
 }
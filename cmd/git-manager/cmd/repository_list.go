@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// newRepositoryListCmd returns the repository list command
+func newRepositoryListCmd(app *App) *cobra.Command {
+	return &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list", "l"},
+		Short:   "List registered repositories (list, l)",
+		Long: `List registered repositories.
+These are the names that can be passed to --repo.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listRepositories(app)
+		},
+	}
+}
+
+func listRepositories(app *App) error {
+	repos, err := app.manager().Repositories()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(app.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "NAME\tPATH")
+	for _, repo := range repos {
+		fmt.Fprintf(w, "%s\t%s\n", repo.Name, repo.Path)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/plan"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newMoveCmd returns the repository move command
+func newMoveCmd(app *App) *cobra.Command {
+	var (
+		relativePaths bool
+		dryRun        bool
+	)
+
+	moveCmd := &cobra.Command{
+		Use:     "move <dest>",
+		Aliases: []string{"mv"},
+		Short:   "Move a workspace and repair its worktree links",
+		Long: `Move a workspace to another directory.
+git records the absolute path of every worktree, so moving a workspace with mv
+breaks all of them. This command moves the workspace directory, runs
+"git worktree repair" for every worktree, including worktrees outside the
+workspace, and updates the registry. If anything fails, the workspace is moved
+back.
+
+With --relative-paths (git 2.48 or newer), the workspace switches to relative
+worktree paths, so it can later be moved with plain mv.`,
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dest := args[0]
+			if !filepath.IsAbs(dest) {
+				dest = filepath.Join(app.Dir, dest)
+			}
+			return moveWorkspace(cmd.Context(), app, dest, relativePaths, dryRun)
+		},
+	}
+
+	moveCmd.Flags().BoolVar(&relativePaths, "relative-paths", false, "Switch to relative worktree paths so later moves need no repair (git 2.48 or newer)")
+	moveCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Print the steps that would be run, without changing anything")
+
+	return moveCmd
+}
+
+func moveWorkspace(ctx context.Context, app *App, dest string, relativePaths bool, dryRun bool) error {
+	m := app.manager()
+
+	ws, err := app.workspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := m.MoveRepository(ctx, ws, gitmanager.MoveOptions{
+		Dest:          dest,
+		RelativePaths: relativePaths,
+		DryRun:        dryRun,
+	})
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		plan.PrintSteps(app.Stdout, res.Plan)
+		return nil
+	}
+
+	fmt.Fprintf(app.Stdout, "Moved workspace %s to %s\n", ws.Root, res.Workspace.Root)
+	if ws.Name != "" {
+		fmt.Fprintf(app.Stdout, "Repository '%s' now points to %s\n", ws.Name, res.Workspace.Root)
+	}
+
+	if !relativePaths {
+		if features, err := m.GitFeatures(ctx); err == nil && features.Has(gitmanager.CapRelativeWorktrees) {
+			fmt.Fprintf(app.Stdout, "\ngit %s supports relative worktree paths, which survive moves without repairs. To switch, run:\n", features.Version)
+			fmt.Fprintf(app.Stdout, "  git -C %s config worktree.useRelativePaths true\n", res.Workspace.GitDir)
+			fmt.Fprintf(app.Stdout, "  git -C %s worktree repair --relative-paths\n", res.Workspace.GitDir)
+		}
+	}
+
+	// Follow the move if the shell was inside the workspace
+	if rel, err := filepath.Rel(ws.Root, app.Dir); err == nil && !strings.HasPrefix(rel, "..") {
+		fmt.Fprintf(app.Stdout, "git-manager-eval:cd %q\n", filepath.Join(res.Workspace.Root, rel))
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// newRegisterCmd returns the repository register command
+func newRegisterCmd(app *App) *cobra.Command {
+	var name string
+
+	registerCmd := &cobra.Command{
+		Use:   "register [dir]",
+		Short: "Register an existing workspace so it can be targeted with --repo",
+		Long: `Register an existing git-manager workspace.
+The workspace containing dir (default: the current directory) is added to the
+repository registry, so other commands can target it from anywhere with --repo.
+
+Workspaces created with "repository init" are registered automatically.`,
+		Args: usageArgs(cobra.MaximumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := app.Dir
+			if len(args) == 1 {
+				dir = args[0]
+				if !filepath.IsAbs(dir) {
+					dir = filepath.Join(app.Dir, dir)
+				}
+			}
+			return registerWorkspace(app, dir, name)
+		},
+	}
+
+	registerCmd.Flags().StringVar(&name, "name", "", "Name to register the repository under (default: the workspace directory name)")
+
+	return registerCmd
+}
+
+func registerWorkspace(app *App, dir string, name string) error {
+	repo, err := app.manager().Register(dir, name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.Stdout, "Registered repository '%s' at %s\n", repo.Name, repo.Path)
+	return nil
+}
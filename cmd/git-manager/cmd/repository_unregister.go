@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// newUnregisterCmd returns the repository unregister command
+func newUnregisterCmd(app *App) *cobra.Command {
+	return &cobra.Command{
+		Use:   "unregister [name]",
+		Short: "Remove a repository from the registry",
+		Long: `Remove a repository from the registry.
+The workspace itself is left untouched on disk.`,
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return unregisterRepository(app, args[0])
+		},
+	}
+}
+
+func unregisterRepository(app *App, name string) error {
+	if err := app.manager().Unregister(name); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.Stdout, "Unregistered repository '%s'\n", name)
+	return nil
+}
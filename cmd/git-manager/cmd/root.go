@@ -1,40 +1,145 @@
 package cmd
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
 	"github.com/spf13/cobra"
 )
 
-var rootCmd = &cobra.Command{
-	Use:   "git-manager",
-	Short: "Git Manager - Manage git repositories using worktrees",
-	Long: `Git Manager is a CLI tool that helps you organize and manage 
+func init() {
+	// Run the root command's persistent hooks before those of subcommands,
+	// so global flags are applied before a subcommand checks the repository
+	cobra.EnableTraverseRunHooks = true
+}
+
+// NewRootCmd returns the git-manager command tree bound to app.
+func NewRootCmd(app *App) *cobra.Command {
+	var (
+		chdir    string
+		repoName string
+		verbose  bool
+		timeout  time.Duration
+	)
+
+	rootCmd := &cobra.Command{
+		Use:   "git-manager",
+		Short: "Git Manager - Manage git repositories using worktrees",
+		Long: `Git Manager is a CLI tool that helps you organize and manage 
 multiple git repositories using git worktrees. It simplifies the process 
 of working with multiple branches across repositories.`,
 
-	Run: func(cmd *cobra.Command, args []string) {
-		// If no subcommand is provided, print help
-		cmd.Help()
-	},
-}
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			app.setupTrace(verbose)
+			if timeout < 0 {
+				return errs.New(errs.Usage, "--timeout must not be negative")
+			}
+			app.Timeout = timeout
+			return selectTarget(app, chdir, repoName)
+		},
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// If no subcommand is provided, print help
+			return cmd.Help()
+		},
 
-// Execute adds all child commands to the root command and sets flags appropriately.
-// This is called by main.main(). It only needs to happen once to the rootCmd.
-func Execute() error {
-	return rootCmd.Execute()
-}
+		// Errors are printed by main, which also maps them to an exit code
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+
+	rootCmd.SetIn(app.Stdin)
+	rootCmd.SetOut(app.Stdout)
+	rootCmd.SetErr(app.Stderr)
 
-func init() {
 	// Disable the completion command
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 
-	// 	rootCmd.He
-	// Here you will define your flags and configuration settings.
-	// Cobra supports persistent flags, which, if defined here,
-	// will be global for your application.
+	// Report bad flags as usage errors so they get their own exit code
+	rootCmd.SetFlagErrorFunc(usageFlagError)
+
+	// Global flags that choose where a command runs
+	rootCmd.PersistentFlags().StringVarP(&chdir, "dir", "C", "", "Run as if git-manager was started in this directory")
+	rootCmd.PersistentFlags().StringVar(&repoName, "repo", "", "Run against the registered repository with this name")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Log every git command with its directory, duration, exit code and stderr (also: GIT_MANAGER_TRACE=1)")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Kill any git command that runs longer than this, e.g. 30s or 5m (default: the timeouts in the config file, if any)")
+
+	rootCmd.AddCommand(
+		newRepositoryCmd(app),
+		newWorktreeCmd(app),
+		newToolCmd(app),
+		newAddCmd(app),
+		newListCmd(app),
+		newStatusCmd(app),
+		newFetchCmd(app),
+		newUpdateCmd(app),
+		newConflictsCmd(app),
+		newOverlapCmd(app),
+		newExecCmd(app),
+		newGrepCmd(app),
+		newReportCmd(app),
+		newDiffCmd(app),
+		newCarryCmd(app),
+		newWorkspaceCmd(app),
+	)
+
+	return rootCmd
+}
+
+// Execute builds the command tree for the current process and runs it.
+// This is called by main.main().
+func Execute() error {
+	app, err := NewApp()
+	if err != nil {
+		return err
+	}
+
+	// Ctrl-C or SIGTERM cancels the command: running git processes are
+	// terminated and partial changes rolled back. A second signal kills
+	// git-manager right away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return NewRootCmd(app).ExecuteContext(ctx)
+}
+
+// selectTarget points the app at the directory chosen by the global -C and
+// --repo flags
+func selectTarget(app *App, chdir, repoName string) error {
+	if chdir != "" && repoName != "" {
+		return errs.New(errs.Usage, "-C and --repo cannot be used together")
+	}
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.git-manager.yaml)")
+	dir := app.Dir
+	switch {
+	case chdir != "":
+		if !filepath.IsAbs(chdir) {
+			chdir = filepath.Join(app.Dir, chdir)
+		}
+		dir = filepath.Clean(chdir)
+	case repoName != "":
+		repo, err := app.manager().Repository(repoName)
+		if err != nil {
+			return err
+		}
+		dir = repo.Path
+	default:
+		return nil
+	}
 
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	// rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return errs.New(errs.NotFound, "cannot run in %s: no such directory", dir)
+	}
+	app.Dir = dir
+	return nil
 }
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/internal/git"
+	"github.com/ingshtrom/git-manager/internal/testutil"
+)
+
+// TestChdirFlag tests running a command as if started in another directory
+func TestChdirFlag(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	elsewhere := t.TempDir()
+
+	// Absolute directory
+	if _, _, err := runCommand(t, elsewhere, "-C", ws.Root, "add", "--switch=false", "abs"); err != nil {
+		t.Fatalf("add with -C failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ws.Root, "abs")); err != nil {
+		t.Errorf("Expected worktree in the workspace: %v", err)
+	}
+
+	// Relative to the current directory
+	if _, _, err := runCommand(t, ws.TempDir, "-C", "workspace/main", "worktree", "add", "--switch=false", "rel"); err != nil {
+		t.Fatalf("add with relative -C failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ws.Root, "rel")); err != nil {
+		t.Errorf("Expected worktree in the workspace: %v", err)
+	}
+
+	_, _, err := runCommand(t, elsewhere, "-C", filepath.Join(elsewhere, "missing"), "list")
+	if !errs.Is(err, errs.NotFound) {
+		t.Errorf("Expected a not-found error, got %v", err)
+	}
+}
+
+// TestRepoFlag tests targeting a registered repository from anywhere
+func TestRepoFlag(t *testing.T) {
+	isolateConfig(t)
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	elsewhere := t.TempDir()
+
+	_, _, err := runCommand(t, elsewhere, "--repo", "api", "list")
+	if !errs.Is(err, errs.NotFound) {
+		t.Fatalf("Expected a not-found error for an unregistered repository, got %v", err)
+	}
+
+	if _, _, err := runCommand(t, ws.Worktree("main").Path, "repository", "register", "--name", "api"); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+
+	stdout, _, err := runCommand(t, elsewhere, "repository", "ls")
+	if err != nil {
+		t.Fatalf("repository ls failed: %v", err)
+	}
+	if !strings.Contains(stdout, "api") || !strings.Contains(stdout, ws.Root) {
+		t.Errorf("Expected api at %s in the registry, got:\n%s", ws.Root, stdout)
+	}
+
+	if _, _, err := runCommand(t, elsewhere, "--repo", "api", "add", "--switch=false", "fix-123"); err != nil {
+		t.Fatalf("add with --repo failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ws.Root, "fix-123")); err != nil {
+		t.Errorf("Expected worktree in the registered workspace: %v", err)
+	}
+
+	stdout, _, err = runCommand(t, elsewhere, "--repo", "api", "worktree", "ls")
+	if err != nil {
+		t.Fatalf("worktree ls with --repo failed: %v", err)
+	}
+	if !strings.Contains(stdout, "fix-123") {
+		t.Errorf("Expected fix-123 in the worktree list, got:\n%s", stdout)
+	}
+
+	_, _, err = runCommand(t, elsewhere, "--repo", "api", "-C", ws.Root, "list")
+	if !errs.Is(err, errs.Usage) {
+		t.Errorf("Expected a usage error for -C with --repo, got %v", err)
+	}
+
+	if _, _, err := runCommand(t, elsewhere, "repository", "unregister", "api"); err != nil {
+		t.Fatalf("unregister failed: %v", err)
+	}
+	_, _, err = runCommand(t, elsewhere, "--repo", "api", "list")
+	if !errs.Is(err, errs.NotFound) {
+		t.Errorf("Expected a not-found error after unregistering, got %v", err)
+	}
+}
+
+// TestInitRegisters tests that init registers the new workspace
+func TestInitRegisters(t *testing.T) {
+	isolateConfig(t)
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	if _, _, err := runCommand(t, dir, "repository", "init", "--name", "svc", ws.Origin.Path); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+
+	stdout, _, err := runCommand(t, t.TempDir(), "--repo", "svc", "list")
+	if err != nil {
+		t.Fatalf("list with --repo failed: %v", err)
+	}
+	if !strings.Contains(stdout, filepath.Join(dir, "svc", "main")) {
+		t.Errorf("Expected the svc main worktree, got:\n%s", stdout)
+	}
+
+	// The name is taken, so a second workspace needs another one
+	_, _, err = runCommand(t, t.TempDir(), "repository", "init", "--name", "svc", ws.Origin.Path)
+	if !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected a conflict error, got %v", err)
+	}
+}
+
+// TestVerbose tests tracing git invocations with -v and GIT_MANAGER_TRACE
+func TestVerbose(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	_, stderr, err := runCommand(t, ws.Root, "-v", "list")
+	if err != nil {
+		t.Fatalf("list -v failed: %v", err)
+	}
+	if !strings.Contains(stderr, "git-manager trace: $ git worktree list --porcelain") {
+		t.Errorf("Expected the worktree list call to be traced, got:\n%s", stderr)
+	}
+	if !strings.Contains(stderr, "git-manager trace:   dir: "+ws.Root+"\n") {
+		t.Errorf("Expected the working directory to be traced, got:\n%s", stderr)
+	}
+
+	_, stderr, err = runCommand(t, ws.Root, "list")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if strings.Contains(stderr, "git-manager trace:") {
+		t.Errorf("Expected no trace without -v, got:\n%s", stderr)
+	}
+
+	// GIT_MANAGER_TRACE can send the trace to a file
+	traceFile := filepath.Join(t.TempDir(), "trace.log")
+	t.Setenv("GIT_MANAGER_TRACE", traceFile)
+	if _, _, err := runCommand(t, ws.Root, "list"); err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	trace, err := os.ReadFile(traceFile)
+	if err != nil {
+		t.Fatalf("Expected a trace file: %v", err)
+	}
+	if !strings.Contains(string(trace), "$ git worktree list --porcelain") {
+		t.Errorf("Expected the trace file to contain the worktree list call, got:\n%s", trace)
+	}
+}
+
+// TestGitErrorsIncludeStderr tests that git's own message reaches the user
+func TestGitErrorsIncludeStderr(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	_, _, err := runCommand(t, ws.Root, "add", "--switch=false", "--base", "no-such-branch", "feature")
+	if !errs.Is(err, errs.GitFailure) {
+		t.Fatalf("Expected a git failure, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "fatal: not a valid object name: 'no-such-branch'") {
+		t.Errorf("Expected git's stderr in the error, got %q", err.Error())
+	}
+}
+
+// TestTimeoutRollsBack tests that --timeout kills a hanging git and that the
+// half-created worktree is rolled back
+func TestTimeoutRollsBack(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	fake := testutil.NewFakeRunner(t)
+	fake.Fallback = &git.ExecRunner{}
+	fake.On("worktree", "add").Hang()
+
+	stdout, _, err := runCommandWithGit(t, fake, ws.Root, "--timeout", "100ms", "add", "feature")
+	if errs.ExitCode(err) != errs.ExitTimeout {
+		t.Fatalf("Expected exit code %d, got %d (%v)", errs.ExitTimeout, errs.ExitCode(err), err)
+	}
+	if !strings.Contains(stdout, "Rolling back...") {
+		t.Errorf("Expected a rollback, got:\n%s", stdout)
+	}
+	if !fake.CalledWith("worktree", "prune") {
+		t.Error("Expected the rollback to prune the worktree entry")
+	}
+	if _, err := os.Stat(filepath.Join(ws.Root, "feature")); !os.IsNotExist(err) {
+		t.Errorf("Expected no worktree directory to be left behind, got %v", err)
+	}
+}
+
+// TestInterruptRollsBack tests that cancelling init removes the partial
+// workspace and reports an interruption
+func TestInterruptRollsBack(t *testing.T) {
+	isolateConfig(t)
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fake := testutil.NewFakeRunner(t)
+	fake.Fallback = &git.ExecRunner{}
+	fake.On("worktree", "add").Hang()
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	dir := t.TempDir()
+	app := &App{Stdin: strings.NewReader(""), Stdout: io.Discard, Stderr: io.Discard, Dir: dir, Env: os.Environ(), Now: time.Now, Git: fake}
+	root := NewRootCmd(app)
+	root.SetArgs([]string{"repository", "init", ws.Origin.Path})
+	err := root.ExecuteContext(ctx)
+
+	if errs.ExitCode(err) != errs.ExitInterrupted {
+		t.Fatalf("Expected exit code %d, got %d (%v)", errs.ExitInterrupted, errs.ExitCode(err), err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "origin")); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial workspace to be removed, got %v", err)
+	}
+	stdout, _, err := runCommand(t, dir, "repository", "ls")
+	if err != nil || strings.Contains(stdout, "origin") {
+		t.Errorf("Expected nothing to be registered, got %v:\n%s", err, stdout)
+	}
+}
+
+// TestManagerShared tests that the commands share one Manager, so what it
+// learns, such as the git version, is looked up once
+func TestManagerShared(t *testing.T) {
+	fake := testutil.GitVersion(t, "2.40.1")
+	app := &App{
+		Stdin:  strings.NewReader(""),
+		Stdout: io.Discard,
+		Stderr: io.Discard,
+		Env:    os.Environ(),
+		Git:    fake,
+	}
+
+	for range 2 {
+		if _, err := app.manager().GitFeatures(context.Background()); err != nil {
+			t.Fatalf("GitFeatures failed: %v", err)
+		}
+	}
+	if app.manager() != app.manager() {
+		t.Errorf("Expected manager to return the same Manager every time")
+	}
+	if n := len(fake.Calls()); n != 1 {
+		t.Errorf("Expected git --version to run once, got %d invocations", n)
+	}
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// newStatusCmd returns the status command
+func newStatusCmd(app *App) *cobra.Command {
+	return &cobra.Command{
+		Use:     "status",
+		Aliases: []string{"st"},
+		Short:   "Show the state of every worktree (st)",
+		Long: `Show the state of every worktree in the current git repository.
+For each worktree this prints its branch, whether it has uncommitted changes,
+and how far it is ahead of or behind its upstream.`,
+		Args:              usageArgs(cobra.NoArgs),
+		PersistentPreRunE: requireRepository(app),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showStatus(cmd.Context(), app)
+		},
+	}
+}
+
+func showStatus(ctx context.Context, app *App) error {
+	ws, err := app.workspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	statuses, err := app.manager().Status(ctx, ws)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(app.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "PATH\tBRANCH\tSTATE\tUPSTREAM")
+	for _, st := range statuses {
+		branchInfo := st.Branch
+		if branchInfo == "" {
+			branchInfo = "(detached)"
+		}
+
+		state := "clean"
+		switch {
+		case st.Missing:
+			state = "missing"
+		case st.Dirty():
+			state = fmt.Sprintf("dirty (%d changed)", st.Changes)
+		}
+
+		upstream := "-"
+		if st.Upstream != "" {
+			upstream = fmt.Sprintf("%s +%d -%d", st.Upstream, st.Ahead, st.Behind)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", st.Path, branchInfo, state, upstream)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newDoctorCmd returns the tool doctor command
+func newDoctorCmd(app *App) *cobra.Command {
+	var all, fix, yes, dryRun bool
+
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check git and your workspaces for problems",
+		Long: `Check git and your workspaces for problems.
+This command reports the installed git version and which of the git features
+git-manager can use are available with it. Features that need a newer git are
+listed with the version they require.
+
+It then checks the current workspace, or every registered one with --all or
+outside a workspace, for:
+  - worktrees whose links to the repository broke, e.g. after a move
+  - worktree entries whose directory was deleted (prunable)
+  - directories in the workspace that are not worktrees, such as the remains
+    of a failed add
+  - remotes of bare repositories without a fetch refspec
+
+With --fix, the problems are repaired. Directories are only removed after
+confirmation, or with --yes.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDoctor(cmd.Context(), app, doctorOptions{All: all, Fix: fix, Yes: yes, DryRun: dryRun})
+		},
+	}
+
+	doctorCmd.Flags().BoolVarP(&all, "all", "a", false, "Check every registered repository")
+	doctorCmd.Flags().BoolVar(&fix, "fix", false, "Repair the problems found")
+	doctorCmd.Flags().BoolVarP(&yes, "yes", "y", false, "Remove stray directories without asking")
+	doctorCmd.Flags().BoolVar(&dryRun, "dry-run", false, "With --fix, print the repairs without running them")
+
+	return doctorCmd
+}
+
+type doctorOptions struct {
+	All    bool
+	Fix    bool
+	Yes    bool
+	DryRun bool
+}
+
+func runDoctor(ctx context.Context, app *App, opts doctorOptions) error {
+	m := app.manager()
+
+	features, err := m.GitFeatures(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.Stdout, "git version %s\n\n", features.Version)
+
+	w := tabwriter.NewWriter(app.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "CAPABILITY\tSTATUS\tUSED FOR")
+	for _, c := range gitmanager.Capabilities() {
+		status := "available"
+		if !features.Has(c) {
+			status = fmt.Sprintf("requires git >= %s", c.Since)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Name, status, c.Description)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	workspaces, err := doctorWorkspaces(ctx, app, m, opts.All)
+	if err != nil {
+		return err
+	}
+
+	var confirm func(gitmanager.Problem) bool
+	if opts.Yes {
+		confirm = func(gitmanager.Problem) bool { return true }
+	} else {
+		in := bufio.NewReader(app.Stdin)
+		confirm = func(p gitmanager.Problem) bool {
+			return promptYesNo(in, app.Stdout, fmt.Sprintf("  Remove %s? [y/N] ", p.Path))
+		}
+	}
+
+	failed := 0
+	for _, ws := range workspaces {
+		fmt.Fprintln(app.Stdout)
+		if ws.Name != "" {
+			fmt.Fprintf(app.Stdout, "%s (%s)\n", ws.Name, ws.Root)
+		} else {
+			fmt.Fprintln(app.Stdout, ws.Root)
+		}
+
+		problems, err := m.Diagnose(ctx, ws)
+		if err != nil {
+			if errs.Is(err, errs.Interrupted) || errs.Is(err, errs.Timeout) {
+				return err
+			}
+			fmt.Fprintf(app.Stdout, "  error    cannot check workspace: %v\n", err)
+			failed++
+			continue
+		}
+		if len(problems) == 0 {
+			fmt.Fprintln(app.Stdout, "  no problems found")
+			continue
+		}
+
+		for _, p := range problems {
+			fmt.Fprintf(app.Stdout, "  %-7s  %s: %s\n", p.Severity, p.Code, p.Message)
+			if p.Fix != "" && !opts.Fix {
+				fmt.Fprintf(app.Stdout, "           fix: %s\n", p.Fix)
+			}
+		}
+
+		if !opts.Fix {
+			continue
+		}
+		if opts.DryRun {
+			fmt.Fprintln(app.Stdout, "  would run:")
+		}
+		results := m.Repair(ctx, problems, gitmanager.RepairOptions{Confirm: confirm, DryRun: opts.DryRun})
+		for _, r := range results {
+			switch {
+			case r.Err != nil:
+				fmt.Fprintf(app.Stdout, "  failed   %s: %v\n", r.Problem.Fix, r.Err)
+				failed++
+			case r.Fixed:
+				fmt.Fprintf(app.Stdout, "  fixed    %s\n", r.Problem.Message)
+			case r.Skipped && r.Problem.Fix != "":
+				fmt.Fprintf(app.Stdout, "  skipped  %s\n", r.Problem.Fix)
+			case opts.DryRun:
+				fmt.Fprintf(app.Stdout, "    %s\n", r.Problem.Fix)
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap(errs.Interrupted, err, "doctor was interrupted")
+	}
+	if failed > 0 {
+		return errs.New(errs.Unknown, "%d check(s) or repair(s) failed", failed)
+	}
+	return nil
+}
+
+// doctorWorkspaces returns the workspaces to check: the current one, or
+// every registered one with all or outside a workspace
+func doctorWorkspaces(ctx context.Context, app *App, m *gitmanager.Manager, all bool) ([]*gitmanager.Workspace, error) {
+	if !all {
+		ws, err := m.Resolve(ctx, gitmanager.Target{Dir: app.Dir})
+		if err == nil {
+			return []*gitmanager.Workspace{ws}, nil
+		}
+		if !errs.Is(err, errs.NotInRepo) {
+			return nil, err
+		}
+	}
+
+	return app.registeredWorkspaces(ctx, m)
+}
+
+// promptYesNo writes question to w and reports whether the answer read from
+// in is yes. No answer, e.g. when stdin is not a terminal, means no.
+func promptYesNo(in *bufio.Reader, w io.Writer, question string) bool {
+	fmt.Fprint(w, question)
+	answer, err := in.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(w)
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
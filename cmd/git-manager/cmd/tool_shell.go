@@ -2,34 +2,33 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
+	"github.com/ingshtrom/git-manager/internal/errs"
 	"github.com/spf13/cobra"
 )
 
-var shellCmd = &cobra.Command{
-	Use:   "shell [shell-type]",
-	Short: "Generate shell integration scripts",
-	Long: `Generate shell integration scripts for different shells.
+// newShellCmd returns the tool shell command
+func newShellCmd(app *App) *cobra.Command {
+	return &cobra.Command{
+		Use:   "shell [shell-type]",
+		Short: "Generate shell integration scripts",
+		Long: `Generate shell integration scripts for different shells.
 This command outputs shell functions that can be added to your shell configuration
 to enable directory switching and other advanced features.
 
 Supported shell types: sh, bash, zsh, fish, nushell`,
-	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		shellType := args[0]
-		generateShellIntegration(shellType)
-	},
-}
-
-func init() {
-	toolCmd.AddCommand(shellCmd)
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			shellType := args[0]
+			return generateShellIntegration(app, shellType)
+		},
+	}
 }
 
-func generateShellIntegration(shellType string) {
+func generateShellIntegration(app *App, shellType string) error {
 	switch shellType {
 	case "sh", "bash", "zsh":
-		fmt.Println(`# Git Manager Shell Integration
+		fmt.Fprintln(app.Stdout, `# Git Manager Shell Integration
 # Add this to your .bashrc, .zshrc, or .profile file
 
 # Main wrapper function for git-manager
@@ -89,7 +88,7 @@ if command -v git-manager &> /dev/null; then
 fi`)
 
 	case "fish":
-		fmt.Println(`# Git Manager Shell Integration for Fish
+		fmt.Fprintln(app.Stdout, `# Git Manager Shell Integration for Fish
 # Save this to ~/.config/fish/functions/git-manager.fish
 
 function git-manager
@@ -127,7 +126,7 @@ complete -c gm -f -n "__fish_use_subcommand" -a "init create list switch remove
 complete -c gm -f -n "__fish_seen_subcommand_from switch remove" -a "(command git-manager list | grep -v 'Available worktrees' | awk '{print \$1}')" -d "Worktree"`)
 
 	case "nushell":
-		fmt.Println(`# Git Manager Shell Integration for Nushell
+		fmt.Fprintln(app.Stdout, `# Git Manager Shell Integration for Nushell
 # Save this to your Nushell config file
 
 def git-manager [...args] {
@@ -186,22 +185,21 @@ export extern "git-manager remove" [
 ]`)
 
 	default:
-		fmt.Fprintf(os.Stderr, "Unsupported shell type: %s\n", shellType)
-		fmt.Println("Supported shell types: sh, bash, zsh, fish, nushell")
-		os.Exit(1)
+		return errs.New(errs.Usage, "unsupported shell type: %s (supported shell types: sh, bash, zsh, fish, nushell)", shellType)
 	}
 
-	fmt.Println("\n# To install, run:")
+	fmt.Fprintln(app.Stdout, "\n# To install, run:")
 	switch shellType {
 	case "sh":
-		fmt.Println("# echo 'source <(git-manager shell sh)' >> ~/.profile")
+		fmt.Fprintln(app.Stdout, "# echo 'source <(git-manager shell sh)' >> ~/.profile")
 	case "bash":
-		fmt.Println("# echo 'source <(git-manager shell bash)' >> ~/.bashrc")
+		fmt.Fprintln(app.Stdout, "# echo 'source <(git-manager shell bash)' >> ~/.bashrc")
 	case "zsh":
-		fmt.Println("# echo 'source <(git-manager shell zsh)' >> ~/.zshrc")
+		fmt.Fprintln(app.Stdout, "# echo 'source <(git-manager shell zsh)' >> ~/.zshrc")
 	case "fish":
-		fmt.Println("# git-manager shell fish > ~/.config/fish/functions/git-manager.fish")
+		fmt.Fprintln(app.Stdout, "# git-manager shell fish > ~/.config/fish/functions/git-manager.fish")
 	case "nushell":
-		fmt.Println("# git-manager shell nushell >> ~/.config/nushell/config.nu")
+		fmt.Fprintln(app.Stdout, "# git-manager shell nushell >> ~/.config/nushell/config.nu")
 	}
+	return nil
 }
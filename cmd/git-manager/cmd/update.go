@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newUpdateCmd returns the update command
+func newUpdateCmd(app *App) *cobra.Command {
+	var (
+		fetch bool
+		opts  gitmanager.UpdateOptions
+	)
+
+	updateCmd := &cobra.Command{
+		Use:     "update",
+		Aliases: []string{"up"},
+		Short:   "Bring every worktree up to date (up)",
+		Long: `Bring every worktree of the current repository up to date.
+Each clean worktree is fast-forwarded to the upstream of its branch, or to
+origin/<branch> when no upstream is set, as in workspaces cloned by
+git-manager. Branches that diverged from their upstream are left alone.
+
+With --rebase, the default branch is fast-forwarded first and the worktrees
+of all other branches are rebased onto it. A rebase that conflicts is aborted,
+so the worktree is left exactly as it was, and the conflicting files are
+reported.
+
+Worktrees with uncommitted changes are skipped, unless --autostash is given.
+Use --fetch to fetch the remotes first.`,
+		Args:              usageArgs(cobra.NoArgs),
+		PersistentPreRunE: requireRepository(app),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return updateWorktrees(cmd.Context(), app, fetch, opts)
+		},
+	}
+
+	updateCmd.Flags().BoolVarP(&fetch, "fetch", "f", false, "Fetch every remote before updating")
+	updateCmd.Flags().BoolVarP(&opts.Rebase, "rebase", "r", false, "Rebase the other branches onto the default branch")
+	updateCmd.Flags().BoolVar(&opts.Autostash, "autostash", false, "Stash uncommitted changes before updating and apply them again afterwards")
+
+	return updateCmd
+}
+
+func updateWorktrees(ctx context.Context, app *App, fetch bool, opts gitmanager.UpdateOptions) error {
+	m := app.manager()
+	ws, err := app.workspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	if fetch {
+		fmt.Fprintln(app.Stdout, "Fetching...")
+		if res := m.Fetch(ctx, []*gitmanager.Workspace{ws}, gitmanager.FetchOptions{}); res[0].Err != nil {
+			return res[0].Err
+		}
+	}
+
+	results, err := m.Update(ctx, ws, opts)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(app.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tBRANCH\tRESULT\tDETAILS")
+	failed, conflicts := 0, 0
+	for _, res := range results {
+		branch := res.Worktree.Branch
+		if branch == "" {
+			branch = "(detached)"
+		}
+
+		details := ""
+		switch res.State {
+		case gitmanager.UpdateUpdated:
+			details = fmt.Sprintf("%d commit(s) from %s", res.Commits, res.Onto)
+		case gitmanager.UpdateUpToDate:
+			details = res.Onto
+		case gitmanager.UpdateDirty:
+			details = "uncommitted changes, use --autostash"
+		case gitmanager.UpdateSkipped:
+			details = res.Reason
+		case gitmanager.UpdateConflict:
+			details = fmt.Sprintf("rebase aborted, %s conflict(s) with %s", strings.Join(res.Conflicts, ", "), res.Onto)
+			conflicts++
+		case gitmanager.UpdateFailed:
+			details = res.Err.Error()
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", res.Worktree.Path, branch, res.State, details)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	switch {
+	case failed > 0:
+		return errs.New(errs.Unknown, "%d worktree(s) could not be updated", failed)
+	case conflicts > 0:
+		return errs.New(errs.Conflict, "%d worktree(s) would conflict and were left unchanged", conflicts)
+	}
+	return nil
+}
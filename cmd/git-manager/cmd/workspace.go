@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// newWorkspaceCmd returns the workspace command
+func newWorkspaceCmd(app *App) *cobra.Command {
+	workspaceCmd := &cobra.Command{
+		Use:     "workspace",
+		Aliases: []string{"ws"},
+		Short:   "Share your whole setup as a manifest (ws)",
+		Long: `Share your whole setup as a manifest.
+
+A manifest is a YAML file listing your registered repositories, their remotes
+and default branches, and optionally their worktrees. Export it on one machine
+and apply it on another to get the same setup.`,
+	}
+
+	workspaceCmd.AddCommand(
+		newWorkspaceExportCmd(app),
+		newWorkspaceApplyCmd(app),
+	)
+
+	return workspaceCmd
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newWorkspaceApplyCmd returns the workspace apply command
+func newWorkspaceApplyCmd(app *App) *cobra.Command {
+	var dryRun bool
+
+	applyCmd := &cobra.Command{
+		Use:   "apply <manifest>",
+		Short: "Recreate the repositories and worktrees of a manifest",
+		Long: `Recreate the repositories and worktrees of a manifest.
+Repositories that are missing are cloned from their first remote and
+registered, and missing remotes and worktrees are added. Applying the same
+manifest again changes nothing.
+
+Differences that may be intended, such as a remote with another URL or a
+worktree the manifest does not list, are reported as drift and left alone.
+Use "-" to read the manifest from stdin.`,
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := args[0]
+			if path != "-" {
+				path = absPath(app, path)
+			}
+			return applyManifest(cmd.Context(), app, path, dryRun)
+		},
+	}
+
+	applyCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Print what would change, without changing anything")
+
+	return applyCmd
+}
+
+func applyManifest(ctx context.Context, app *App, path string, dryRun bool) error {
+	var r io.Reader = app.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("error reading manifest: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	man, err := gitmanager.ReadManifest(r)
+	if err != nil {
+		return err
+	}
+
+	results, err := app.manager().ApplyManifest(ctx, man, gitmanager.ApplyOptions{DryRun: dryRun})
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Fprintln(app.Stdout, "Dry run, nothing will be changed.")
+	}
+
+	failed := 0
+	for _, res := range results {
+		fmt.Fprintf(app.Stdout, "%s (%s)\n", res.Name, res.Path)
+		switch {
+		case res.Err != nil:
+			fmt.Fprintf(app.Stdout, "  error: %v\n", res.Err)
+			failed++
+		case len(res.Actions) == 0 && len(res.Drift) == 0:
+			fmt.Fprintln(app.Stdout, "  up to date")
+		}
+		for _, action := range res.Actions {
+			fmt.Fprintf(app.Stdout, "  %s\n", action)
+		}
+		for _, drift := range res.Drift {
+			fmt.Fprintf(app.Stdout, "  drift: %s\n", drift)
+		}
+	}
+
+	if failed > 0 {
+		return errs.New(errs.Unknown, "%d of %d repositories could not be applied", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+	"github.com/spf13/cobra"
+)
+
+// newWorkspaceExportCmd returns the workspace export command
+func newWorkspaceExportCmd(app *App) *cobra.Command {
+	var (
+		worktrees bool
+		output    string
+	)
+
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write a manifest of every registered repository",
+		Long: `Write a manifest of every registered repository.
+The manifest records each repository's name, path, remotes and default branch.
+With --worktrees, it also records the active worktrees and their branches.
+Paths in your home directory are written relative to ~, so the manifest works
+for other users.
+
+The manifest is written to stdout, or to the file given with --output.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "" {
+				output = absPath(app, output)
+			}
+			return exportManifest(cmd.Context(), app, worktrees, output)
+		},
+	}
+
+	exportCmd.Flags().BoolVarP(&worktrees, "worktrees", "w", false, "Also record the active worktrees and their branches")
+	exportCmd.Flags().StringVarP(&output, "output", "o", "", "File to write the manifest to (default: stdout)")
+
+	return exportCmd
+}
+
+func exportManifest(ctx context.Context, app *App, worktrees bool, output string) error {
+	man, err := app.manager().ExportManifest(ctx, gitmanager.ExportOptions{Worktrees: worktrees})
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		return gitmanager.WriteManifest(app.Stdout, man)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("error creating manifest: %w", err)
+	}
+	if err := gitmanager.WriteManifest(f, man); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+	fmt.Fprintf(app.Stdout, "Wrote %d repositories to %s\n", len(man.Repositories), output)
+	return nil
+}
@@ -1,45 +1,40 @@
 package cmd
 
 import (
-	"fmt"
-	"os"
-
-	"github.com/ingshtrom/git-manager/internal/worktree"
+	"github.com/ingshtrom/git-manager/internal/errs"
 	"github.com/spf13/cobra"
 )
 
-// worktreeCmd represents the worktree command
-var worktreeCmd = &cobra.Command{
-	Use:     "worktree",
-	Aliases: []string{"wt", "w"},
-	Short:   "Manage git worktrees (wt, w)",
-	Long: `Manage git worktrees.
+// newWorktreeCmd returns the worktree command
+func newWorktreeCmd(app *App) *cobra.Command {
+	worktreeCmd := &cobra.Command{
+		Use:     "worktree",
+		Aliases: []string{"wt", "w"},
+		Short:   "Manage git worktrees (wt, w)",
+		Long: `Manage git worktrees.
 
 This command allows you to manage your git worktrees.
 You can create, list, switch, and remove worktrees.`,
-	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		dir, err := os.Getwd()
-		if err != nil {
-			return fmt.Errorf("failed to determine your current directory. Please ensure you have permissions to access this directory and try again: %v", err)
-		}
-
-		if !worktree.IsGitRepository(dir) {
-			return fmt.Errorf("this command must be run from within a git repository. Please navigate to a git repository and try again")
-		}
-		return nil
-	},
-}
-
-func init() {
-	rootCmd.AddCommand(worktreeCmd)
+		PersistentPreRunE: requireRepository(app),
+	}
 
-	// Here you will define your flags and configuration settings.
+	worktreeCmd.AddCommand(
+		newWorktreeAddCmd(app),
+		newWorktreeListCmd(app),
+		newRemoveCmd(app),
+		newSwitchCmd(app),
+	)
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// worktreeCmd.PersistentFlags().String("foo", "", "A help for foo")
+	return worktreeCmd
+}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// worktreeCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+// requireRepository returns a pre-run hook that fails unless the app's
+// directory is inside a git repository
+func requireRepository(app *App) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if !app.manager().IsRepository(cmd.Context(), app.Dir) {
+			return errs.New(errs.NotInRepo, "this command must be run from within a git repository. Please navigate to a git repository and try again")
+		}
+		return nil
+	}
 }
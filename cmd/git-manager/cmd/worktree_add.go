@@ -1,108 +1,114 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
 
-	"github.com/ingshtrom/git-manager/internal/worktree"
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/internal/plan"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
 	"github.com/spf13/cobra"
 )
 
-var (
-	createBranch      bool
-	baseBranch        string
-	switchAfterCreate bool
-)
-
-var worktreeAddCmd = &cobra.Command{
-	Use:   "add [branch-name]",
-	Short: "Add a new worktree",
-	Long: `Add a new worktree in the current git repository.
+// newWorktreeAddCmd returns the worktree add command
+func newWorktreeAddCmd(app *App) *cobra.Command {
+	var (
+		createBranch      bool
+		baseBranch        string
+		switchAfterCreate bool
+		orphan            bool
+		dryRun            bool
+		carry             bool
+		untracked         bool
+		fromStash         int
+	)
+
+	worktreeAddCmd := &cobra.Command{
+		Use:   "add [branch-name]",
+		Short: "Add a new worktree",
+		Long: `Add a new worktree in the current git repository.
 This command will add a new worktree with the specified branch name.
 
-When used with shell integration, it can automatically change the directory to the new worktree.`,
-	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		branchName := args[0]
-		createWorktree(branchName, createBranch, baseBranch, switchAfterCreate)
-	},
-}
-
-func init() {
-	worktreeCmd.AddCommand(worktreeAddCmd)
+When used with shell integration, it can automatically change the directory to the new worktree.
+
+With --carry the uncommitted changes of the current worktree move into the new
+one, and with --from-stash the given stash entry does, see "git-manager carry".
+If they do not apply cleanly, the worktree is not created.`,
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			branchName := args[0]
+			var carryOpts *gitmanager.CarryOptions
+			switch {
+			case carry && cmd.Flags().Changed("from-stash"):
+				return errs.New(errs.Usage, "--carry and --from-stash cannot be used together")
+			case untracked && !carry:
+				return errs.New(errs.Usage, "--untracked needs --carry")
+			case carry:
+				carryOpts = &gitmanager.CarryOptions{Source: app.Dir, Untracked: untracked}
+			case cmd.Flags().Changed("from-stash"):
+				carryOpts = &gitmanager.CarryOptions{FromStash: true, Stash: fromStash}
+			}
+			return createWorktree(cmd.Context(), app, branchName, createBranch, baseBranch, orphan, switchAfterCreate, dryRun, carryOpts)
+		},
+	}
 
 	// Add flags
 	worktreeAddCmd.Flags().BoolVarP(&createBranch, "create-branch", "b", true, "Create a new branch for the worktree")
 	worktreeAddCmd.Flags().StringVarP(&baseBranch, "base", "", "main", "Base branch to create the new branch from (used with --create-branch)")
 	worktreeAddCmd.Flags().BoolVarP(&switchAfterCreate, "switch", "s", true, "Switch to the new worktree after creation")
+	worktreeAddCmd.Flags().BoolVar(&orphan, "orphan", false, "Create the new branch without any history (requires git >= 2.42)")
+	worktreeAddCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Print the git command that would create the worktree, without changing anything")
+	worktreeAddCmd.Flags().BoolVar(&carry, "carry", false, "Move the uncommitted changes of the current worktree into the new one")
+	worktreeAddCmd.Flags().BoolVarP(&untracked, "untracked", "u", false, "Also move untracked files (used with --carry)")
+	worktreeAddCmd.Flags().IntVar(&fromStash, "from-stash", 0, "Move the changes of stash entry n into the new worktree")
+
+	return worktreeAddCmd
 }
 
-func createWorktree(branchName string, createBranch bool, baseBranch string, switchAfterCreate bool) {
-	// Get current directory
-	currentDir, err := os.Getwd()
+func createWorktree(ctx context.Context, app *App, branchName string, createBranch bool, baseBranch string, orphan bool, switchAfterCreate bool, dryRun bool, carry *gitmanager.CarryOptions) error {
+	ws, err := app.workspace(ctx)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting current directory: %v\n", err)
-		os.Exit(1)
+		return err
 	}
 
-	// Find the git directory
-	gitDir, err := worktree.FindGitDir(currentDir)
+	res, err := app.manager().AddWorktree(ctx, ws, gitmanager.AddOptions{
+		Branch:       branchName,
+		CreateBranch: createBranch,
+		Base:         baseBranch,
+		Orphan:       orphan,
+		Carry:        carry,
+		DryRun:       dryRun,
+	})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Get the parent directory of the git directory
-	parentDir := filepath.Dir(gitDir)
-
-	// Create the worktree path
-	worktreePath := filepath.Join(parentDir, branchName)
-
-	// Check if the directory already exists
-	if _, err := os.Stat(worktreePath); err == nil {
-		fmt.Fprintf(os.Stderr, "Error: Directory %s already exists\n", worktreePath)
-		os.Exit(1)
+		return err
 	}
-
-	var cmd *exec.Cmd
-
-	if createBranch {
-		// Create a new branch and worktree
-		fmt.Printf("Creating new branch '%s' based on '%s' and adding worktree...\n", branchName, baseBranch)
-		cmd = exec.Command("git", "-C", gitDir, "worktree", "add", "-b", branchName, worktreePath, baseBranch)
-	} else {
-		// Add worktree for existing branch
-		fmt.Printf("Adding worktree for branch '%s'...\n", branchName)
-		cmd = exec.Command("git", "-C", gitDir, "worktree", "add", worktreePath, branchName)
+	if dryRun {
+		plan.PrintSteps(app.Stdout, res.Plan)
+		return nil
 	}
+	worktreePath := res.Path
 
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating worktree: %v\n", err)
-		os.Exit(1)
+	fmt.Fprintf(app.Stdout, "\nWorktree created successfully at %s\n", worktreePath)
+	if carry != nil {
+		fmt.Fprintf(app.Stdout, "Carried changes to %s\n", plural(len(res.Carried), "file"))
 	}
 
-	fmt.Printf("\nWorktree created successfully at %s\n", worktreePath)
-
 	if switchAfterCreate {
 		// Output the special command for shell integration to evaluate
 		// This will be captured by the shell wrapper and executed
-		fmt.Printf("git-manager-eval:cd %q\n", worktreePath)
+		fmt.Fprintf(app.Stdout, "git-manager-eval:cd %q\n", worktreePath)
 	}
 
 	// Print instructions for users without shell integration
-	fmt.Println("\nIf you're not using shell integration, run:")
-	fmt.Printf("  cd %s\n", worktreePath)
+	fmt.Fprintln(app.Stdout, "\nIf you're not using shell integration, run:")
+	fmt.Fprintf(app.Stdout, "  cd %s\n", worktreePath)
 
 	if !switchAfterCreate {
-		fmt.Println("\nTo automatically switch to new worktrees, use the --switch flag:")
-		fmt.Printf("  git-manager create --switch %s\n", branchName)
+		fmt.Fprintln(app.Stdout, "\nTo automatically switch to new worktrees, use the --switch flag:")
+		fmt.Fprintf(app.Stdout, "  git-manager create --switch %s\n", branchName)
 	}
 
-	fmt.Println("\nTo enable shell integration, run:")
-	fmt.Println("  git-manager shell [your-shell]")
+	fmt.Fprintln(app.Stdout, "\nTo enable shell integration, run:")
+	fmt.Fprintln(app.Stdout, "  git-manager shell [your-shell]")
+	return nil
 }
@@ -1,46 +1,41 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"os"
 	"text/tabwriter"
 
-	"github.com/ingshtrom/git-manager/internal/worktree"
 	"github.com/spf13/cobra"
 )
 
-var worktreeListCmd = &cobra.Command{
-	Use:     "ls",
-	Aliases: []string{"list", "l"},
-	Short:   "List all worktrees in the current git repository (list, l)",
-	Long: `List all worktrees in the current git repository.
+// newWorktreeListCmd returns the worktree list command
+func newWorktreeListCmd(app *App) *cobra.Command {
+	return &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list", "l"},
+		Short:   "List all worktrees in the current git repository (list, l)",
+		Long: `List all worktrees in the current git repository.
 This command will display all worktrees, their paths, and their current branch.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		listWorktrees()
-	},
-}
-
-func init() {
-	worktreeCmd.AddCommand(worktreeListCmd)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listWorktrees(cmd.Context(), app)
+		},
+	}
 }
 
-func listWorktrees() {
-	// Get current directory
-	currentDir, err := os.Getwd()
+func listWorktrees(ctx context.Context, app *App) error {
+	ws, err := app.workspace(ctx)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting current directory: %v\n", err)
-		os.Exit(1)
+		return err
 	}
 
 	// Get worktree information
-	worktrees, err := worktree.GetWorktreeInfo(currentDir)
+	worktrees, err := app.manager().ListWorktrees(ctx, ws)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return err
 	}
 
 	// Print worktree information in a tabular format
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	w := tabwriter.NewWriter(app.Stdout, 0, 0, 2, ' ', 0)
 
 	fmt.Fprintln(w, "PATH\tBRANCH\tCOMMIT")
 	for _, wt := range worktrees {
@@ -54,5 +49,5 @@ func listWorktrees() {
 			fmt.Fprintf(w, "%s\t%s\t%s\n", wt.Path, branchInfo, wt.Commit[:7])
 		}
 	}
-	w.Flush()
+	return w.Flush()
 }
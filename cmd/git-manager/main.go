@@ -5,11 +5,12 @@ import (
 	"os"
 
 	"github.com/ingshtrom/git-manager/cmd/git-manager/cmd"
+	"github.com/ingshtrom/git-manager/internal/errs"
 )
 
 func main() {
 	if err := cmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(errs.ExitCode(err))
 	}
 }
@@ -0,0 +1,172 @@
+// Package config loads and saves git-manager's user configuration, including
+// the registry of repositories that commands can target with --repo.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+)
+
+// Repository is a registered git-manager workspace
+type Repository struct {
+	// Name is the name used with --repo
+	Name string `json:"name"`
+
+	// Path is the absolute path of the workspace directory, the parent of
+	// its .git directory
+	Path string `json:"path"`
+}
+
+// Config is the user configuration, stored as JSON
+type Config struct {
+	Repositories []Repository `json:"repositories,omitempty"`
+
+	// Backend picks how read-only queries such as listing worktrees are
+	// answered: "cli" runs git, "native" reads the repository files
+	// directly. Empty means "cli".
+	Backend string `json:"backend,omitempty"`
+
+	// Timeouts limits how long git commands may run, keyed by git
+	// subcommand such as "clone" or "fetch", with "default" for all others.
+	// Values are Go durations like "90s" or "10m". Commands without a
+	// timeout run until they finish.
+	Timeouts map[string]string `json:"timeouts,omitempty"`
+}
+
+// Path returns the location of the configuration file. GIT_MANAGER_CONFIG
+// wins, then $XDG_CONFIG_HOME/git-manager/config.json, then
+// $HOME/.config/git-manager/config.json.
+func Path(getenv func(string) string) (string, error) {
+	if path := getenv("GIT_MANAGER_CONFIG"); path != "" {
+		return path, nil
+	}
+	if dir := getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "git-manager", "config.json"), nil
+	}
+	if home := getenv("HOME"); home != "" {
+		return filepath.Join(home, ".config", "git-manager", "config.json"), nil
+	}
+	return "", fmt.Errorf("cannot locate the git-manager config file: neither GIT_MANAGER_CONFIG, XDG_CONFIG_HOME nor HOME is set")
+}
+
+// Load reads the configuration at path. A missing file is an empty
+// configuration.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	for subcommand := range cfg.Timeouts {
+		if _, err := cfg.Timeout(subcommand); err != nil {
+			return nil, err
+		}
+	}
+	return &cfg, nil
+}
+
+// Timeout returns the configured timeout for the git subcommand, or zero if
+// there is none
+func (c *Config) Timeout(subcommand string) (time.Duration, error) {
+	value, ok := c.Timeouts[subcommand]
+	if !ok {
+		subcommand = "default"
+		value = c.Timeouts[subcommand]
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, errs.New(errs.Usage, "invalid timeout %q for %q in the config file, use a duration like \"90s\" or \"10m\"", value, subcommand)
+	}
+	return d, nil
+}
+
+// Save writes the configuration to path, creating its directory if needed.
+// The file is replaced atomically so a failed write never truncates it.
+func (c *Config) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+	data = append(data, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating config directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.json")
+	if err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	return nil
+}
+
+// Repository returns the registered repository called name
+func (c *Config) Repository(name string) (Repository, error) {
+	for _, repo := range c.Repositories {
+		if repo.Name == name {
+			return repo, nil
+		}
+	}
+	return Repository{}, errs.New(errs.NotFound, "no repository named %q is registered", name)
+}
+
+// AddRepository registers repo. Registering the same name and path again is
+// a no-op; reusing a name for a different path is a conflict.
+func (c *Config) AddRepository(repo Repository) error {
+	for _, existing := range c.Repositories {
+		if existing.Name != repo.Name {
+			continue
+		}
+		if existing.Path == repo.Path {
+			return nil
+		}
+		return errs.New(errs.Conflict, "a repository named %q is already registered at %s", repo.Name, existing.Path)
+	}
+
+	c.Repositories = append(c.Repositories, repo)
+	sort.Slice(c.Repositories, func(i, j int) bool {
+		return c.Repositories[i].Name < c.Repositories[j].Name
+	})
+	return nil
+}
+
+// RemoveRepository unregisters the repository called name
+func (c *Config) RemoveRepository(name string) error {
+	for i, repo := range c.Repositories {
+		if repo.Name == name {
+			c.Repositories = append(c.Repositories[:i], c.Repositories[i+1:]...)
+			return nil
+		}
+	}
+	return errs.New(errs.NotFound, "no repository named %q is registered", name)
+}
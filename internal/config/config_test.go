@@ -0,0 +1,130 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+)
+
+// TestPath tests the lookup order of the config file location
+func TestPath(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"explicit", map[string]string{"GIT_MANAGER_CONFIG": "/etc/gm.json", "HOME": "/home/me"}, "/etc/gm.json"},
+		{"xdg", map[string]string{"XDG_CONFIG_HOME": "/xdg", "HOME": "/home/me"}, "/xdg/git-manager/config.json"},
+		{"home", map[string]string{"HOME": "/home/me"}, "/home/me/.config/git-manager/config.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Path(func(key string) string { return tt.env[key] })
+			if err != nil {
+				t.Fatalf("Path failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := Path(func(string) string { return "" }); err == nil {
+		t.Errorf("Expected an error without any environment")
+	}
+}
+
+// TestRegistry tests registering, saving, loading and removing repositories
+func TestRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "config.json")
+
+	// A missing file is an empty configuration
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.Repositories) != 0 {
+		t.Fatalf("Expected no repositories, got %v", cfg.Repositories)
+	}
+
+	if err := cfg.AddRepository(Repository{Name: "web", Path: "/src/web"}); err != nil {
+		t.Fatalf("AddRepository failed: %v", err)
+	}
+	if err := cfg.AddRepository(Repository{Name: "api", Path: "/src/api"}); err != nil {
+		t.Fatalf("AddRepository failed: %v", err)
+	}
+	if err := cfg.AddRepository(Repository{Name: "api", Path: "/src/api"}); err != nil {
+		t.Errorf("Expected re-registering the same repository to be a no-op, got %v", err)
+	}
+	if err := cfg.AddRepository(Repository{Name: "api", Path: "/elsewhere/api"}); !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected a conflict error, got %v", err)
+	}
+
+	if err := cfg.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded.Repositories) != 2 || loaded.Repositories[0].Name != "api" {
+		t.Fatalf("Expected repositories sorted by name, got %v", loaded.Repositories)
+	}
+
+	repo, err := loaded.Repository("web")
+	if err != nil || repo.Path != "/src/web" {
+		t.Errorf("Expected web at /src/web, got %v (%v)", repo, err)
+	}
+	if _, err := loaded.Repository("nope"); !errs.Is(err, errs.NotFound) {
+		t.Errorf("Expected a not-found error, got %v", err)
+	}
+
+	if err := loaded.RemoveRepository("web"); err != nil {
+		t.Fatalf("RemoveRepository failed: %v", err)
+	}
+	if err := loaded.RemoveRepository("web"); !errs.Is(err, errs.NotFound) {
+		t.Errorf("Expected a not-found error, got %v", err)
+	}
+
+	// A corrupt file is reported rather than silently replaced
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Errorf("Expected an error for a corrupt config file")
+	}
+}
+
+// TestTimeouts tests per-subcommand timeouts and their validation
+func TestTimeouts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"timeouts": {"clone": "10m", "default": "30s"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for subcommand, want := range map[string]time.Duration{"clone": 10 * time.Minute, "status": 30 * time.Second} {
+		if got, err := cfg.Timeout(subcommand); err != nil || got != want {
+			t.Errorf("Timeout(%q) = %s, %v, want %s", subcommand, got, err, want)
+		}
+	}
+
+	if got, err := (&Config{}).Timeout("clone"); err != nil || got != 0 {
+		t.Errorf("Expected no timeout by default, got %s, %v", got, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"timeouts": {"fetch": "soon"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); !errs.Is(err, errs.Usage) {
+		t.Errorf("Expected an invalid timeout to be a usage error, got %v", err)
+	}
+}
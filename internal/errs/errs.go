@@ -0,0 +1,180 @@
+// Package errs defines the typed errors git-manager returns and the process
+// exit code each kind of failure maps to.
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies an error so callers (and scripts, via the exit code) can
+// tell failures apart without parsing messages.
+type Kind int
+
+const (
+	// Unknown is any failure that does not fit a more specific kind.
+	Unknown Kind = iota
+	// Usage means the command was invoked incorrectly (bad flags or arguments).
+	Usage
+	// NotInRepo means the command needs a git repository but none was found.
+	NotInRepo
+	// NotFound means a named worktree, branch or repository does not exist.
+	NotFound
+	// Ambiguous means a name matched more than one worktree or repository.
+	Ambiguous
+	// Dirty means a worktree has uncommitted changes that would be lost.
+	Dirty
+	// Conflict means the target already exists or the change collides with
+	// existing state.
+	Conflict
+	// GitFailure means a git subprocess failed.
+	GitFailure
+	// Timeout means a git subprocess ran longer than its timeout and was
+	// killed.
+	Timeout
+	// Interrupted means the command was cancelled, e.g. with Ctrl-C, and
+	// whatever it had started was rolled back.
+	Interrupted
+	// Unsupported means the installed git is too old for what was asked.
+	Unsupported
+)
+
+// Exit codes returned by the git-manager binary. They are part of the public
+// interface; see docs/exit-codes.md.
+const (
+	ExitOK          = 0
+	ExitUnknown     = 1
+	ExitUsage       = 2
+	ExitNotInRepo   = 3
+	ExitNotFound    = 4
+	ExitAmbiguous   = 5
+	ExitDirty       = 6
+	ExitConflict    = 7
+	ExitGitFailure  = 8
+	ExitTimeout     = 9
+	ExitUnsupported = 10
+
+	// ExitInterrupted follows the shell convention of 128 + SIGINT
+	ExitInterrupted = 130
+)
+
+var kindNames = map[Kind]string{
+	Unknown:     "unknown",
+	Usage:       "usage",
+	NotInRepo:   "not-in-repo",
+	NotFound:    "not-found",
+	Ambiguous:   "ambiguous",
+	Dirty:       "dirty",
+	Conflict:    "conflict",
+	GitFailure:  "git-failure",
+	Timeout:     "timeout",
+	Interrupted: "interrupted",
+	Unsupported: "unsupported",
+}
+
+var kindExitCodes = map[Kind]int{
+	Unknown:     ExitUnknown,
+	Usage:       ExitUsage,
+	NotInRepo:   ExitNotInRepo,
+	NotFound:    ExitNotFound,
+	Ambiguous:   ExitAmbiguous,
+	Dirty:       ExitDirty,
+	Conflict:    ExitConflict,
+	GitFailure:  ExitGitFailure,
+	Timeout:     ExitTimeout,
+	Interrupted: ExitInterrupted,
+	Unsupported: ExitUnsupported,
+}
+
+// String returns the kebab-case name of the kind.
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("kind(%d)", int(k))
+}
+
+// ExitCode returns the process exit code for the kind.
+func (k Kind) ExitCode() int {
+	if code, ok := kindExitCodes[k]; ok {
+		return code
+	}
+	return ExitUnknown
+}
+
+// Error is an error with a Kind and an optional underlying cause.
+type Error struct {
+	Kind Kind
+	Msg  string
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	switch {
+	case e.Msg == "" && e.Err == nil:
+		return e.Kind.String()
+	case e.Msg == "":
+		return e.Err.Error()
+	case e.Err == nil:
+		return e.Msg
+	default:
+		return e.Msg + ": " + e.Err.Error()
+	}
+}
+
+// Unwrap returns the underlying cause.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an *Error of the same kind, so that
+// errors.Is(err, &errs.Error{Kind: errs.NotFound}) matches any not-found error.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Msg == "" && t.Err == nil && t.Kind == e.Kind
+}
+
+// New returns an error of the given kind with a formatted message.
+func New(kind Kind, format string, args ...any) *Error {
+	return &Error{Kind: kind, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Wrap returns an error of the given kind that wraps err with a formatted
+// message. If format is empty, the message of err is used as is.
+func Wrap(kind Kind, err error, format string, args ...any) *Error {
+	return &Error{Kind: kind, Msg: fmt.Sprintf(format, args...), Err: err}
+}
+
+// Annotate is Wrap for errors that may already have a kind: err keeps its
+// kind, and kind is only used when err has none. Use it to add context to
+// errors coming back from git, which may be timeouts or interruptions rather
+// than git failures.
+func Annotate(kind Kind, err error, format string, args ...any) *Error {
+	if k := KindOf(err); k != Unknown {
+		kind = k
+	}
+	return Wrap(kind, err, format, args...)
+}
+
+// KindOf returns the kind of the first *Error in err's chain, or Unknown.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return Unknown
+}
+
+// Is reports whether err, or any error it wraps, has the given kind.
+func Is(err error, kind Kind) bool {
+	return errors.Is(err, &Error{Kind: kind})
+}
+
+// ExitCode returns the process exit code for err. A nil error maps to ExitOK.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	return KindOf(err).ExitCode()
+}
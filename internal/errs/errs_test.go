@@ -0,0 +1,85 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestExitCode tests that every kind maps to its documented exit code
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, ExitOK},
+		{"plain error", errors.New("boom"), ExitUnknown},
+		{"usage", New(Usage, "bad flag"), ExitUsage},
+		{"not in repo", New(NotInRepo, "no repo"), ExitNotInRepo},
+		{"not found", New(NotFound, "missing"), ExitNotFound},
+		{"ambiguous", New(Ambiguous, "two matches"), ExitAmbiguous},
+		{"dirty", New(Dirty, "changes"), ExitDirty},
+		{"conflict", New(Conflict, "exists"), ExitConflict},
+		{"git failure", Wrap(GitFailure, errors.New("exit status 128"), "git failed"), ExitGitFailure},
+		{"timeout", New(Timeout, "git clone timed out"), ExitTimeout},
+		{"interrupted", New(Interrupted, "interrupted"), ExitInterrupted},
+		{"unsupported", New(Unsupported, "requires git >= 2.42.0"), ExitUnsupported},
+		{"wrapped with fmt", fmt.Errorf("context: %w", New(NotFound, "missing")), ExitNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestErrorMessage tests how messages and causes are combined
+func TestErrorMessage(t *testing.T) {
+	cause := errors.New("exit status 128")
+
+	if got := Wrap(GitFailure, cause, "error creating worktree").Error(); got != "error creating worktree: exit status 128" {
+		t.Errorf("unexpected message %q", got)
+	}
+	if got := Wrap(Usage, cause, "").Error(); got != "exit status 128" {
+		t.Errorf("unexpected message %q", got)
+	}
+	if got := New(Dirty, "worktree %q is dirty", "feature").Error(); got != `worktree "feature" is dirty` {
+		t.Errorf("unexpected message %q", got)
+	}
+}
+
+// TestIs tests matching errors by kind through wrapping
+func TestIs(t *testing.T) {
+	err := fmt.Errorf("remove: %w", Wrap(GitFailure, New(Dirty, "dirty"), "git failed"))
+
+	if !Is(err, GitFailure) {
+		t.Errorf("expected %v to be a git failure", err)
+	}
+	if !Is(err, Dirty) {
+		t.Errorf("expected %v to wrap a dirty error", err)
+	}
+	if Is(err, NotFound) {
+		t.Errorf("expected %v not to be a not-found error", err)
+	}
+	if !errors.Is(err, &Error{Kind: Dirty}) {
+		t.Errorf("expected errors.Is to match by kind")
+	}
+	if KindOf(err) != GitFailure {
+		t.Errorf("expected outermost kind to be git-failure, got %v", KindOf(err))
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	timeout := Wrap(Timeout, errors.New("deadline exceeded"), "git clone timed out")
+
+	if got := KindOf(Annotate(GitFailure, timeout, "error cloning repository")); got != Timeout {
+		t.Errorf("expected annotating a timeout to keep its kind, got %v", got)
+	}
+	if got := KindOf(Annotate(GitFailure, errors.New("boom"), "error cloning repository")); got != GitFailure {
+		t.Errorf("expected an error without a kind to get the given kind, got %v", got)
+	}
+}
@@ -0,0 +1,234 @@
+// Package git runs git subprocesses. Every invocation goes through a
+// GitRunner, so the real git binary can be swapped for a fake in tests and
+// wrapped with tracing or timeouts.
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+)
+
+// Invocation describes a single git call
+type Invocation struct {
+	// Dir is the directory git runs in
+	Dir string
+
+	// Args are the arguments after "git"
+	Args []string
+
+	// Env holds extra environment entries in "KEY=value" form, added on top
+	// of the runner's environment
+	Env []string
+
+	// Stdin, when set, is fed to git's standard input
+	Stdin io.Reader
+
+	// Stdout and Stderr, when set, receive git's output as it is produced.
+	// The output is captured in the Result either way.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Result is the outcome of an invocation
+type Result struct {
+	Stdout string
+	Stderr string
+
+	// ExitCode is git's exit status, or -1 if git could not be started or
+	// was killed
+	ExitCode int
+}
+
+// GitRunner runs git. Implementations return the Result even when git fails,
+// together with a git-failure error wrapping an *Error.
+type GitRunner interface {
+	Run(ctx context.Context, inv Invocation) (Result, error)
+}
+
+// Error is returned when git fails. Its message always includes what git
+// wrote to stderr.
+type Error struct {
+	Args     []string
+	Dir      string
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("git %s", strings.Join(e.Args, " "))
+	switch {
+	case errors.Is(e.Err, exec.ErrNotFound):
+		msg += " failed: git is not installed or not in PATH"
+	case errors.Is(e.Err, context.DeadlineExceeded):
+		msg += " timed out"
+	case errors.Is(e.Err, context.Canceled):
+		msg += " was interrupted"
+	case e.ExitCode >= 0:
+		msg += fmt.Sprintf(" exited with status %d", e.ExitCode)
+	default:
+		msg += fmt.Sprintf(" failed: %v", e.Err)
+	}
+	if stderr := CleanStderr(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+// Unwrap returns the underlying cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewError returns the error a GitRunner reports for a failed invocation.
+// cause may be nil when git simply exited with a non-zero status. Its kind is
+// a git failure, or a timeout or interruption when cause says the context
+// ended.
+func NewError(inv Invocation, res Result, cause error) error {
+	if cause == nil {
+		cause = fmt.Errorf("exit status %d", res.ExitCode)
+	}
+
+	kind := errs.GitFailure
+	switch {
+	case errors.Is(cause, context.DeadlineExceeded):
+		kind = errs.Timeout
+	case errors.Is(cause, context.Canceled):
+		kind = errs.Interrupted
+	}
+
+	return errs.Wrap(kind, &Error{
+		Args:     inv.Args,
+		Dir:      inv.Dir,
+		ExitCode: res.ExitCode,
+		Stderr:   res.Stderr,
+		Err:      cause,
+	}, "")
+}
+
+// CleanStderr drops the intermediate states of progress lines, which git
+// redraws with carriage returns, and blank lines
+func CleanStderr(stderr string) string {
+	var lines []string
+	for _, line := range strings.Split(stderr, "\n") {
+		if i := strings.LastIndex(strings.TrimRight(line, "\r"), "\r"); i >= 0 {
+			line = line[i+1:]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// waitDelay is how long a cancelled git gets to exit, and how long its
+// output is still read after it exited, before Run gives up on it. Helpers
+// git started can keep its output pipes open after git itself is gone.
+const waitDelay = 5 * time.Second
+
+// ExecRunner is the default GitRunner. It runs the git binary. When the
+// context of an invocation ends, git is terminated.
+type ExecRunner struct {
+	// Path is the git binary to run. It defaults to "git" looked up in PATH.
+	Path string
+
+	// Env is the environment in os.Environ form. A nil Env inherits the
+	// current process environment.
+	Env []string
+
+	// ProcessGroup runs git in a process group of its own, so cancelling an
+	// invocation also terminates the remote helpers, ssh and credential
+	// helpers git started. Leave it off when git may need to prompt on the
+	// terminal: only the terminal's foreground process group can read from
+	// it. Ctrl-C on the terminal reaches that whole group anyway.
+	ProcessGroup bool
+}
+
+// Run implements GitRunner
+func (r *ExecRunner) Run(ctx context.Context, inv Invocation) (Result, error) {
+	path := r.Path
+	if path == "" {
+		path = "git"
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, path, inv.Args...)
+	cmd.Dir = inv.Dir
+	cmd.Env = r.Env
+	if len(inv.Env) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = cmd.Environ()
+		}
+		cmd.Env = append(cmd.Env[:len(cmd.Env):len(cmd.Env)], inv.Env...)
+	}
+	cmd.Stdin = inv.Stdin
+	cmd.Stdout = tee(&stdout, inv.Stdout)
+	cmd.Stderr = tee(&stderr, inv.Stderr)
+	cmd.WaitDelay = waitDelay
+	if r.ProcessGroup {
+		SetProcessGroup(cmd)
+	}
+
+	err := cmd.Run()
+	res := Result{Stdout: stdout.String(), Stderr: stderr.String()}
+	if err == nil {
+		return res, nil
+	}
+
+	res.ExitCode = -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		res.ExitCode = exitErr.ExitCode()
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = fmt.Errorf("%w (%v)", ctxErr, err)
+	}
+	return res, NewError(inv, res, err)
+}
+
+// tee returns a writer that writes to buf and, if set, to w
+func tee(buf *bytes.Buffer, w io.Writer) io.Writer {
+	if w == nil {
+		return buf
+	}
+	return io.MultiWriter(buf, w)
+}
+
+// Run runs git with args in dir, streaming its output to stdout and stderr,
+// either of which may be nil to only capture it
+func Run(ctx context.Context, r GitRunner, dir string, stdout, stderr io.Writer, args ...string) error {
+	_, err := r.Run(ctx, Invocation{Dir: dir, Args: args, Stdout: stdout, Stderr: stderr})
+	return err
+}
+
+// Subcommand returns the git subcommand of args, such as "clone" for
+// "-C /ws clone --bare url", skipping git's global options
+func Subcommand(args []string) string {
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-C" || arg == "-c" || arg == "--git-dir" || arg == "--work-tree" || arg == "--namespace":
+			// The option's value is the next argument
+			i++
+		case strings.HasPrefix(arg, "-"):
+		default:
+			return arg
+		}
+	}
+	return ""
+}
+
+// Output runs git with args in dir and returns its stdout
+func Output(ctx context.Context, r GitRunner, dir string, args ...string) (string, error) {
+	res, err := r.Run(ctx, Invocation{Dir: dir, Args: args})
+	return res.Stdout, err
+}
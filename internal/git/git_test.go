@@ -0,0 +1,246 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+)
+
+// TestRunFailureIncludesStderr tests that git's stderr ends up in the error
+func TestRunFailureIncludesStderr(t *testing.T) {
+	dir := t.TempDir()
+
+	res, err := (&ExecRunner{}).Run(context.Background(), Invocation{Dir: dir, Args: []string{"rev-parse", "HEAD"}})
+	if err == nil {
+		t.Fatalf("Expected rev-parse outside a repository to fail")
+	}
+	if !errs.Is(err, errs.GitFailure) {
+		t.Errorf("Expected a git failure, got %v", err)
+	}
+	if res.ExitCode != 128 || !strings.Contains(res.Stderr, "not a git repository") {
+		t.Errorf("Unexpected result: %+v", res)
+	}
+
+	var gitErr *Error
+	if !errors.As(err, &gitErr) {
+		t.Fatalf("Expected a *git.Error, got %T", err)
+	}
+	if gitErr.ExitCode != 128 || gitErr.Dir != dir {
+		t.Errorf("Unexpected error details: exit %d in %s", gitErr.ExitCode, gitErr.Dir)
+	}
+	if !strings.Contains(err.Error(), "not a git repository") {
+		t.Errorf("Expected git's stderr in the message, got %q", err.Error())
+	}
+}
+
+// TestRunStreamsAndCaptures tests that output is both streamed and captured,
+// and that extra environment entries reach git
+func TestRunStreamsAndCaptures(t *testing.T) {
+	var stdout bytes.Buffer
+	res, err := (&ExecRunner{}).Run(context.Background(), Invocation{
+		Dir:    t.TempDir(),
+		Args:   []string{"config", "--get", "gm.test"},
+		Env:    []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=gm.test", "GIT_CONFIG_VALUE_0=hello"},
+		Stdout: &stdout,
+	})
+	if err != nil {
+		t.Fatalf("git config failed: %v", err)
+	}
+	if res.Stdout != "hello\n" || stdout.String() != "hello\n" {
+		t.Errorf("Expected hello to be captured and streamed, got %q and %q", res.Stdout, stdout.String())
+	}
+}
+
+// TestGitNotInstalled tests the error when the git binary is missing
+func TestGitNotInstalled(t *testing.T) {
+	res, err := (&ExecRunner{Path: "git-manager-no-such-git"}).Run(context.Background(), Invocation{Args: []string{"status"}})
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Fatalf("Expected exec.ErrNotFound, got %v", err)
+	}
+	if res.ExitCode != -1 {
+		t.Errorf("Expected exit code -1, got %d", res.ExitCode)
+	}
+	if !strings.Contains(err.Error(), "git is not installed or not in PATH") {
+		t.Errorf("Expected a helpful message, got %q", err.Error())
+	}
+}
+
+// TestTrace tests that invocations are traced with their details
+func TestTrace(t *testing.T) {
+	dir := t.TempDir()
+
+	var trace bytes.Buffer
+	r := WithTrace(&ExecRunner{}, &trace)
+
+	if _, err := Output(context.Background(), r, dir, "--version"); err != nil {
+		t.Fatalf("git --version failed: %v", err)
+	}
+	r.Run(context.Background(), Invocation{Dir: dir, Args: []string{"rev-parse", "HEAD"}, Env: []string{"GIT_DIR=nope"}})
+
+	out := trace.String()
+	for _, want := range []string{
+		"git-manager trace: $ git --version\n",
+		"git-manager trace:   dir: " + dir + "\n",
+		"git-manager trace:   exit: 0 after ",
+		"git-manager trace: $ git rev-parse HEAD\n",
+		"git-manager trace:   env: GIT_DIR=nope\n",
+		"git-manager trace:   exit: 128 after ",
+		"git-manager trace:   stderr: fatal: not a git repository",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected trace to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+// TestWithTimeout tests that a hung invocation is cancelled
+func TestWithTimeout(t *testing.T) {
+	hang := RunnerFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+		<-ctx.Done()
+		res := Result{ExitCode: -1}
+		return res, NewError(inv, res, ctx.Err())
+	})
+
+	start := time.Now()
+	_, err := WithTimeout(hang, 20*time.Millisecond).Run(context.Background(), Invocation{Args: []string{"fetch"}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the timeout to fire quickly, took %s", elapsed)
+	}
+
+	// The real runner kills git when the context expires
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = (&ExecRunner{Path: "sleep"}).Run(ctx, Invocation{Args: []string{"5"}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline error from the exec runner, got %v", err)
+	}
+	if !errs.Is(err, errs.Timeout) || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+}
+
+// TestProcessGroup tests that cancelling git also terminates the processes
+// it started, which would otherwise keep its output open
+func TestProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are not supported on windows")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	r := &ExecRunner{ProcessGroup: true}
+	_, err := r.Run(ctx, Invocation{Dir: t.TempDir(), Args: []string{"-c", "alias.hang=!sleep 30", "hang"}})
+	if !errs.Is(err, errs.Interrupted) || !strings.Contains(err.Error(), "was interrupted") {
+		t.Errorf("Expected an interrupted error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > waitDelay/2 {
+		t.Errorf("Expected the whole process group to be killed quickly, took %s", elapsed)
+	}
+}
+
+// TestWithTimeoutFunc tests per-invocation timeouts
+func TestWithTimeoutFunc(t *testing.T) {
+	var got []time.Duration
+	r := RunnerFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+		deadline, ok := ctx.Deadline()
+		if ok {
+			got = append(got, time.Until(deadline).Round(time.Minute))
+		} else {
+			got = append(got, 0)
+		}
+		return Result{}, nil
+	})
+
+	timeouts := WithTimeoutFunc(r, func(inv Invocation) time.Duration {
+		if Subcommand(inv.Args) == "clone" {
+			return 10 * time.Minute
+		}
+		return 0
+	})
+	timeouts.Run(context.Background(), Invocation{Args: []string{"clone", "--bare", "url"}})
+	timeouts.Run(context.Background(), Invocation{Args: []string{"-C", "/ws/.git", "status"}})
+
+	if len(got) != 2 || got[0] != 10*time.Minute || got[1] != 0 {
+		t.Errorf("Expected a 10m timeout for clone and none for status, got %v", got)
+	}
+}
+
+// TestSubcommand tests finding the subcommand behind global options
+func TestSubcommand(t *testing.T) {
+	tests := map[string][]string{
+		"clone":    {"clone", "--bare", "url", "dir"},
+		"worktree": {"-C", "/ws/.git", "worktree", "add", "/ws/x"},
+		"status":   {"-c", "color.ui=never", "--no-pager", "status"},
+		"":         {"--version"},
+	}
+	for want, args := range tests {
+		if got := Subcommand(args); got != want {
+			t.Errorf("Subcommand(%q) = %q, want %q", args, got, want)
+		}
+	}
+}
+
+// TestCleanStderr tests that progress redraws and blank lines are dropped
+func TestCleanStderr(t *testing.T) {
+	stderr := "Cloning into 'x'...\nReceiving objects:  50% (1/2)\rReceiving objects: 100% (2/2), done.\r\n\nfatal: boom\n"
+	want := "Cloning into 'x'...\nReceiving objects: 100% (2/2), done.\nfatal: boom"
+
+	if got := CleanStderr(stderr); got != want {
+		t.Errorf("CleanStderr() = %q, want %q", got, want)
+	}
+}
+
+// TestProgressWriter tests splitting stderr into progress reports and other
+// lines, across writes and carriage returns
+func TestProgressWriter(t *testing.T) {
+	var progress []Progress
+	var lines []string
+	w := &ProgressWriter{
+		OnProgress: func(p Progress) { progress = append(progress, p) },
+		OnLine:     func(line string) { lines = append(lines, line) },
+	}
+
+	for _, chunk := range []string{
+		"From /tmp/origin\nremote: Counting objects:  50% (1/2)\rremote: Counting",
+		" objects: 100% (2/2), done.\nReceiving objects:  33% (1/3)\r",
+		"Receiving objects: 100% (3/3), done.\r\n * [new branch]      topic      -> origin/topic\n",
+		"   1a2b3c4..5d6e7f8  main       -> origin/main",
+	} {
+		w.Write([]byte(chunk))
+	}
+	w.Flush()
+
+	wantProgress := []Progress{
+		{"Counting objects", 50}, {"Counting objects", 100},
+		{"Receiving objects", 33}, {"Receiving objects", 100},
+	}
+	if len(progress) != len(wantProgress) {
+		t.Fatalf("Expected %v, got %v", wantProgress, progress)
+	}
+	for i, p := range wantProgress {
+		if progress[i] != p {
+			t.Errorf("Progress %d = %v, want %v", i, progress[i], p)
+		}
+	}
+
+	wantLines := []string{
+		"From /tmp/origin",
+		" * [new branch]      topic      -> origin/topic",
+		"   1a2b3c4..5d6e7f8  main       -> origin/main",
+	}
+	if strings.Join(lines, "\n") != strings.Join(wantLines, "\n") {
+		t.Errorf("Expected lines %q, got %q", wantLines, lines)
+	}
+}
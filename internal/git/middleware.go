@@ -0,0 +1,79 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/plan"
+)
+
+// RunnerFunc adapts a function to the GitRunner interface
+type RunnerFunc func(ctx context.Context, inv Invocation) (Result, error)
+
+// Run implements GitRunner
+func (f RunnerFunc) Run(ctx context.Context, inv Invocation) (Result, error) {
+	return f(ctx, inv)
+}
+
+// WithTrace returns a runner that logs every invocation to w with its argv,
+// working directory, duration, exit code and stderr
+func WithTrace(r GitRunner, w io.Writer) GitRunner {
+	var mu sync.Mutex
+
+	return RunnerFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+		start := time.Now()
+		res, err := r.Run(ctx, inv)
+		elapsed := time.Since(start)
+
+		// Keep the lines of concurrent invocations together
+		var b strings.Builder
+		fmt.Fprintf(&b, "git-manager trace: %s\n", plan.Command(append([]string{"git"}, inv.Args...)...))
+		if inv.Dir != "" {
+			fmt.Fprintf(&b, "git-manager trace:   dir: %s\n", inv.Dir)
+		}
+		for _, kv := range inv.Env {
+			fmt.Fprintf(&b, "git-manager trace:   env: %s\n", kv)
+		}
+		fmt.Fprintf(&b, "git-manager trace:   exit: %d after %s\n", res.ExitCode, elapsed.Round(10*time.Microsecond))
+		if stderr := CleanStderr(res.Stderr); stderr != "" {
+			for _, line := range strings.Split(stderr, "\n") {
+				fmt.Fprintf(&b, "git-manager trace:   stderr: %s\n", line)
+			}
+		}
+
+		mu.Lock()
+		io.WriteString(w, b.String())
+		mu.Unlock()
+
+		return res, err
+	})
+}
+
+// WithTimeout returns a runner that cancels any invocation still running
+// after d. The timeout applies to each invocation separately. A zero d
+// returns r unchanged.
+func WithTimeout(r GitRunner, d time.Duration) GitRunner {
+	if d <= 0 {
+		return r
+	}
+	return WithTimeoutFunc(r, func(Invocation) time.Duration { return d })
+}
+
+// WithTimeoutFunc returns a runner that cancels each invocation still running
+// after the duration timeout returns for it. Invocations with a zero duration
+// run without a timeout.
+func WithTimeoutFunc(r GitRunner, timeout func(Invocation) time.Duration) GitRunner {
+	return RunnerFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+		d := timeout(inv)
+		if d <= 0 {
+			return r.Run(ctx, inv)
+		}
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		return r.Run(ctx, inv)
+	})
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package git
+
+import "os/exec"
+
+// SetProcessGroup is a no-op where process groups are not available. Only
+// the process itself is killed when cmd is cancelled.
+func SetProcessGroup(cmd *exec.Cmd) {}
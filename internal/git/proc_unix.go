@@ -0,0 +1,23 @@
+//go:build unix
+
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// SetProcessGroup starts cmd in a new process group and makes cancellation
+// terminate the whole group. git cleans up its lock files on SIGTERM.
+func SetProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		err := syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+}
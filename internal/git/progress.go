@@ -0,0 +1,81 @@
+package git
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Progress is a progress report git writes to stderr with --progress, such
+// as "Receiving objects:  45% (450/1000)"
+type Progress struct {
+	// Phase is what git is doing, e.g. "Receiving objects"
+	Phase string
+
+	// Percent is how far along the phase is
+	Percent int
+}
+
+// progressLine matches progress reports, including those of the remote side
+var progressLine = regexp.MustCompile(`^(?:remote: )?([A-Za-z][A-Za-z ]*):\s+(\d+)% `)
+
+// ParseProgress parses a progress report. It reports false for any other
+// line.
+func ParseProgress(line string) (Progress, bool) {
+	match := progressLine.FindStringSubmatch(strings.TrimSpace(line) + " ")
+	if match == nil {
+		return Progress{}, false
+	}
+	percent, _ := strconv.Atoi(match[2])
+	return Progress{Phase: match[1], Percent: percent}, true
+}
+
+// ProgressWriter splits git's stderr into lines as it is written, both at
+// newlines and at the carriage returns git redraws progress reports with.
+// Progress reports go to OnProgress, everything else to OnLine. Either may
+// be nil.
+type ProgressWriter struct {
+	OnProgress func(Progress)
+	OnLine     func(string)
+
+	buf []byte
+}
+
+// Write implements io.Writer
+func (w *ProgressWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		w.emit(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush handles a last line that did not end with a newline
+func (w *ProgressWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.emit(string(w.buf))
+		w.buf = nil
+	}
+}
+
+// emit passes line on to the callbacks
+func (w *ProgressWriter) emit(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if p, ok := ParseProgress(line); ok {
+		if w.OnProgress != nil {
+			w.OnProgress(p)
+		}
+		return
+	}
+	if w.OnLine != nil {
+		w.OnLine(line)
+	}
+}
@@ -0,0 +1,151 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+)
+
+// Version is a git release version
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// String returns the version as git prints it, e.g. "2.39.5"
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast reports whether v is the same as or newer than other
+func (v Version) AtLeast(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor > other.Minor
+	}
+	return v.Patch >= other.Patch
+}
+
+// ParseVersion parses the output of `git --version`. Vendor suffixes such as
+// "2.39.3 (Apple Git-146)", "2.45.1.windows.1" or "2.46.0.rc1" are ignored.
+func ParseVersion(output string) (Version, error) {
+	fields := strings.Fields(output)
+	if len(fields) < 3 || fields[0] != "git" || fields[1] != "version" {
+		return Version{}, fmt.Errorf("unexpected git --version output %q", strings.TrimSpace(output))
+	}
+
+	var numbers [3]int
+	for i, part := range strings.SplitN(fields[2], ".", 4) {
+		if i == len(numbers) {
+			break
+		}
+		// Release candidates look like "0-rc1" or "rc1" in the last part
+		part, _, _ = strings.Cut(part, "-")
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			if i < 2 {
+				return Version{}, fmt.Errorf("unexpected git version %q", fields[2])
+			}
+			break
+		}
+		numbers[i] = n
+	}
+	return Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, nil
+}
+
+// DetectVersion asks git for its version
+func DetectVersion(ctx context.Context, r GitRunner) (Version, error) {
+	output, err := Output(ctx, r, "", "--version")
+	if err != nil {
+		return Version{}, errs.Annotate(errs.GitFailure, err, "error detecting the git version")
+	}
+	return ParseVersion(output)
+}
+
+// Capability is a git feature git-manager uses when it is available
+type Capability struct {
+	// Name identifies the capability in messages and `tool doctor`
+	Name string
+
+	// Description says what git-manager uses it for
+	Description string
+
+	// Since is the first git version that has it
+	Since Version
+}
+
+// The capabilities git-manager knows about
+var (
+	PorcelainZ = Capability{
+		Name:        "worktree-list-z",
+		Description: "`git worktree list --porcelain -z`, for worktree paths with unusual characters",
+		Since:       Version{2, 36, 0},
+	}
+	MergeTreeWriteTree = Capability{
+		Name:        "merge-tree-write-tree",
+		Description: "`git merge-tree --write-tree`, to predict conflicts without touching a worktree",
+		Since:       Version{2, 38, 0},
+	}
+	WorktreeOrphan = Capability{
+		Name:        "worktree-add-orphan",
+		Description: "`git worktree add --orphan`, to start a worktree on a branch without history",
+		Since:       Version{2, 42, 0},
+	}
+	RelativeWorktrees = Capability{
+		Name:        "relative-worktrees",
+		Description: "`git worktree add --relative-paths`, so workspaces can be moved without repairs",
+		Since:       Version{2, 48, 0},
+	}
+)
+
+// Capabilities lists every known capability, oldest first
+var Capabilities = []Capability{PorcelainZ, MergeTreeWriteTree, WorktreeOrphan, RelativeWorktrees}
+
+// Features is the set of capabilities of an installed git
+type Features struct {
+	Version Version
+}
+
+// Has reports whether git has the capability
+func (f Features) Has(c Capability) bool {
+	return f.Version.AtLeast(c.Since)
+}
+
+// Require returns an Unsupported error naming the git version needed when
+// git lacks the capability
+func (f Features) Require(c Capability, what string) error {
+	if f.Has(c) {
+		return nil
+	}
+	return errs.New(errs.Unsupported, "%s requires git >= %s, but git %s is installed", what, c.Since, f.Version)
+}
+
+// FeatureDetector detects the features of git once and caches them. It is
+// safe for concurrent use.
+type FeatureDetector struct {
+	Git GitRunner
+
+	mu       sync.Mutex
+	features *Features
+}
+
+// Features returns the features of git. Only the first successful detection
+// runs `git --version`; failures are retried on the next call.
+func (d *FeatureDetector) Features(ctx context.Context) (Features, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.features == nil {
+		v, err := DetectVersion(ctx, d.Git)
+		if err != nil {
+			return Features{}, err
+		}
+		d.features = &Features{Version: v}
+	}
+	return *d.features, nil
+}
@@ -0,0 +1,81 @@
+package git
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+)
+
+// TestParseVersion tests parsing the version strings git vendors print
+func TestParseVersion(t *testing.T) {
+	tests := map[string]Version{
+		"git version 2.39.5\n":                 {2, 39, 5},
+		"git version 2.39.3 (Apple Git-146)\n": {2, 39, 3},
+		"git version 2.45.1.windows.1\n":       {2, 45, 1},
+		"git version 2.46.0.rc1\n":             {2, 46, 0},
+		"git version 2.48.0-rc2\n":             {2, 48, 0},
+		"git version 2.50\n":                   {2, 50, 0},
+	}
+	for output, want := range tests {
+		got, err := ParseVersion(output)
+		if err != nil {
+			t.Errorf("ParseVersion(%q) failed: %v", output, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseVersion(%q) = %s, want %s", output, got, want)
+		}
+	}
+
+	if _, err := ParseVersion("hub version 2.14.2"); err == nil {
+		t.Error("Expected an error for output that is not git's")
+	}
+}
+
+// TestFeatures tests capability checks against the version
+func TestFeatures(t *testing.T) {
+	f := Features{Version: Version{2, 39, 5}}
+
+	if !f.Has(PorcelainZ) || !f.Has(MergeTreeWriteTree) {
+		t.Errorf("Expected git 2.39.5 to have %s and %s", PorcelainZ.Name, MergeTreeWriteTree.Name)
+	}
+	if f.Has(WorktreeOrphan) {
+		t.Errorf("Expected git 2.39.5 not to have %s", WorktreeOrphan.Name)
+	}
+
+	err := f.Require(WorktreeOrphan, "--orphan")
+	if !errs.Is(err, errs.Unsupported) {
+		t.Fatalf("Expected an unsupported error, got %v", err)
+	}
+	if want := "--orphan requires git >= 2.42.0, but git 2.39.5 is installed"; err.Error() != want {
+		t.Errorf("Require() = %q, want %q", err.Error(), want)
+	}
+}
+
+// TestFeatureDetector tests that git is asked for its version only once
+func TestFeatureDetector(t *testing.T) {
+	calls := 0
+	r := RunnerFunc(func(ctx context.Context, inv Invocation) (Result, error) {
+		calls++
+		if strings.Join(inv.Args, " ") != "--version" {
+			t.Errorf("Unexpected git invocation: %q", inv.Args)
+		}
+		return Result{Stdout: "git version 2.42.0\n"}, nil
+	})
+
+	d := &FeatureDetector{Git: r}
+	for i := 0; i < 3; i++ {
+		f, err := d.Features(context.Background())
+		if err != nil {
+			t.Fatalf("Features failed: %v", err)
+		}
+		if !f.Has(WorktreeOrphan) || f.Has(RelativeWorktrees) {
+			t.Errorf("Unexpected features for git %s", f.Version)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected git --version to run once, ran %d times", calls)
+	}
+}
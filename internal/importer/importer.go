@@ -0,0 +1,293 @@
+// Package importer reads the repository lists of other checkout managers,
+// so their repositories can be brought into git-manager.
+package importer
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+)
+
+// The sources Read understands
+const (
+	SourceGhq          = "ghq"
+	SourceMr           = "mr"
+	SourceRepoManifest = "repo-manifest"
+)
+
+// Sources lists the supported sources
+var Sources = []string{SourceGhq, SourceMr, SourceRepoManifest}
+
+// Entry is a repository listed by a source
+type Entry struct {
+	// Path is the absolute path of the checkout, which may not exist yet
+	Path string
+
+	// URL is where to clone the repository from, if the source says
+	URL string
+}
+
+// Read lists the repositories of the source from at path: the root
+// directory for ghq, the .mrconfig file for mr and the manifest file for
+// repo-manifest. Entries are sorted by path.
+func Read(from string, path string) ([]Entry, error) {
+	var entries []Entry
+	var err error
+	switch from {
+	case SourceGhq:
+		entries, err = Ghq(path)
+	case SourceMr:
+		entries, err = MrConfig(path)
+	case SourceRepoManifest:
+		entries, err = RepoManifest(path)
+	default:
+		return nil, errs.New(errs.Usage, "unknown import source %q, use one of: %s", from, strings.Join(Sources, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries, nil
+}
+
+// Ghq lists the checkouts in a ghq root, laid out as <host>/<owner>/<repo>.
+// ghq keeps no list of its own, so every entry exists and has no URL.
+func Ghq(root string) ([]Entry, error) {
+	var entries []Entry
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return fs.SkipDir
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			return fs.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+			entries = append(entries, Entry{Path: path})
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading ghq root %s: %w", root, err)
+	}
+	return entries, nil
+}
+
+// MrConfig lists the repositories in a myrepos .mrconfig file. Section names
+// are checkout paths relative to the file's directory, and the URL comes
+// from the section's `checkout = git clone <url>` command.
+func MrConfig(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	defer f.Close()
+
+	base := filepath.Dir(path)
+	var entries []Entry
+	current := -1
+	var key, value string
+
+	// flush finishes the key being read, which may span continuation lines
+	flush := func() {
+		if current >= 0 && key == "checkout" {
+			entries[current].URL = cloneURL(value)
+		}
+		key, value = "", ""
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";"):
+			continue
+		case key != "" && (line[0] == ' ' || line[0] == '\t'):
+			value += " " + trimmed
+			continue
+		}
+		flush()
+
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			section := strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			current = -1
+			if section == "DEFAULT" || section == "" {
+				continue
+			}
+			if !filepath.IsAbs(section) {
+				section = filepath.Join(base, section)
+			}
+			entries = append(entries, Entry{Path: filepath.Clean(section)})
+			current = len(entries) - 1
+			continue
+		}
+
+		if k, v, ok := strings.Cut(trimmed, "="); ok {
+			key, value = strings.TrimSpace(k), strings.TrimSpace(v)
+			value = strings.TrimSuffix(value, "\\")
+		}
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// cloneURL returns the repository URL of a `git clone` command line, or ""
+// if command is not one
+func cloneURL(command string) string {
+	args := shellFields(command)
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] != "git" || args[i+1] != "clone" {
+			continue
+		}
+		for j := i + 2; j < len(args); j++ {
+			switch arg := args[j]; {
+			case arg == "-b" || arg == "--branch" || arg == "-o" || arg == "--origin" ||
+				arg == "-c" || arg == "--config" || arg == "--depth" || arg == "--reference":
+				j++
+			case strings.HasPrefix(arg, "-"):
+			default:
+				return arg
+			}
+		}
+	}
+	return ""
+}
+
+// shellFields splits s into words like a POSIX shell would, honoring single
+// and double quotes and backslashes but expanding nothing
+func shellFields(s string) []string {
+	var fields []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	for i := 0; i < len(s); i++ {
+		c := rune(s[i])
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' && i+1 < len(s) {
+				i++
+				word.WriteByte(s[i])
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == '\\' && i+1 < len(s):
+			i++
+			word.WriteByte(s[i])
+			inWord = true
+		case c == ' ' || c == '\t' || c == ';' || c == '&' || c == '|':
+			if inWord {
+				fields = append(fields, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		fields = append(fields, word.String())
+	}
+	return fields
+}
+
+// manifest is the part of a repo tool manifest that Entries need
+type manifest struct {
+	Remotes []struct {
+		Name  string `xml:"name,attr"`
+		Fetch string `xml:"fetch,attr"`
+	} `xml:"remote"`
+	Default struct {
+		Remote string `xml:"remote,attr"`
+	} `xml:"default"`
+	Projects []struct {
+		Name   string `xml:"name,attr"`
+		Path   string `xml:"path,attr"`
+		Remote string `xml:"remote,attr"`
+	} `xml:"project"`
+}
+
+// RepoManifest lists the projects of an Android-style repo tool manifest.
+// Project paths are relative to the checkout root: the directory holding
+// .repo when the manifest is inside one, otherwise the manifest's
+// directory. URLs are the remote's fetch URL joined with the project name.
+func RepoManifest(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	var m manifest
+	if err := xml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error parsing manifest %s: %w", path, err)
+	}
+
+	root := filepath.Dir(path)
+	if i := strings.Index(path, string(filepath.Separator)+".repo"+string(filepath.Separator)); i >= 0 {
+		root = path[:i]
+	}
+
+	fetch := map[string]string{}
+	for _, r := range m.Remotes {
+		fetch[r.Name] = r.Fetch
+	}
+
+	entries := make([]Entry, 0, len(m.Projects))
+	for _, p := range m.Projects {
+		checkout := p.Path
+		if checkout == "" {
+			checkout = p.Name
+		}
+		remote := p.Remote
+		if remote == "" {
+			remote = m.Default.Remote
+		}
+		entries = append(entries, Entry{
+			Path: filepath.Join(root, filepath.FromSlash(checkout)),
+			URL:  projectURL(fetch[remote], p.Name),
+		})
+	}
+	return entries, nil
+}
+
+// projectURL joins a remote's fetch URL and a project name. Fetch URLs
+// relative to the manifest's own URL cannot be resolved here and give "".
+func projectURL(fetch string, name string) string {
+	if fetch == "" || strings.HasPrefix(fetch, ".") {
+		return ""
+	}
+	if u, err := url.Parse(fetch); err == nil && u.Scheme != "" {
+		return strings.TrimSuffix(fetch, "/") + "/" + name
+	}
+	// scp-style fetch URLs like git@host:org/
+	if strings.HasSuffix(fetch, ":") {
+		return fetch + name
+	}
+	return strings.TrimSuffix(fetch, "/") + "/" + name
+}
@@ -0,0 +1,123 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+)
+
+// writeFile writes content to path, creating its directory
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGhq tests finding checkouts in a ghq root
+func TestGhq(t *testing.T) {
+	root := t.TempDir()
+	for _, repo := range []string{"github.com/org/api", "github.com/org/web", "gitlab.com/team/tools"} {
+		if err := os.MkdirAll(filepath.Join(root, repo, ".git", "objects"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Nested directories of a checkout are not separate repositories
+	if err := os.MkdirAll(filepath.Join(root, "github.com/org/api/vendor/lib/.git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Read(SourceGhq, root)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	want := []Entry{
+		{Path: filepath.Join(root, "github.com/org/api")},
+		{Path: filepath.Join(root, "github.com/org/web")},
+		{Path: filepath.Join(root, "gitlab.com/team/tools")},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("Read = %+v, want %+v", entries, want)
+	}
+}
+
+// TestMrConfig tests reading paths and clone URLs from an .mrconfig
+func TestMrConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".mrconfig")
+	writeFile(t, path, `[DEFAULT]
+jobs = 4
+
+# the API
+[src/api]
+checkout = git clone 'git@github.com:org/api.git' 'api'
+
+[src/web]
+checkout =
+	git clone --branch develop "https://github.com/org/web.git" web &&
+	cd web && git config user.email me@example.com
+
+[/opt/tools]
+checkout = git clone -o upstream https://example.com/tools.git tools
+
+[notes]
+update = svn update
+`)
+
+	entries, err := Read(SourceMr, path)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	want := []Entry{
+		{Path: "/opt/tools", URL: "https://example.com/tools.git"},
+		{Path: filepath.Join(dir, "notes")},
+		{Path: filepath.Join(dir, "src/api"), URL: "git@github.com:org/api.git"},
+		{Path: filepath.Join(dir, "src/web"), URL: "https://github.com/org/web.git"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("Read = %+v, want %+v", entries, want)
+	}
+}
+
+// TestRepoManifest tests reading projects from a repo tool manifest
+func TestRepoManifest(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, ".repo", "manifests", "default.xml")
+	writeFile(t, path, `<?xml version="1.0" encoding="UTF-8"?>
+<manifest>
+  <remote name="aosp" fetch="https://android.googlesource.com/" />
+  <remote name="corp" fetch="git@git.example.com:" />
+  <remote name="rel" fetch=".." />
+  <default remote="aosp" revision="main" />
+  <project name="platform/build" path="build/make" />
+  <project name="tools/lint" remote="corp" />
+  <project name="relative" remote="rel" />
+</manifest>
+`)
+
+	entries, err := Read(SourceRepoManifest, path)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	want := []Entry{
+		{Path: filepath.Join(root, "build/make"), URL: "https://android.googlesource.com/platform/build"},
+		{Path: filepath.Join(root, "relative")},
+		{Path: filepath.Join(root, "tools/lint"), URL: "git@git.example.com:tools/lint"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("Read = %+v, want %+v", entries, want)
+	}
+}
+
+// TestReadUnknownSource tests that an unknown source is a usage error
+func TestReadUnknownSource(t *testing.T) {
+	if _, err := Read("svn", t.TempDir()); !errs.Is(err, errs.Usage) {
+		t.Errorf("Expected a usage error, got %v", err)
+	}
+}
@@ -1,10 +1,12 @@
 package internal_test
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/ingshtrom/git-manager/internal/git"
 	"github.com/ingshtrom/git-manager/internal/testutil"
 	"github.com/ingshtrom/git-manager/internal/worktree"
 )
@@ -16,7 +18,7 @@ func TestWorktreeIntegration(t *testing.T) {
 	defer cleanup()
 
 	// Test GetWorktreeInfo
-	worktrees, err := worktree.GetWorktreeInfo(suite.RootRepo.Path)
+	worktrees, err := worktree.GetWorktreeInfo(context.Background(), &git.ExecRunner{}, suite.RootRepo.Path)
 	if err != nil {
 		t.Fatalf("GetWorktreeInfo failed: %v", err)
 	}
@@ -71,7 +73,7 @@ func TestWorktreeIntegration(t *testing.T) {
 	}
 
 	// Test IsGitRepository
-	if !worktree.IsGitRepository(suite.RootRepo.Path) {
+	if !worktree.IsGitRepository(context.Background(), &git.ExecRunner{}, suite.RootRepo.Path) {
 		t.Errorf("Expected %s to be a git repository", suite.RootRepo.Path)
 	}
 
@@ -82,7 +84,7 @@ func TestWorktreeIntegration(t *testing.T) {
 	}
 	defer os.RemoveAll(tempDir)
 
-	if worktree.IsGitRepository(tempDir) {
+	if worktree.IsGitRepository(context.Background(), &git.ExecRunner{}, tempDir) {
 		t.Errorf("Expected %s to not be a git repository", tempDir)
 	}
 
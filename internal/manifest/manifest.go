@@ -0,0 +1,154 @@
+// Package manifest reads and writes workspace manifests, the YAML files that
+// describe a set of repositories and worktrees so they can be recreated on
+// another machine.
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the manifest format version written by Write
+const Version = 1
+
+// Manifest describes a set of workspaces
+type Manifest struct {
+	Version      int          `yaml:"version"`
+	Repositories []Repository `yaml:"repositories"`
+}
+
+// Repository is a registered workspace
+type Repository struct {
+	// Name is the registry name
+	Name string `yaml:"name"`
+
+	// Path is the workspace directory. A leading "~/" stands for the home
+	// directory, so manifests work across machines.
+	Path string `yaml:"path"`
+
+	// DefaultBranch is the branch HEAD of the repository points to
+	DefaultBranch string `yaml:"default_branch,omitempty"`
+
+	// Remotes are the repository's remotes. The first is cloned from.
+	Remotes []Remote `yaml:"remotes,omitempty"`
+
+	// Worktrees are the active worktrees, when they were exported
+	Worktrees []Worktree `yaml:"worktrees,omitempty"`
+}
+
+// Remote is a git remote
+type Remote struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+}
+
+// Worktree is a worktree and the branch checked out in it
+type Worktree struct {
+	// Path is relative to the workspace directory
+	Path string `yaml:"path"`
+
+	Branch string `yaml:"branch"`
+}
+
+// Write writes m as YAML
+func Write(w io.Writer, m *Manifest) error {
+	out := *m
+	out.Version = Version
+	if out.Repositories == nil {
+		out.Repositories = []Repository{}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("# git-manager workspace manifest, recreate it with `git-manager workspace apply`\n")
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&out); err != nil {
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Read parses a manifest written by Write or by hand. Unknown fields are
+// rejected, so typos do not go unnoticed. Invalid manifests are Usage errors.
+func Read(r io.Reader) (*Manifest, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+
+	m := &Manifest{Version: Version}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(m); err != nil && !errors.Is(err, io.EOF) {
+		return nil, errs.Wrap(errs.Usage, err, "invalid manifest")
+	}
+	if err := validate(m); err != nil {
+		return nil, errs.Wrap(errs.Usage, err, "invalid manifest")
+	}
+
+	// "[]" and a missing list mean the same
+	if len(m.Repositories) == 0 {
+		m.Repositories = nil
+	}
+	for i := range m.Repositories {
+		if len(m.Repositories[i].Remotes) == 0 {
+			m.Repositories[i].Remotes = nil
+		}
+		if len(m.Repositories[i].Worktrees) == 0 {
+			m.Repositories[i].Worktrees = nil
+		}
+	}
+	return m, nil
+}
+
+// validate checks what the YAML decoder cannot: the version, required
+// fields and duplicate names
+func validate(m *Manifest) error {
+	if m.Version > Version {
+		return fmt.Errorf("version %d is newer than this git-manager supports (%d), upgrade git-manager", m.Version, Version)
+	}
+
+	names := map[string]bool{}
+	for i, repo := range m.Repositories {
+		where := fmt.Sprintf("repositories[%d]", i)
+		if err := required(where, "name", repo.Name, "path", repo.Path); err != nil {
+			return err
+		}
+		if names[repo.Name] {
+			return fmt.Errorf("%s: repository %q is listed twice", where, repo.Name)
+		}
+		names[repo.Name] = true
+
+		for j, remote := range repo.Remotes {
+			if err := required(fmt.Sprintf("%s.remotes[%d]", where, j), "name", remote.Name, "url", remote.URL); err != nil {
+				return err
+			}
+		}
+		for j, wt := range repo.Worktrees {
+			if err := required(fmt.Sprintf("%s.worktrees[%d]", where, j), "path", wt.Path, "branch", wt.Branch); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// required returns an error for the first empty value of the key, value
+// pairs in fields
+func required(where string, fields ...string) error {
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i+1] == "" {
+			return fmt.Errorf("%s: %s is missing", where, fields[i])
+		}
+	}
+	return nil
+}
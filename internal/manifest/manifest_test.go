@@ -0,0 +1,106 @@
+package manifest
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+)
+
+// TestRoundTrip tests that Read returns what Write wrote, including values
+// that need quoting
+func TestRoundTrip(t *testing.T) {
+	m := &Manifest{
+		Version: Version,
+		Repositories: []Repository{
+			{
+				Name:          "api",
+				Path:          "~/code/api",
+				DefaultBranch: "main",
+				Remotes: []Remote{
+					{Name: "origin", URL: "git@github.com:org/api.git"},
+					{Name: "upstream", URL: "file:///srv/git/api.git"},
+				},
+				Worktrees: []Worktree{
+					{Path: "main", Branch: "main"},
+					{Path: "feature/login", Branch: "feature/login"},
+				},
+			},
+			{
+				Name:    "true",
+				Path:    "/tmp/odd: path #1",
+				Remotes: []Remote{{Name: "origin", URL: "'quoted'"}},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, m); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v\n%s", err, buf.String())
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("Read = %+v, want %+v\n%s", got, m, buf.String())
+	}
+}
+
+// TestReadHandWritten tests reading the indentation styles people use when
+// editing by hand
+func TestReadHandWritten(t *testing.T) {
+	got, err := Read(strings.NewReader(`
+# our team's repositories
+version: 1
+repositories:
+- name: web   # the frontend
+  path: "~/code/web"
+  remotes:
+  - name: origin
+    url: 'https://example.com/web.git'
+  worktrees: []
+`))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	want := &Manifest{
+		Version: 1,
+		Repositories: []Repository{{
+			Name:    "web",
+			Path:    "~/code/web",
+			Remotes: []Remote{{Name: "origin", URL: "https://example.com/web.git"}},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read = %+v, want %+v", got, want)
+	}
+}
+
+// TestReadInvalid tests that invalid manifests are usage errors that say
+// what is wrong
+func TestReadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"missing name", "repositories:\n  - path: /x\n", "repositories[0]: name is missing"},
+		{"newer version", "version: 2\n", "newer than this git-manager supports"},
+		{"bad indentation", "repositories:\n  - name: a\n      path: /x\n", "line 3"},
+		{"unknown field", "repositories:\n  - name: a\n    path: /a\n    branch: main\n", "field branch not found"},
+		{"not a string", "repositories:\n  - name: [a]\n    path: /a\n", "line 2"},
+		{"duplicate", "repositories:\n  - name: a\n    path: /a\n  - name: a\n    path: /b\n", "listed twice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.content))
+			if !errs.Is(err, errs.Usage) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected a usage error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Package plan records the changes a mutating command is going to make, so
+// they can either be printed for --dry-run or executed in order.
+package plan
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Step is a single change to the filesystem, a repository or the
+// configuration
+type Step struct {
+	// Description is what --dry-run prints. Commands are shown as the exact
+	// argv prefixed with "$ ", see Command.
+	Description string
+
+	// Progress is printed before the step runs. Leave it empty for steps
+	// that do not need to announce themselves.
+	Progress string
+
+	// Run performs the step
+	Run func() error
+
+	// Undo, when set, reverts the step. It must cope with a step that only
+	// got partway, since it also runs for the step that failed.
+	Undo func() error
+}
+
+// Plan is an ordered list of steps
+type Plan struct {
+	Steps []Step
+}
+
+// Add appends steps to the plan
+func (p *Plan) Add(steps ...Step) {
+	p.Steps = append(p.Steps, steps...)
+}
+
+// Print writes the plan to w without running any step
+func (p *Plan) Print(w io.Writer) {
+	PrintSteps(w, p.Descriptions())
+}
+
+// Descriptions returns the description of every step, in order
+func (p *Plan) Descriptions() []string {
+	descriptions := make([]string, len(p.Steps))
+	for i, step := range p.Steps {
+		descriptions[i] = step.Description
+	}
+	return descriptions
+}
+
+// PrintSteps writes step descriptions the way Print does, for plans that
+// were built elsewhere and only their descriptions survived
+func PrintSteps(w io.Writer, descriptions []string) {
+	fmt.Fprintln(w, "Dry run, nothing will be changed. Planned steps:")
+	for _, description := range descriptions {
+		fmt.Fprintf(w, "  %s\n", description)
+	}
+}
+
+// Execute runs the steps in order and stops at the first failure. The steps
+// that ran, including the failed one, are then rolled back in reverse order,
+// so an interrupted command leaves nothing half done. Steps without Undo are
+// left as they are. Progress messages are written to w.
+func (p *Plan) Execute(w io.Writer) error {
+	for i, step := range p.Steps {
+		if step.Progress != "" {
+			fmt.Fprintln(w, step.Progress)
+		}
+		if err := step.Run(); err != nil {
+			p.rollback(w, p.Steps[:i+1])
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback undoes steps in reverse order. Undo failures are reported to w
+// and do not stop the rollback.
+func (p *Plan) rollback(w io.Writer, steps []Step) {
+	announced := false
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		if step.Undo == nil {
+			continue
+		}
+		if !announced {
+			fmt.Fprintln(w, "Rolling back...")
+			announced = true
+		}
+		if err := step.Undo(); err != nil {
+			fmt.Fprintf(w, "warning: could not undo %q: %v\n", step.Description, err)
+		}
+	}
+}
+
+// Mkdir returns a step that creates path and any missing parents. Undoing
+// it removes the outermost directory it created, with everything later
+// steps put inside.
+func Mkdir(path string) Step {
+	var created string
+	return Step{
+		Description: "create directory " + path,
+		Run: func() error {
+			created = firstMissing(path)
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return fmt.Errorf("error creating directories: %w", err)
+			}
+			return nil
+		},
+		Undo: func() error {
+			if created == "" {
+				return nil
+			}
+			return os.RemoveAll(created)
+		},
+	}
+}
+
+// firstMissing returns the outermost directory of path that does not exist
+// yet, or "" if path exists
+func firstMissing(path string) string {
+	missing := ""
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil {
+			return missing
+		}
+		missing = dir
+		if dir == filepath.Dir(dir) {
+			return missing
+		}
+	}
+}
+
+// Command formats argv as a shell command line, prefixed with "$ ". Arguments
+// are quoted only when the shell would otherwise split or expand them, so the
+// line can be pasted into a shell as is.
+func Command(argv ...string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = quote(arg)
+	}
+	return "$ " + strings.Join(quoted, " ")
+}
+
+// quote returns arg quoted for a POSIX shell if it needs quoting
+func quote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range arg {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+%^", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
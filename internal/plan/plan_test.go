@@ -0,0 +1,126 @@
+package plan
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCommand tests quoting of command lines
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		argv []string
+		want string
+	}{
+		{[]string{"git", "-C", "/ws/.git", "worktree", "add", "/ws/feature/x"}, "$ git -C /ws/.git worktree add /ws/feature/x"},
+		{[]string{"git", "clone", "--bare", "git@github.com:org/api.git"}, "$ git clone --bare git@github.com:org/api.git"},
+		{[]string{"git", "commit", "-m", "it's done"}, `$ git commit -m 'it'\''s done'`},
+		{[]string{"ls", "/My Documents", ""}, "$ ls '/My Documents' ''"},
+		{[]string{"echo", "$HOME", "*"}, "$ echo '$HOME' '*'"},
+	}
+
+	for _, tt := range tests {
+		if got := Command(tt.argv...); got != tt.want {
+			t.Errorf("Command(%q) = %s, want %s", tt.argv, got, tt.want)
+		}
+	}
+}
+
+// TestPrintChangesNothing tests that printing a plan runs none of its steps
+func TestPrintChangesNothing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+
+	var p Plan
+	p.Add(Mkdir(dir), Step{
+		Description: Command("git", "status"),
+		Run: func() error {
+			t.Errorf("Step ran during a dry run")
+			return nil
+		},
+	})
+
+	var out bytes.Buffer
+	p.Print(&out)
+
+	if !strings.Contains(out.String(), "create directory "+dir) || !strings.Contains(out.String(), "$ git status") {
+		t.Errorf("Unexpected plan output:\n%s", out.String())
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected %s not to be created, got %v", dir, err)
+	}
+}
+
+// TestExecute tests that steps run in order and stop at the first failure
+func TestExecute(t *testing.T) {
+	var ran []string
+	step := func(name string, err error) Step {
+		return Step{
+			Description: name,
+			Progress:    "running " + name,
+			Run: func() error {
+				ran = append(ran, name)
+				return err
+			},
+		}
+	}
+
+	boom := errors.New("boom")
+	var p Plan
+	p.Add(step("one", nil), step("two", boom), step("three", nil))
+
+	var out bytes.Buffer
+	if err := p.Execute(&out); !errors.Is(err, boom) {
+		t.Fatalf("Expected the failing step's error, got %v", err)
+	}
+	if strings.Join(ran, ",") != "one,two" {
+		t.Errorf("Expected steps one and two to run, got %v", ran)
+	}
+	if out.String() != "running one\nrunning two\n" {
+		t.Errorf("Unexpected progress output %q", out.String())
+	}
+}
+
+// TestExecuteRollsBack tests that a failure undoes the steps that ran,
+// including the failed one, in reverse order
+func TestExecuteRollsBack(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "ws", "main")
+
+	var undone []string
+	step := func(name string, err error) Step {
+		return Step{
+			Description: name,
+			Run:         func() error { return err },
+			Undo: func() error {
+				undone = append(undone, name)
+				return nil
+			},
+		}
+	}
+
+	boom := errors.New("boom")
+	var p Plan
+	p.Add(Mkdir(dir), step("clone", nil), Step{Description: "no undo", Run: func() error { return nil }}, step("add", boom), step("never", nil))
+
+	var out bytes.Buffer
+	if err := p.Execute(&out); !errors.Is(err, boom) {
+		t.Fatalf("Expected the failing step's error, got %v", err)
+	}
+	if strings.Join(undone, ",") != "add,clone" {
+		t.Errorf("Expected add and clone to be undone in that order, got %v", undone)
+	}
+	if !strings.Contains(out.String(), "Rolling back...") {
+		t.Errorf("Expected the rollback to be announced, got:\n%s", out.String())
+	}
+
+	// Mkdir removes only what it created
+	if _, err := os.Stat(filepath.Join(parent, "ws")); !os.IsNotExist(err) {
+		t.Errorf("Expected the created directories to be removed, got %v", err)
+	}
+	if _, err := os.Stat(parent); err != nil {
+		t.Errorf("Expected the existing parent to be kept: %v", err)
+	}
+}
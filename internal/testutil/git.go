@@ -13,7 +13,7 @@ type GitRepo struct {
 }
 
 // SetupGitRepo creates a new git repository for testing
-func SetupGitRepo(t *testing.T) (*GitRepo, func()) {
+func SetupGitRepo(t testing.TB) (*GitRepo, func()) {
 	t.Helper()
 
 	// Create a temporary directory
@@ -55,7 +55,7 @@ func SetupGitRepo(t *testing.T) (*GitRepo, func()) {
 }
 
 // CreateFile creates a file in the repository with the given content
-func (r *GitRepo) CreateFile(t *testing.T, relativePath, content string) {
+func (r *GitRepo) CreateFile(t testing.TB, relativePath, content string) {
 	t.Helper()
 
 	fullPath := filepath.Join(r.Path, relativePath)
@@ -73,7 +73,7 @@ func (r *GitRepo) CreateFile(t *testing.T, relativePath, content string) {
 }
 
 // AddAndCommit adds and commits the specified files
-func (r *GitRepo) AddAndCommit(t *testing.T, message string, files ...string) {
+func (r *GitRepo) AddAndCommit(t testing.TB, message string, files ...string) {
 	t.Helper()
 
 	// Add files
@@ -91,7 +91,7 @@ func (r *GitRepo) AddAndCommit(t *testing.T, message string, files ...string) {
 }
 
 // CreateBranch creates a new branch
-func (r *GitRepo) CreateBranch(t *testing.T, branchName string) {
+func (r *GitRepo) CreateBranch(t testing.TB, branchName string) {
 	t.Helper()
 
 	cmd := exec.Command("git", "-C", r.Path, "branch", branchName)
@@ -101,7 +101,7 @@ func (r *GitRepo) CreateBranch(t *testing.T, branchName string) {
 }
 
 // Checkout checks out the specified branch or commit
-func (r *GitRepo) Checkout(t *testing.T, ref string) {
+func (r *GitRepo) Checkout(t testing.TB, ref string) {
 	t.Helper()
 
 	cmd := exec.Command("git", "-C", r.Path, "checkout", ref)
@@ -111,7 +111,7 @@ func (r *GitRepo) Checkout(t *testing.T, ref string) {
 }
 
 // CreateWorktree creates a new worktree
-func (r *GitRepo) CreateWorktree(t *testing.T, path, branch string) string {
+func (r *GitRepo) CreateWorktree(t testing.TB, path, branch string) string {
 	t.Helper()
 
 	worktreePath := filepath.Join(r.Path, "..", filepath.Base(path))
@@ -119,6 +119,9 @@ func (r *GitRepo) CreateWorktree(t *testing.T, path, branch string) string {
 	var cmd *exec.Cmd
 	if branch == "" {
 		cmd = exec.Command("git", "-C", r.Path, "worktree", "add", worktreePath)
+	} else if exec.Command("git", "-C", r.Path, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch).Run() == nil {
+		// The branch already exists, so check it out instead of creating it
+		cmd = exec.Command("git", "-C", r.Path, "worktree", "add", worktreePath, branch)
 	} else {
 		cmd = exec.Command("git", "-C", r.Path, "worktree", "add", "-b", branch, worktreePath)
 	}
@@ -131,7 +134,7 @@ func (r *GitRepo) CreateWorktree(t *testing.T, path, branch string) string {
 }
 
 // RunGit runs a git command in the repository and returns its output
-func (r *GitRepo) RunGit(t *testing.T, args ...string) string {
+func (r *GitRepo) RunGit(t testing.TB, args ...string) string {
 	t.Helper()
 
 	cmdArgs := append([]string{"-C", r.Path}, args...)
@@ -146,7 +149,7 @@ func (r *GitRepo) RunGit(t *testing.T, args ...string) string {
 }
 
 // AssertBranchExists checks if a branch exists
-func (r *GitRepo) AssertBranchExists(t *testing.T, branch string) {
+func (r *GitRepo) AssertBranchExists(t testing.TB, branch string) {
 	t.Helper()
 
 	cmd := exec.Command("git", "-C", r.Path, "rev-parse", "--verify", branch)
@@ -156,7 +159,7 @@ func (r *GitRepo) AssertBranchExists(t *testing.T, branch string) {
 }
 
 // AssertFileContent checks if a file has the expected content
-func (r *GitRepo) AssertFileContent(t *testing.T, relativePath, expectedContent string) {
+func (r *GitRepo) AssertFileContent(t testing.TB, relativePath, expectedContent string) {
 	t.Helper()
 
 	fullPath := filepath.Join(r.Path, relativePath)
@@ -0,0 +1,228 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+)
+
+// FakeRunner is a git.GitRunner that answers invocations from scripted rules
+// instead of running git, and records every invocation it receives. It lets
+// tests reach failure paths such as a missing git, an old git or a locked
+// index deterministically.
+type FakeRunner struct {
+	// Fallback handles invocations that no rule matches. When nil, an
+	// unmatched invocation fails the test.
+	Fallback git.GitRunner
+
+	t     *testing.T
+	mu    sync.Mutex
+	rules []*FakeRule
+	calls []git.Invocation
+}
+
+// FakeRule is a scripted answer to matching invocations
+type FakeRule struct {
+	args   []string
+	exact  bool
+	times  int
+	used   int
+	result git.Result
+	cause  error
+	hang   bool
+}
+
+// NewFakeRunner returns a FakeRunner with no rules
+func NewFakeRunner(t *testing.T) *FakeRunner {
+	return &FakeRunner{t: t}
+}
+
+// On adds a rule for invocations whose arguments contain args as a
+// contiguous run, so On("worktree", "add") matches
+// "git -C /ws/.git worktree add -b x /ws/x main". Rules are tried in the
+// order they were added. A new rule succeeds with empty output until told
+// otherwise.
+func (f *FakeRunner) On(args ...string) *FakeRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rule := &FakeRule{args: args}
+	f.rules = append(f.rules, rule)
+	return rule
+}
+
+// Return makes the rule succeed with stdout
+func (r *FakeRule) Return(stdout string) *FakeRule {
+	r.result = git.Result{Stdout: stdout}
+	r.cause = nil
+	return r
+}
+
+// Fail makes the rule fail with the given exit code and stderr
+func (r *FakeRule) Fail(exitCode int, stderr string) *FakeRule {
+	r.result = git.Result{Stderr: stderr, ExitCode: exitCode}
+	return r
+}
+
+// IndexLocked makes the rule fail the way git does when another git process
+// holds the index lock of gitDir
+func (r *FakeRule) IndexLocked(gitDir string) *FakeRule {
+	return r.Fail(128, fmt.Sprintf("fatal: Unable to create '%s/index.lock': File exists.\n\n"+
+		"Another git process seems to be running in this repository, e.g.\n"+
+		"an editor opened by 'git commit'. Please make sure all processes\n"+
+		"are terminated then try again. If it still fails, a git process\n"+
+		"may have crashed in this repository earlier:\n"+
+		"remove the file manually to continue.\n", gitDir))
+}
+
+// Hang makes the rule block until the invocation's context is done, like a
+// git process stuck on the network or a credential prompt
+func (r *FakeRule) Hang() *FakeRule {
+	r.hang = true
+	return r
+}
+
+// Times limits the rule to the first n matching invocations
+func (r *FakeRule) Times(n int) *FakeRule {
+	r.times = n
+	return r
+}
+
+// matches reports whether the rule answers args
+func (r *FakeRule) matches(args []string) bool {
+	if r.times > 0 && r.used >= r.times {
+		return false
+	}
+	if r.exact {
+		return slices.Equal(r.args, args)
+	}
+	for i := 0; i+len(r.args) <= len(args); i++ {
+		if slices.Equal(args[i:i+len(r.args)], r.args) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run implements git.GitRunner
+func (f *FakeRunner) Run(ctx context.Context, inv git.Invocation) (git.Result, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, inv)
+	var rule *FakeRule
+	for _, r := range f.rules {
+		if r.matches(inv.Args) {
+			r.used++
+			rule = r
+			break
+		}
+	}
+	f.mu.Unlock()
+
+	if rule == nil {
+		if f.Fallback != nil {
+			return f.Fallback.Run(ctx, inv)
+		}
+		f.t.Errorf("Unexpected git invocation: git %s", strings.Join(inv.Args, " "))
+		res := git.Result{Stderr: "fatal: unexpected invocation in test\n", ExitCode: 128}
+		return res, git.NewError(inv, res, nil)
+	}
+
+	if rule.hang {
+		<-ctx.Done()
+		res := git.Result{ExitCode: -1}
+		return res, git.NewError(inv, res, ctx.Err())
+	}
+
+	res := rule.result
+	if inv.Stdout != nil {
+		inv.Stdout.Write([]byte(res.Stdout))
+	}
+	if inv.Stderr != nil {
+		inv.Stderr.Write([]byte(res.Stderr))
+	}
+	if res.ExitCode != 0 {
+		return res, git.NewError(inv, res, rule.cause)
+	}
+	return res, nil
+}
+
+// Calls returns every invocation the runner received, in order
+func (f *FakeRunner) Calls() []git.Invocation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// CalledWith reports whether any invocation contained args as a contiguous run
+func (f *FakeRunner) CalledWith(args ...string) bool {
+	probe := &FakeRule{args: args}
+	for _, call := range f.Calls() {
+		if probe.matches(call.Args) {
+			return true
+		}
+	}
+	return false
+}
+
+// Recorder is a git.GitRunner that passes invocations to another runner and
+// records them with their results, so they can be replayed later
+type Recorder struct {
+	Runner git.GitRunner
+
+	mu      sync.Mutex
+	records []Record
+}
+
+// Record is an invocation and the result it produced
+type Record struct {
+	Invocation git.Invocation
+	Result     git.Result
+}
+
+// Run implements git.GitRunner
+func (r *Recorder) Run(ctx context.Context, inv git.Invocation) (git.Result, error) {
+	res, err := r.Runner.Run(ctx, inv)
+
+	r.mu.Lock()
+	r.records = append(r.records, Record{Invocation: inv, Result: res})
+	r.mu.Unlock()
+
+	return res, err
+}
+
+// Records returns the recorded invocations in order
+func (r *Recorder) Records() []Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.records)
+}
+
+// Replay returns a FakeRunner that answers each recorded invocation once, in
+// order, with the result it produced when it was recorded
+func (r *Recorder) Replay(t *testing.T) *FakeRunner {
+	f := NewFakeRunner(t)
+	for _, rec := range r.Records() {
+		rule := &FakeRule{args: rec.Invocation.Args, exact: true, times: 1, result: rec.Result}
+		f.rules = append(f.rules, rule)
+	}
+	return f
+}
+
+// GitNotInstalled returns a runner that behaves as if git is not on PATH
+func GitNotInstalled() git.GitRunner {
+	return &git.ExecRunner{Path: "git-manager-test-no-such-git"}
+}
+
+// GitVersion returns a runner that reports the given git version and runs
+// every other invocation with the real git binary
+func GitVersion(t *testing.T, version string) *FakeRunner {
+	f := NewFakeRunner(t)
+	f.Fallback = &git.ExecRunner{}
+	f.On("--version").Return("git version " + version + "\n")
+	return f
+}
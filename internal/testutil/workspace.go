@@ -0,0 +1,96 @@
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Workspace represents a git-manager workspace: a bare repository in
+// <Root>/.git with worktrees checked out next to it
+type Workspace struct {
+	// Root is the workspace directory
+	Root string
+
+	// GitDir is the bare repository at <Root>/.git
+	GitDir string
+
+	// Origin is the repository the workspace was cloned from
+	Origin *GitRepo
+
+	// TempDir is the temporary directory containing the workspace and origin
+	TempDir string
+}
+
+// SetupWorkspace creates a workspace the way `git-manager repository init`
+// lays it out: an origin repository with a commit on main, a bare clone of it
+// in <Root>/.git and a worktree for main in <Root>/main
+func SetupWorkspace(t testing.TB) (*Workspace, func()) {
+	t.Helper()
+
+	// Create a temporary directory
+	tempDir, err := os.MkdirTemp("", "git-manager-workspace-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+
+	// Resolve symlinks so paths match what git reports
+	tempDir, err = filepath.EvalSymlinks(tempDir)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		t.Fatalf("Failed to resolve temp directory: %v", err)
+	}
+
+	cleanup := func() {
+		os.RemoveAll(tempDir)
+	}
+
+	// Create the origin repository with main as its default branch
+	origin := &GitRepo{Path: filepath.Join(tempDir, "origin")}
+	if out, err := NewCommand("git", "init", origin.Path).CombinedOutput(); err != nil {
+		cleanup()
+		t.Fatalf("Failed to initialize origin repository: %v\nOutput: %s", err, out)
+	}
+	origin.RunGit(t, "symbolic-ref", "HEAD", "refs/heads/main")
+	origin.RunGit(t, "config", "user.name", "Test User")
+	origin.RunGit(t, "config", "user.email", "test@example.com")
+	origin.CreateFile(t, "README.md", "# Test Repository\n")
+	origin.AddAndCommit(t, "Initial commit", "README.md")
+
+	// Clone it into the workspace layout
+	root := filepath.Join(tempDir, "workspace")
+	gitDir := filepath.Join(root, ".git")
+	if out, err := NewCommand("git", "clone", "--bare", origin.Path, gitDir).CombinedOutput(); err != nil {
+		cleanup()
+		t.Fatalf("Failed to clone origin: %v\nOutput: %s", err, out)
+	}
+
+	bare := &GitRepo{Path: gitDir}
+	bare.RunGit(t, "config", "user.name", "Test User")
+	bare.RunGit(t, "config", "user.email", "test@example.com")
+	bare.RunGit(t, "worktree", "add", filepath.Join(root, "main"), "main")
+
+	ws := &Workspace{
+		Root:    root,
+		GitDir:  gitDir,
+		Origin:  origin,
+		TempDir: tempDir,
+	}
+
+	return ws, cleanup
+}
+
+// Worktree returns the worktree directory with the given name
+func (w *Workspace) Worktree(name string) *GitRepo {
+	return &GitRepo{Path: filepath.Join(w.Root, name)}
+}
+
+// AddWorktree creates a worktree on a new branch based on main
+func (w *Workspace) AddWorktree(t testing.TB, branch string) *GitRepo {
+	t.Helper()
+
+	bare := &GitRepo{Path: w.GitDir}
+	bare.RunGit(t, "worktree", "add", "-b", branch, filepath.Join(w.Root, branch), "main")
+
+	return w.Worktree(branch)
+}
@@ -0,0 +1,120 @@
+package worktree
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/internal/git"
+)
+
+// Names of the backends, as used in the configuration file
+const (
+	BackendCLI    = "cli"
+	BackendNative = "native"
+)
+
+// Backend answers the read-only questions git-manager asks about
+// repositories. Anything that changes a repository always goes through git.
+type Backend interface {
+	// GetWorktreeInfo lists the worktrees of the repository containing dir,
+	// in the order `git worktree list` prints them
+	GetWorktreeInfo(ctx context.Context, dir string) ([]Info, error)
+
+	// IsBareRepository checks if dir is in a bare git repository
+	IsBareRepository(ctx context.Context, dir string) bool
+
+	// IsWorktree checks if dir is inside a worktree
+	IsWorktree(ctx context.Context, dir string) bool
+}
+
+// NewBackend returns the backend called name. An empty name is the CLI
+// backend. The native backend falls back to the CLI backend, using r, for
+// repositories it cannot read. features, which may be nil, lets the CLI
+// backend use newer git output formats.
+func NewBackend(name string, r git.GitRunner, features *git.FeatureDetector) (Backend, error) {
+	cli := &CLIBackend{Git: r, Features: features}
+	switch name {
+	case "", BackendCLI:
+		return cli, nil
+	case BackendNative:
+		return &NativeBackend{Fallback: cli}, nil
+	default:
+		return nil, errs.New(errs.Usage, "unknown backend %q, use %q or %q", name, BackendCLI, BackendNative)
+	}
+}
+
+// IsRepository checks if dir is in a bare repository or a worktree
+func IsRepository(ctx context.Context, b Backend, dir string) bool {
+	return b.IsBareRepository(ctx, dir) || b.IsWorktree(ctx, dir)
+}
+
+// CLIBackend answers by running git
+type CLIBackend struct {
+	Git git.GitRunner
+
+	// Features, when set, is used to list worktrees NUL-separated where git
+	// supports it, which keeps paths with newlines intact
+	Features *git.FeatureDetector
+}
+
+// GetWorktreeInfo implements Backend
+func (b *CLIBackend) GetWorktreeInfo(ctx context.Context, dir string) ([]Info, error) {
+	z := false
+	if b.Features != nil {
+		if f, err := b.Features.Features(ctx); err == nil {
+			z = f.Has(git.PorcelainZ)
+		}
+	}
+	return listWorktrees(ctx, b.Git, dir, z)
+}
+
+// IsBareRepository implements Backend
+func (b *CLIBackend) IsBareRepository(ctx context.Context, dir string) bool {
+	return IsBareRepository(ctx, b.Git, dir)
+}
+
+// IsWorktree implements Backend
+func (b *CLIBackend) IsWorktree(ctx context.Context, dir string) bool {
+	return IsWorktree(ctx, b.Git, dir)
+}
+
+// NativeBackend answers by reading the repository's files directly, without
+// starting a process. Repositories using features it does not understand,
+// such as the reftable ref format, are passed to Fallback.
+type NativeBackend struct {
+	Fallback Backend
+}
+
+// GetWorktreeInfo implements Backend
+func (b *NativeBackend) GetWorktreeInfo(ctx context.Context, dir string) ([]Info, error) {
+	repo, err := openRepository(dir)
+	if err == nil {
+		var worktrees []Info
+		if worktrees, err = repo.worktrees(); err == nil {
+			return worktrees, nil
+		}
+	}
+	if errors.Is(err, errUnsupported) || errs.Is(err, errs.NotInRepo) {
+		return b.Fallback.GetWorktreeInfo(ctx, dir)
+	}
+	return nil, err
+}
+
+// IsBareRepository implements Backend
+func (b *NativeBackend) IsBareRepository(ctx context.Context, dir string) bool {
+	repo, err := openRepository(dir)
+	if err != nil {
+		return b.Fallback.IsBareRepository(ctx, dir)
+	}
+	return repo.bare && !repo.inWorktree
+}
+
+// IsWorktree implements Backend
+func (b *NativeBackend) IsWorktree(ctx context.Context, dir string) bool {
+	repo, err := openRepository(dir)
+	if err != nil {
+		return b.Fallback.IsWorktree(ctx, dir)
+	}
+	return repo.inWorktree
+}
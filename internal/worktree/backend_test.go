@@ -0,0 +1,147 @@
+package worktree
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+	"github.com/ingshtrom/git-manager/internal/testutil"
+)
+
+// setupBackendWorkspace returns a workspace exercising everything the
+// backends report: nested branch names, a detached HEAD, a locked worktree,
+// a prunable worktree and packed refs
+func setupBackendWorkspace(t testing.TB) (*testutil.Workspace, func()) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+
+	bare := &testutil.GitRepo{Path: ws.GitDir}
+	ws.AddWorktree(t, "feature/login")
+	bare.RunGit(t, "pack-refs", "--all")
+	ws.AddWorktree(t, "locked")
+	bare.RunGit(t, "worktree", "lock", "--reason", "on a usb stick", filepath.Join(ws.Root, "locked"))
+	bare.RunGit(t, "worktree", "add", "--detach", filepath.Join(ws.Root, "detached"), "main")
+	ws.AddWorktree(t, "gone")
+	if err := os.RemoveAll(filepath.Join(ws.Root, "gone")); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	return ws, cleanup
+}
+
+// TestNativeBackendMatchesCLI tests that both backends give the same answers
+func TestNativeBackendMatchesCLI(t *testing.T) {
+	ws, cleanup := setupBackendWorkspace(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	cli := &CLIBackend{Git: &git.ExecRunner{}}
+	native := &NativeBackend{Fallback: &CLIBackend{Git: testutil.NewFakeRunner(t)}}
+
+	for _, dir := range []string{ws.Root, ws.GitDir, filepath.Join(ws.Root, "main"), filepath.Join(ws.Root, "feature/login")} {
+		want, err := cli.GetWorktreeInfo(ctx, dir)
+		if err != nil {
+			t.Fatalf("CLI GetWorktreeInfo(%s) failed: %v", dir, err)
+		}
+		got, err := native.GetWorktreeInfo(ctx, dir)
+		if err != nil {
+			t.Fatalf("native GetWorktreeInfo(%s) failed: %v", dir, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetWorktreeInfo(%s):\nnative %+v\ncli    %+v", dir, got, want)
+		}
+
+		if got, want := native.IsBareRepository(ctx, dir), cli.IsBareRepository(ctx, dir); got != want {
+			t.Errorf("IsBareRepository(%s) = %v, CLI says %v", dir, got, want)
+		}
+		if got, want := native.IsWorktree(ctx, dir), cli.IsWorktree(ctx, dir); got != want {
+			t.Errorf("IsWorktree(%s) = %v, CLI says %v", dir, got, want)
+		}
+	}
+}
+
+// TestNativeBackendFallback tests that unreadable repositories go to git
+func TestNativeBackendFallback(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	(&testutil.GitRepo{Path: ws.GitDir}).RunGit(t, "config", "extensions.refStorage", "reftable")
+
+	fake := testutil.NewFakeRunner(t)
+	fake.On("worktree", "list").Return("worktree " + ws.Root + "\nbare\n\n")
+	native := &NativeBackend{Fallback: &CLIBackend{Git: fake}}
+
+	got, err := native.GetWorktreeInfo(context.Background(), ws.Root)
+	if err != nil {
+		t.Fatalf("GetWorktreeInfo failed: %v", err)
+	}
+	if len(got) != 1 || !fake.CalledWith("worktree", "list") {
+		t.Errorf("Expected the fallback to answer, got %+v", got)
+	}
+}
+
+// TestNewBackend tests selecting a backend by name
+func TestNewBackend(t *testing.T) {
+	for name, want := range map[string]Backend{"": &CLIBackend{}, "cli": &CLIBackend{}, "native": &NativeBackend{}} {
+		b, err := NewBackend(name, nil, nil)
+		if err != nil {
+			t.Fatalf("NewBackend(%q) failed: %v", name, err)
+		}
+		if reflect.TypeOf(b) != reflect.TypeOf(want) {
+			t.Errorf("NewBackend(%q) = %T, want %T", name, b, want)
+		}
+	}
+	if _, err := NewBackend("go-git", nil, nil); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+}
+
+// BenchmarkGetWorktreeInfo compares listing worktrees through git with
+// reading the repository directly
+func BenchmarkGetWorktreeInfo(b *testing.B) {
+	ws, cleanup := setupBackendWorkspace(b)
+	defer cleanup()
+	ctx := context.Background()
+
+	backends := map[string]Backend{
+		BackendCLI:    &CLIBackend{Git: &git.ExecRunner{}},
+		BackendNative: &NativeBackend{},
+	}
+	for name, backend := range backends {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := backend.GetWorktreeInfo(ctx, ws.Root); err != nil {
+					b.Fatal(err)
+				}
+				if !IsRepository(ctx, backend, ws.Root) {
+					b.Fatal("workspace not recognized as a repository")
+				}
+			}
+		})
+	}
+}
+
+// TestCLIBackendNulSeparated tests that the CLI backend lists worktrees with
+// -z where git supports it, keeping unusual paths intact
+func TestCLIBackendNulSeparated(t *testing.T) {
+	fake := testutil.NewFakeRunner(t)
+	fake.On("--version").Return("git version 2.36.0\n")
+	fake.On("worktree", "list", "--porcelain", "-z").Return("worktree /ws\x00bare\x00\x00" +
+		"worktree /ws/odd\nname \x00HEAD 0123456789012345678901234567890123456789\x00branch refs/heads/odd\x00locked\x00\x00")
+
+	b := &CLIBackend{Git: fake, Features: &git.FeatureDetector{Git: fake}}
+	got, err := b.GetWorktreeInfo(context.Background(), "/ws")
+	if err != nil {
+		t.Fatalf("GetWorktreeInfo failed: %v", err)
+	}
+
+	want := []Info{
+		{Path: "/ws", IsBare: true},
+		{Path: "/ws/odd\nname ", Branch: "odd", Commit: "0123456789012345678901234567890123456789", Locked: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetWorktreeInfo:\ngot  %+v\nwant %+v", got, want)
+	}
+}
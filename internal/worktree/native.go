@@ -0,0 +1,318 @@
+package worktree
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+)
+
+// errUnsupported reports a repository the native backend cannot read. The
+// caller falls back to git.
+var errUnsupported = errors.New("repository format not supported by the native backend")
+
+// nullCommit is what git reports as the commit of an unborn branch
+const nullCommit = "0000000000000000000000000000000000000000"
+
+// maxSymrefDepth bounds how many symbolic refs are followed, like git does
+const maxSymrefDepth = 5
+
+// nativeRepo is a repository as found on disk
+type nativeRepo struct {
+	// gitDir is the git directory of the worktree dir is in, which is
+	// .git/worktrees/<id> for linked worktrees
+	gitDir string
+
+	// commonDir holds the objects, refs and config shared by all worktrees
+	commonDir string
+
+	// bare is core.bare from the repository config
+	bare bool
+
+	// inWorktree is set when dir is inside a worktree's checkout rather than
+	// inside a git directory or a bare repository
+	inWorktree bool
+}
+
+// openRepository discovers the repository containing dir the way git does:
+// each directory up to the root is checked for a .git file or directory,
+// then for being a git directory itself
+func openRepository(dir string) (*nativeRepo, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() {
+				return newNativeRepo(dotGit, true)
+			}
+			gitDir, err := readGitFile(dotGit)
+			if err != nil {
+				return nil, err
+			}
+			return newNativeRepo(gitDir, true)
+		}
+		if isGitDir(dir) {
+			return newNativeRepo(dir, false)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, errs.New(errs.NotInRepo, "not in a git repository")
+		}
+		dir = parent
+	}
+}
+
+// newNativeRepo reads the repository configuration of gitDir. checkout says
+// whether gitDir was found through a .git entry of a checkout.
+func newNativeRepo(gitDir string, checkout bool) (*nativeRepo, error) {
+	repo := &nativeRepo{gitDir: gitDir, commonDir: gitDir}
+
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		repo.commonDir = filepath.Clean(commonDir)
+	}
+
+	cfg, err := readConfig(filepath.Join(repo.commonDir, "config"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the files backend with SHA-1 objects is read natively
+	if format := cfg["extensions.refstorage"]; format != "" && format != "files" {
+		return nil, errUnsupported
+	}
+	if format := cfg["extensions.objectformat"]; format != "" && format != "sha1" {
+		return nil, errUnsupported
+	}
+
+	repo.bare = configBool(cfg["core.bare"])
+	linked := repo.gitDir != repo.commonDir
+	repo.inWorktree = checkout && (linked || !repo.bare)
+	return repo, nil
+}
+
+// worktrees lists the main worktree followed by the linked worktrees sorted
+// by path, matching `git worktree list`
+func (r *nativeRepo) worktrees() ([]Info, error) {
+	// git names the main worktree after the common dir without its /.git
+	mainPath := r.commonDir
+	if real, err := filepath.EvalSymlinks(mainPath); err == nil {
+		mainPath = real
+	}
+	main := Info{Path: strings.TrimSuffix(mainPath, string(filepath.Separator)+".git"), IsBare: r.bare}
+	if !r.bare {
+		if err := r.readHead(r.commonDir, &main); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(r.commonDir, "worktrees"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading worktrees: %w", err)
+	}
+
+	var linked []Info
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		adminDir := filepath.Join(r.commonDir, "worktrees", entry.Name())
+
+		data, err := os.ReadFile(filepath.Join(adminDir, "gitdir"))
+		if err != nil {
+			// git skips entries without a gitdir file too
+			continue
+		}
+		dotGit := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(dotGit) {
+			dotGit = filepath.Join(adminDir, dotGit)
+		}
+
+		wt := Info{Path: filepath.Dir(filepath.Clean(dotGit))}
+		if err := r.readHead(adminDir, &wt); err != nil {
+			return nil, err
+		}
+		if reason, err := os.ReadFile(filepath.Join(adminDir, "locked")); err == nil {
+			wt.Locked = true
+			wt.LockReason = strings.TrimSpace(string(reason))
+		}
+		if _, err := os.Stat(dotGit); err != nil && !wt.Locked {
+			wt.Prunable = true
+		}
+		linked = append(linked, wt)
+	}
+
+	sort.Slice(linked, func(i, j int) bool { return linked[i].Path < linked[j].Path })
+	return append([]Info{main}, linked...), nil
+}
+
+// readHead fills the branch and commit of wt from the HEAD in gitDir
+func (r *nativeRepo) readHead(gitDir string, wt *Info) error {
+	data, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return fmt.Errorf("error reading HEAD: %w", err)
+	}
+	head := strings.TrimSpace(string(data))
+
+	ref, symbolic := strings.CutPrefix(head, "ref: ")
+	if !symbolic {
+		// Detached HEAD
+		wt.Commit = head
+		return nil
+	}
+
+	wt.Branch = strings.TrimPrefix(ref, "refs/heads/")
+	commit, err := r.resolveRef(ref)
+	if errs.Is(err, errs.NotFound) {
+		// The branch is unborn
+		commit = nullCommit
+	} else if err != nil {
+		return err
+	}
+	wt.Commit = commit
+	return nil
+}
+
+// resolveRef returns the commit ref points to, following symbolic refs.
+// Branches live in the common dir; loose refs win over packed refs.
+func (r *nativeRepo) resolveRef(ref string) (string, error) {
+	for depth := 0; depth < maxSymrefDepth; depth++ {
+		value, err := r.readRef(ref)
+		if err != nil {
+			return "", err
+		}
+		next, symbolic := strings.CutPrefix(value, "ref: ")
+		if !symbolic {
+			return value, nil
+		}
+		ref = next
+	}
+	return "", fmt.Errorf("too many levels of symbolic refs resolving %s", ref)
+}
+
+// readRef returns the raw value of ref
+func (r *nativeRepo) readRef(ref string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(r.commonDir, filepath.FromSlash(ref)))
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("error reading ref %s: %w", ref, err)
+	}
+
+	f, err := os.Open(filepath.Join(r.commonDir, "packed-refs"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", errs.New(errs.NotFound, "ref %s not found", ref)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading packed refs: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		if hash, name, ok := strings.Cut(line, " "); ok && name == ref {
+			return hash, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading packed refs: %w", err)
+	}
+	return "", errs.New(errs.NotFound, "ref %s not found", ref)
+}
+
+// readGitFile returns the git directory a .git file points to
+func readGitFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading .git file: %w", err)
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return "", fmt.Errorf("invalid .git file %s", path)
+	}
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	return filepath.Clean(gitDir), nil
+}
+
+// isGitDir reports whether dir looks like a git directory
+func isGitDir(dir string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// IsBareGitDir reports whether the repository in gitDir has core.bare set,
+// as the .git directory of a workspace does
+func IsBareGitDir(gitDir string) bool {
+	cfg, err := readConfig(filepath.Join(gitDir, "config"))
+	return err == nil && configBool(cfg["core.bare"])
+}
+
+// configBool reports whether a value read by readConfig is a true boolean
+func configBool(value string) bool {
+	switch value {
+	case "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
+
+// readConfig reads the settings of a git config file that the native backend
+// needs, as lower-case "section.key" names. Includes, subsections and
+// multi-valued keys are not needed and not supported.
+func readConfig(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading git config: %w", err)
+	}
+	defer f.Close()
+
+	cfg := map[string]string{}
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[':
+			name, _, _ := strings.Cut(strings.Trim(line, "[]"), " ")
+			section = strings.ToLower(name)
+		default:
+			key, value, hasValue := strings.Cut(line, "=")
+			key = strings.ToLower(strings.TrimSpace(key))
+			value = strings.TrimSpace(value)
+			if !hasValue {
+				// A key without a value is a boolean true
+				value = "true"
+			}
+			cfg[section+"."+key] = strings.ToLower(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading git config: %w", err)
+	}
+	return cfg, nil
+}
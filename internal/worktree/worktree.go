@@ -1,11 +1,14 @@
 package worktree
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/internal/git"
 )
 
 type Repository struct {
@@ -20,25 +23,48 @@ type Info struct {
 	Branch     string
 	Commit     string
 	IsBare     bool
+
+	// Locked is set for worktrees locked with `git worktree lock`, with the
+	// optional reason in LockReason
+	Locked     bool
+	LockReason string
+
+	// Prunable is set when the worktree's directory is gone and
+	// `git worktree prune` would remove its entry
+	Prunable bool
 }
 
 // GetWorktreeInfo returns information about all worktrees in the repository
 // dir can be a .git directory or anywhere `git` commands can be run
-func GetWorktreeInfo(dir string) ([]Info, error) {
+func GetWorktreeInfo(ctx context.Context, r git.GitRunner, dir string) ([]Info, error) {
+	return listWorktrees(ctx, r, dir, false)
+}
+
+// listWorktrees runs `git worktree list --porcelain`, NUL-separated when z is
+// set, and parses its output
+func listWorktrees(ctx context.Context, r git.GitRunner, dir string, z bool) ([]Info, error) {
+	args := []string{"worktree", "list", "--porcelain"}
+	sep := "\n"
+	if z {
+		args = append(args, "-z")
+		sep = "\x00"
+	}
+
 	// Run git worktree list command with porcelain output
-	cmd := exec.Command("git", "-C", dir, "worktree", "list", "--porcelain")
-	output, err := cmd.Output()
+	output, err := git.Output(ctx, r, dir, args...)
 	if err != nil {
-		return nil, fmt.Errorf("error listing worktrees: %v", err)
+		return nil, errs.Annotate(errs.GitFailure, err, "error listing worktrees")
 	}
 
 	// Parse the output
 	var worktrees []Info
 	var currentWorktree *Info
 
-	lines := strings.Split(string(output), "\n")
+	lines := strings.Split(output, sep)
 	for _, line := range lines {
-		line = strings.TrimSpace(line)
+		if !z {
+			line = strings.TrimSpace(line)
+		}
 		if line == "" {
 			if currentWorktree != nil {
 				worktrees = append(worktrees, *currentWorktree)
@@ -58,17 +84,18 @@ func GetWorktreeInfo(dir string) ([]Info, error) {
 		} else if currentWorktree != nil {
 			if strings.HasPrefix(line, "branch ") {
 				branch := strings.TrimPrefix(line, "branch ")
-				// The branch is usually in the format "refs/heads/branch-name"
-				parts := strings.Split(branch, "/")
-				if len(parts) > 0 {
-					currentWorktree.Branch = parts[len(parts)-1]
-				} else {
-					currentWorktree.Branch = branch
-				}
+				// The branch is usually in the format "refs/heads/branch-name",
+				// and the branch name itself may contain slashes
+				currentWorktree.Branch = strings.TrimPrefix(branch, "refs/heads/")
 			} else if strings.HasPrefix(line, "HEAD ") {
 				currentWorktree.Commit = strings.TrimPrefix(line, "HEAD ")
 			} else if strings.HasPrefix(line, "bare") {
 				currentWorktree.IsBare = true
+			} else if line == "locked" || strings.HasPrefix(line, "locked ") {
+				currentWorktree.Locked = true
+				currentWorktree.LockReason = strings.TrimPrefix(strings.TrimPrefix(line, "locked"), " ")
+			} else if strings.HasPrefix(line, "prunable") {
+				currentWorktree.Prunable = true
 			}
 		}
 	}
@@ -80,28 +107,73 @@ func GetWorktreeInfo(dir string) ([]Info, error) {
 	return worktrees, nil
 }
 
+// Find returns the worktree called name. A worktree matches when the last
+// element of its path or its branch equals name; a path match wins over a
+// branch match. It returns a NotFound error when nothing matches and an
+// Ambiguous error when several worktrees match equally well.
+func Find(worktrees []Info, name string) (Info, error) {
+	var byPath, byBranch []Info
+	for _, wt := range worktrees {
+		if wt.IsBare {
+			continue
+		}
+		if filepath.Base(wt.Path) == name {
+			byPath = append(byPath, wt)
+		} else if wt.Branch == name {
+			byBranch = append(byBranch, wt)
+		}
+	}
+
+	matches := byPath
+	if len(matches) == 0 {
+		matches = byBranch
+	}
+
+	switch len(matches) {
+	case 0:
+		return Info{}, errs.New(errs.NotFound, "worktree %q not found", name)
+	case 1:
+		return matches[0], nil
+	default:
+		paths := make([]string, len(matches))
+		for i, wt := range matches {
+			paths[i] = wt.Path
+		}
+		return Info{}, errs.New(errs.Ambiguous, "worktree name %q is ambiguous, it matches: %s", name, strings.Join(paths, ", "))
+	}
+}
+
+// IsDirty reports whether the worktree at dir has uncommitted changes,
+// including untracked files
+func IsDirty(ctx context.Context, r git.GitRunner, dir string) (bool, error) {
+	output, err := git.Output(ctx, r, dir, "status", "--porcelain")
+	if err != nil {
+		return false, errs.Annotate(errs.GitFailure, err, "error checking worktree status")
+	}
+	return len(strings.TrimSpace(output)) > 0, nil
+}
+
 // IsGitRepository checks if the given directory is a git repository
-func IsGitRepository(dir string) bool {
-	return IsBareRepository(dir) || IsWorktree(dir)
+func IsGitRepository(ctx context.Context, r git.GitRunner, dir string) bool {
+	return IsBareRepository(ctx, r, dir) || IsWorktree(ctx, r, dir)
 }
 
-func IsBareRepository(dir string) bool {
-	cmd := exec.Command("git", "-C", dir, "rev-parse", "--is-bare-repository")
-	output, err := cmd.Output()
+// IsBareRepository checks if the given directory is a bare git repository
+func IsBareRepository(ctx context.Context, r git.GitRunner, dir string) bool {
+	output, err := git.Output(ctx, r, dir, "rev-parse", "--is-bare-repository")
 	if err != nil {
 		return false
 	}
-	return strings.TrimSpace(string(output)) == "true"
+	return strings.TrimSpace(output) == "true"
 }
 
 // IsWorktree checks if the given directory is a git worktree
-func IsWorktree(dir string) bool {
-	cmd := exec.Command("git", "-C", dir, "rev-parse", "--is-inside-work-tree")
-	output, err := cmd.Output()
+func IsWorktree(ctx context.Context, r git.GitRunner, dir string) bool {
+	output, err := git.Output(ctx, r, dir, "rev-parse", "--is-inside-work-tree")
 	if err != nil {
 		return false
 	}
-	return strings.TrimSpace(string(output)) == "true"
+	return strings.TrimSpace(output) == "true"
 }
 
 // FindGitDir attempts to find the .git directory by traversing up the directory tree
@@ -128,6 +200,11 @@ func FindGitDir(startDir string) (string, error) {
 					gitdirPath = filepath.Join(currentDir, gitdirPath)
 				}
 
+				// The path may be stale when the workspace was moved by hand
+				if _, err := os.Stat(gitdirPath); err != nil {
+					return findMovedGitDir(currentDir, gitdirPath)
+				}
+
 				// The path usually points to .git/worktrees/branch, we need to go up two levels
 				return filepath.Dir(filepath.Dir(gitdirPath)), nil
 			}
@@ -141,7 +218,7 @@ func FindGitDir(startDir string) (string, error) {
 	for {
 		// Check if we've reached the root directory
 		if currentDir == filepath.Dir(currentDir) {
-			return "", fmt.Errorf("not in a git repository or git-manager workspace")
+			return "", errs.New(errs.NotInRepo, "not in a git repository or git-manager workspace")
 		}
 
 		// Move up one directory
@@ -154,3 +231,19 @@ func FindGitDir(startDir string) (string, error) {
 		}
 	}
 }
+
+// findMovedGitDir finds the repository of the worktree at dir whose .git
+// file points to gitdirPath, which no longer exists. It looks for a .git
+// directory above dir with the same worktree entry, which is where the
+// repository is when the whole workspace was moved. The links still need
+// repairing, but commands can find the workspace to do it.
+func findMovedGitDir(dir string, gitdirPath string) (string, error) {
+	entry := filepath.Base(gitdirPath)
+	for current := filepath.Dir(dir); current != filepath.Dir(current); current = filepath.Dir(current) {
+		gitDir := filepath.Join(current, ".git")
+		if info, err := os.Stat(filepath.Join(gitDir, "worktrees", entry)); err == nil && info.IsDir() {
+			return gitDir, nil
+		}
+	}
+	return "", errs.New(errs.NotInRepo, "the .git file in %s points to %s, which does not exist; if the workspace was moved, run `git-manager tool doctor --fix` in its new location", dir, gitdirPath)
+}
@@ -1,10 +1,17 @@
 package worktree
 
 import (
+	"context"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/internal/git"
+	"github.com/ingshtrom/git-manager/internal/testutil"
 )
 
 // setupTestRepo creates a temporary git repository for testing
@@ -72,7 +79,7 @@ func TestGetWorktreeInfo(t *testing.T) {
 	defer cleanup()
 
 	// Test GetWorktreeInfo
-	worktrees, err := GetWorktreeInfo(repoPath)
+	worktrees, err := GetWorktreeInfo(context.Background(), &git.ExecRunner{}, repoPath)
 	if err != nil {
 		t.Fatalf("GetWorktreeInfo failed: %v", err)
 	}
@@ -88,6 +95,36 @@ func TestGetWorktreeInfo(t *testing.T) {
 	}
 }
 
+// TestGetWorktreeInfoBranchNames is a regression test for branch names with
+// slashes, which were cut down to their last component. Only the leading
+// "refs/heads/" is removed.
+func TestGetWorktreeInfoBranchNames(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	branches := []string{"feature/deep/login", "user/refs/heads/odd"}
+	for i, branch := range branches {
+		path := filepath.Join(repoPath, ".worktrees", strconv.Itoa(i))
+		if out, err := exec.Command("git", "-C", repoPath, "worktree", "add", "-b", branch, path).CombinedOutput(); err != nil {
+			t.Fatalf("Failed to add worktree: %v\n%s", err, out)
+		}
+	}
+
+	for _, z := range []bool{false, true} {
+		worktrees, err := listWorktrees(context.Background(), &git.ExecRunner{}, repoPath, z)
+		if err != nil {
+			t.Fatalf("listWorktrees failed: %v", err)
+		}
+		var got []string
+		for _, wt := range worktrees[1:] {
+			got = append(got, wt.Branch)
+		}
+		if strings.Join(got, ",") != strings.Join(branches, ",") {
+			t.Errorf("With z=%v, expected branches %v, got %v", z, branches, got)
+		}
+	}
+}
+
 // TestIsGitRepository tests the IsGitRepository function
 func TestIsGitRepository(t *testing.T) {
 	// Set up test repository
@@ -95,7 +132,7 @@ func TestIsGitRepository(t *testing.T) {
 	defer cleanup()
 
 	// Test with a valid git repository
-	if !IsGitRepository(repoPath) {
+	if !IsGitRepository(context.Background(), &git.ExecRunner{}, repoPath) {
 		t.Errorf("Expected %s to be a git repository", repoPath)
 	}
 
@@ -106,7 +143,196 @@ func TestIsGitRepository(t *testing.T) {
 	}
 	defer os.RemoveAll(tempDir)
 
-	if IsGitRepository(tempDir) {
+	if IsGitRepository(context.Background(), &git.ExecRunner{}, tempDir) {
 		t.Errorf("Expected %s to not be a git repository", tempDir)
 	}
 }
+
+// TestFind tests resolving a worktree by directory or branch name
+func TestFind(t *testing.T) {
+	worktrees := []Info{
+		{Path: "/ws/.git", IsBare: true},
+		{Path: "/ws/main", Branch: "main"},
+		{Path: "/ws/feature/login", Branch: "feature/login"},
+		{Path: "/ws/hotfix", Branch: "login"},
+		{Path: "/ws/a/review", Branch: "review-a"},
+		{Path: "/ws/b/review", Branch: "review-b"},
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		wantPath string
+		wantKind errs.Kind
+	}{
+		{"directory name", "main", "/ws/main", 0},
+		{"branch with slash", "feature/login", "/ws/feature/login", 0},
+		{"path match wins over branch", "login", "/ws/feature/login", 0},
+		{"branch only", "review-b", "/ws/b/review", 0},
+		{"ambiguous directory", "review", "", errs.Ambiguous},
+		{"missing", "nope", "", errs.NotFound},
+		{"bare repository is skipped", ".git", "", errs.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wt, err := Find(worktrees, tt.query)
+			if tt.wantKind != 0 {
+				if !errs.Is(err, tt.wantKind) {
+					t.Fatalf("expected %v error, got %v", tt.wantKind, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Find failed: %v", err)
+			}
+			if wt.Path != tt.wantPath {
+				t.Errorf("expected path %s, got %s", tt.wantPath, wt.Path)
+			}
+		})
+	}
+}
+
+// TestIsDirty tests detecting uncommitted changes in a worktree
+func TestIsDirty(t *testing.T) {
+	// Set up test repository
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	dirty, err := IsDirty(context.Background(), &git.ExecRunner{}, repoPath)
+	if err != nil {
+		t.Fatalf("IsDirty failed: %v", err)
+	}
+	if dirty {
+		t.Errorf("Expected a freshly committed repository to be clean")
+	}
+
+	// An untracked file makes the worktree dirty
+	if err := os.WriteFile(filepath.Join(repoPath, "scratch.txt"), []byte("wip\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	dirty, err = IsDirty(context.Background(), &git.ExecRunner{}, repoPath)
+	if err != nil {
+		t.Fatalf("IsDirty failed: %v", err)
+	}
+	if !dirty {
+		t.Errorf("Expected a repository with untracked files to be dirty")
+	}
+}
+
+// TestGetWorktreeInfoFromFake tests parsing porcelain output without running git
+func TestGetWorktreeInfoFromFake(t *testing.T) {
+	fake := testutil.NewFakeRunner(t)
+	fake.On("worktree", "list", "--porcelain").Return(`worktree /ws
+bare
+
+worktree /ws/main
+HEAD 1111111111111111111111111111111111111111
+branch refs/heads/main
+
+worktree /ws/feature/login
+HEAD 2222222222222222222222222222222222222222
+branch refs/heads/feature/login
+
+worktree /ws/review
+HEAD 3333333333333333333333333333333333333333
+detached
+`)
+
+	worktrees, err := GetWorktreeInfo(context.Background(), fake, "/ws/main")
+	if err != nil {
+		t.Fatalf("GetWorktreeInfo failed: %v", err)
+	}
+
+	want := []Info{
+		{Path: "/ws", IsBare: true},
+		{Path: "/ws/main", Branch: "main", Commit: "1111111111111111111111111111111111111111"},
+		{Path: "/ws/feature/login", Branch: "feature/login", Commit: "2222222222222222222222222222222222222222"},
+		{Path: "/ws/review", Commit: "3333333333333333333333333333333333333333"},
+	}
+	if len(worktrees) != len(want) {
+		t.Fatalf("Expected %d worktrees, got %+v", len(want), worktrees)
+	}
+	for i := range want {
+		if worktrees[i] != want[i] {
+			t.Errorf("Worktree %d: expected %+v, got %+v", i, want[i], worktrees[i])
+		}
+	}
+}
+
+// TestFailurePaths tests how git failures surface, using runners that fail
+// deterministically
+func TestFailurePaths(t *testing.T) {
+	ctx := context.Background()
+
+	// git is not installed
+	_, err := GetWorktreeInfo(ctx, testutil.GitNotInstalled(), t.TempDir())
+	if !errs.Is(err, errs.GitFailure) || !strings.Contains(err.Error(), "git is not installed") {
+		t.Errorf("Expected a git failure mentioning the missing git, got %v", err)
+	}
+	if IsGitRepository(ctx, testutil.GitNotInstalled(), t.TempDir()) {
+		t.Errorf("Expected no repository to be detected without git")
+	}
+
+	// Another git process holds the index lock
+	fake := testutil.NewFakeRunner(t)
+	fake.On("status", "--porcelain").IndexLocked("/ws/.git/worktrees/main")
+	_, err = IsDirty(ctx, fake, "/ws/main")
+	if !errs.Is(err, errs.GitFailure) || !strings.Contains(err.Error(), "index.lock': File exists") {
+		t.Errorf("Expected a git failure mentioning the lock, got %v", err)
+	}
+	if !fake.CalledWith("status", "--porcelain") {
+		t.Errorf("Expected git status to be called, got %v", fake.Calls())
+	}
+}
+
+// TestRecordAndReplay tests replaying a recorded session after the repository is gone
+func TestRecordAndReplay(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+
+	recorder := &testutil.Recorder{Runner: &git.ExecRunner{}}
+	recorded, err := GetWorktreeInfo(context.Background(), recorder, repoPath)
+	if err != nil {
+		t.Fatalf("GetWorktreeInfo failed: %v", err)
+	}
+	cleanup()
+
+	replayed, err := GetWorktreeInfo(context.Background(), recorder.Replay(t), repoPath)
+	if err != nil {
+		t.Fatalf("Replayed GetWorktreeInfo failed: %v", err)
+	}
+	if len(replayed) != 1 || replayed[0] != recorded[0] {
+		t.Errorf("Expected the replay to match the recording, got %+v and %+v", replayed, recorded)
+	}
+}
+
+// TestFindGitDirAfterMove tests that FindGitDir finds a workspace that was
+// moved by hand, even though the worktree's .git file still names the old
+// location
+func TestFindGitDirAfterMove(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	moved := filepath.Join(ws.TempDir, "moved")
+	if err := os.Rename(ws.Root, moved); err != nil {
+		t.Fatal(err)
+	}
+
+	gitDir, err := FindGitDir(filepath.Join(moved, "main"))
+	if err != nil {
+		t.Fatalf("FindGitDir failed: %v", err)
+	}
+	if want := filepath.Join(moved, ".git"); gitDir != want {
+		t.Errorf("FindGitDir = %s, want %s", gitDir, want)
+	}
+
+	// A worktree taken out of its workspace cannot be traced back
+	lost := filepath.Join(ws.TempDir, "lost")
+	if err := os.Rename(filepath.Join(moved, "main"), lost); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FindGitDir(lost); !errs.Is(err, errs.NotInRepo) || !strings.Contains(err.Error(), "tool doctor --fix") {
+		t.Errorf("Expected a not-in-repo error suggesting a repair, got %v", err)
+	}
+}
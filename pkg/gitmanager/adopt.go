@@ -0,0 +1,327 @@
+package gitmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+	"github.com/ingshtrom/git-manager/internal/plan"
+	"github.com/ingshtrom/git-manager/internal/worktree"
+)
+
+// adoptStaging is where an adopted checkout is parked while its worktree is
+// created, so tracked files cannot collide with the worktree's name
+const adoptStaging = ".git-manager-adopt"
+
+// AdoptOptions configures AdoptRepository
+type AdoptOptions struct {
+	// Dir is anywhere in the clone to adopt
+	Dir string
+
+	// Name is the registry name. Empty uses the clone's directory name.
+	Name string
+
+	// AllBranches also adds a worktree for every other local branch
+	AllBranches bool
+
+	// DryRun resolves and validates everything but changes nothing
+	DryRun bool
+}
+
+// AdoptResult describes a clone converted by AdoptRepository
+type AdoptResult struct {
+	Workspace Workspace
+
+	// Worktree is where the clone's checkout, with its uncommitted changes,
+	// now lives
+	Worktree string
+
+	// Added lists the worktrees added for other branches
+	Added []string
+
+	// Skipped lists the other branches left without a worktree, as their
+	// worktree path would be inside the checkout's worktree or contain it
+	Skipped []string
+
+	// Plan lists the planned steps, in the form printed for a dry run
+	Plan []string
+}
+
+// AdoptRepository converts a normal clone into a git-manager workspace in
+// place: its .git directory becomes a bare repository and the checkout,
+// including uncommitted and staged changes, moves into the worktree for its
+// branch. Stashes and existing linked worktrees are kept. The workspace is
+// registered under opts.Name. A failure restores the clone.
+func (m *Manager) AdoptRepository(ctx context.Context, opts AdoptOptions) (*AdoptResult, error) {
+	root, err := git.Output(ctx, m.git, opts.Dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		if m.IsRepository(ctx, opts.Dir) {
+			return nil, newError(Conflict, "%s is already a git-manager workspace or bare repository", opts.Dir)
+		}
+		return nil, newError(NotInRepo, "%s is not a git clone", opts.Dir)
+	}
+	root = strings.TrimSpace(root)
+	gitDir := filepath.Join(root, ".git")
+
+	if info, err := os.Stat(gitDir); err != nil || !info.IsDir() {
+		return nil, newError(Usage, "%s is a linked worktree, adopt the clone it belongs to", root)
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "modules")); err == nil {
+		return nil, newError(Unsupported, "%s has submodules, which cannot be adopted", root)
+	}
+	for _, marker := range []string{"MERGE_HEAD", "CHERRY_PICK_HEAD", "REVERT_HEAD", "BISECT_LOG", "rebase-merge", "rebase-apply"} {
+		if _, err := os.Stat(filepath.Join(gitDir, marker)); err == nil {
+			return nil, newError(Conflict, "%s has a merge, rebase, cherry-pick, revert or bisect in progress, finish it first", root)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, adoptStaging)); err == nil {
+		return nil, newError(Conflict, "%s exists, remove it first", filepath.Join(root, adoptStaging))
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = filepath.Base(root)
+	}
+	cfg, err := m.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := cfg.Repository(name); err == nil && existing.Path != root {
+		return nil, newError(Conflict, "a repository named %q is already registered at %s, use another name", name, existing.Path)
+	}
+
+	// The worktree is named after the branch, like the ones add creates
+	branch := ""
+	if out, err := git.Output(ctx, m.git, root, "symbolic-ref", "--quiet", "--short", "HEAD"); err == nil {
+		branch = strings.TrimSpace(out)
+	} else if ctx.Err() != nil {
+		return nil, wrapGit(err, "error reading the current branch")
+	}
+	worktreePath := filepath.Join(root, "detached")
+	if branch != "" {
+		worktreePath = filepath.Join(root, branch)
+	}
+
+	ws := &Workspace{Name: name, Root: root, GitDir: gitDir}
+
+	var others, skipped []string
+	if opts.AllBranches {
+		others, skipped, err = m.otherBranches(ctx, ws, branch, worktreePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	staging := filepath.Join(root, adoptStaging)
+	var p plan.Plan
+
+	// Park the checkout so nothing in it collides with the worktree path
+	p.Add(plan.Step{
+		Description: fmt.Sprintf("move the checkout in %s to %s", root, staging),
+		Progress:    fmt.Sprintf("Adopting %s...", root),
+		Run: func() error {
+			if err := os.Mkdir(staging, 0755); err != nil {
+				return fmt.Errorf("error creating %s: %w", staging, err)
+			}
+			return moveEntries(root, staging, ".git", adoptStaging)
+		},
+		Undo: func() error {
+			if err := moveEntries(staging, root); err != nil {
+				return err
+			}
+			return os.Remove(staging)
+		},
+	})
+
+	bare := m.gitStep(ctx, root, "error converting to a bare repository", "-C", gitDir, "config", "core.bare", "true")
+	bare.Undo = m.undoStep(ctx, root, "-C", gitDir, "config", "core.bare", "false")
+	p.Add(bare)
+
+	// Create the worktree without touching the files, then give it the
+	// clone's index so staged changes survive
+	args := []string{"-C", gitDir, "worktree", "add", "--no-checkout", worktreePath, branch}
+	if branch == "" {
+		args = []string{"-C", gitDir, "worktree", "add", "--no-checkout", "--detach", worktreePath, "HEAD"}
+	}
+	addWorktree := m.gitStep(ctx, root, "error creating worktree", args...)
+	prune := m.undoStep(ctx, root, "-C", gitDir, "worktree", "prune")
+	addWorktree.Undo = func() error {
+		if err := os.RemoveAll(worktreePath); err != nil {
+			return err
+		}
+		return prune()
+	}
+	p.Add(addWorktree)
+
+	var adminIndex string
+	p.Add(plan.Step{
+		Description: fmt.Sprintf("move the index and the checkout into %s", worktreePath),
+		Run: func() error {
+			adminDir, err := worktreeAdminDir(worktreePath)
+			if err != nil {
+				return err
+			}
+			adminIndex = filepath.Join(adminDir, "index")
+			if err := os.Rename(filepath.Join(gitDir, "index"), adminIndex); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("error moving the index: %w", err)
+			}
+			if err := moveEntries(staging, worktreePath); err != nil {
+				return err
+			}
+			return os.Remove(staging)
+		},
+		Undo: func() error {
+			if err := os.MkdirAll(staging, 0755); err != nil {
+				return err
+			}
+			if err := moveEntries(worktreePath, staging, ".git"); err != nil {
+				return err
+			}
+			if adminIndex == "" {
+				return nil
+			}
+			if err := os.Rename(adminIndex, filepath.Join(gitDir, "index")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			return nil
+		},
+	})
+
+	var added []string
+	for _, other := range others {
+		path := filepath.Join(root, other)
+		step := m.gitStep(ctx, root, "error creating worktree", "-C", gitDir, "worktree", "add", path, other)
+		step.Progress = fmt.Sprintf("Adding worktree for branch '%s'...", other)
+		step.Undo = func() error {
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+			return prune()
+		}
+		p.Add(step)
+		added = append(added, path)
+	}
+
+	p.Add(m.registerStep(name, root))
+
+	steps, err := m.run(&p, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	return &AdoptResult{Workspace: *ws, Worktree: worktreePath, Added: added, Skipped: skipped, Plan: steps}, nil
+}
+
+// otherBranches returns the local branches other than current that are not
+// checked out in a worktree yet. Their worktrees go next to the checkout's
+// worktree at checkout, which holds everything else in the clone once it is
+// adopted; the branches whose worktree path would overlap it are returned as
+// skipped.
+func (m *Manager) otherBranches(ctx context.Context, ws *Workspace, current string, checkout string) ([]string, []string, error) {
+	out, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "for-each-ref", "--format=%(refname:short)", "refs/heads")
+	if err != nil {
+		return nil, nil, wrapGit(err, "error listing branches")
+	}
+	worktrees, err := m.ListWorktrees(ctx, ws)
+	if err != nil {
+		return nil, nil, err
+	}
+	checkedOut := map[string]bool{current: true}
+	for _, wt := range worktrees {
+		checkedOut[wt.Branch] = true
+	}
+
+	var branches, skipped []string
+	for _, branch := range strings.Fields(out) {
+		if checkedOut[branch] {
+			continue
+		}
+		if path := filepath.Join(ws.Root, branch); isWithin(path, checkout) || isWithin(checkout, path) {
+			skipped = append(skipped, branch)
+			continue
+		}
+		branches = append(branches, branch)
+	}
+	return branches, skipped, nil
+}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// worktreeAdminDir returns the directory in the repository that the .git
+// file of the worktree at path points to
+func worktreeAdminDir(path string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(path, ".git"))
+	if err != nil {
+		return "", fmt.Errorf("error reading .git file: %w", err)
+	}
+	dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return "", fmt.Errorf("%s is not a valid .git file", filepath.Join(path, ".git"))
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(path, dir)
+	}
+	return filepath.Clean(dir), nil
+}
+
+// moveEntries moves everything in from into to, except the names in skip
+func moveEntries(from string, to string, skip ...string) error {
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", from, err)
+	}
+	for _, entry := range entries {
+		if slices.Contains(skip, entry.Name()) {
+			continue
+		}
+		if err := os.Rename(filepath.Join(from, entry.Name()), filepath.Join(to, entry.Name())); err != nil {
+			return fmt.Errorf("error moving %s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// FindClones returns the normal clones under dir, for adopting them all.
+// Workspaces, bare repositories and hidden directories are skipped, and the
+// search does not descend into a clone once found.
+func FindClones(dir string) ([]string, error) {
+	var clones []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			// Unreadable directories are not worth failing the scan for
+			return fs.SkipDir
+		}
+		if !d.IsDir() || (path != dir && strings.HasPrefix(d.Name(), ".")) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		info, err := os.Stat(filepath.Join(path, ".git"))
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && !worktree.IsBareGitDir(filepath.Join(path, ".git")) {
+			clones = append(clones, path)
+		}
+		// Nothing below a clone, workspace or worktree is a separate clone
+		return fs.SkipDir
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning %s: %w", dir, err)
+	}
+	return clones, nil
+}
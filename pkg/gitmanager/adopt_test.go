@@ -0,0 +1,161 @@
+package gitmanager_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// setupClone returns a normal clone of the workspace's origin with a second
+// branch, a stash, staged, unstaged and untracked changes, and a directory
+// named like its branch
+func setupClone(t *testing.T, ws *testutil.Workspace) *testutil.GitRepo {
+	t.Helper()
+
+	clone := &testutil.GitRepo{Path: filepath.Join(ws.TempDir, "clone")}
+	if out, err := testutil.NewCommand("git", "clone", ws.Origin.Path, clone.Path).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to clone: %v\nOutput: %s", err, out)
+	}
+	clone.RunGit(t, "config", "user.name", "Test User")
+	clone.RunGit(t, "config", "user.email", "test@example.com")
+	clone.RunGit(t, "branch", "topic")
+
+	clone.CreateFile(t, "README.md", "stashed\n")
+	clone.RunGit(t, "stash")
+
+	clone.CreateFile(t, "main/notes.txt", "a directory named like the branch\n")
+	clone.AddAndCommit(t, "Add notes", "main/notes.txt")
+	clone.CreateFile(t, "README.md", "unstaged\n")
+	clone.CreateFile(t, "staged.txt", "staged\n")
+	clone.RunGit(t, "add", "staged.txt")
+	clone.CreateFile(t, "untracked.txt", "untracked\n")
+	return clone
+}
+
+// TestAdoptRepository tests converting a clone into a workspace without
+// losing uncommitted work or stashes
+func TestAdoptRepository(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	clone := setupClone(t, testWS)
+	status := clone.RunGit(t, "status", "--porcelain")
+
+	res, err := m.AdoptRepository(ctx, gitmanager.AdoptOptions{Dir: clone.Path, AllBranches: true})
+	if err != nil {
+		t.Fatalf("AdoptRepository failed: %v", err)
+	}
+
+	main := &testutil.GitRepo{Path: filepath.Join(clone.Path, "main")}
+	if res.Worktree != main.Path {
+		t.Errorf("Expected the checkout in %s, got %s", main.Path, res.Worktree)
+	}
+	if got := main.RunGit(t, "status", "--porcelain"); got != status {
+		t.Errorf("Status changed by adoption, was:\n%s\nnow:\n%s", status, got)
+	}
+	main.AssertFileContent(t, "main/notes.txt", "a directory named like the branch\n")
+	if stash := main.RunGit(t, "stash", "list"); stash == "" {
+		t.Errorf("Expected the stash to be kept")
+	}
+	if len(res.Added) != 1 || res.Added[0] != filepath.Join(clone.Path, "topic") {
+		t.Errorf("Expected a worktree for topic, got %v", res.Added)
+	}
+	if bare := (&testutil.GitRepo{Path: filepath.Join(clone.Path, ".git")}).RunGit(t, "rev-parse", "--is-bare-repository"); bare != "true\n" {
+		t.Errorf("Expected .git to be bare, got %q", bare)
+	}
+	if repo, err := m.Repository("clone"); err != nil || repo.Path != clone.Path {
+		t.Errorf("Expected the workspace to be registered as clone, got %+v, %v", repo, err)
+	}
+
+	// Adopting twice is refused
+	if _, err := m.AdoptRepository(ctx, gitmanager.AdoptOptions{Dir: clone.Path}); gitmanager.KindOf(err) != gitmanager.Conflict {
+		t.Errorf("Expected a conflict adopting a workspace, got %v", err)
+	}
+}
+
+// TestAdoptRepositorySkipsBranches tests that --all-branches adds worktrees
+// by the layout after the adoption: a directory in the checkout does not
+// block a branch of the same name, a branch overlapping the checkout's
+// worktree is skipped
+func TestAdoptRepositorySkipsBranches(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+
+	clone := setupClone(t, testWS)
+	clone.CreateFile(t, "topic/notes.txt", "a directory named like a branch\n")
+	clone.RunGit(t, "checkout", "--quiet", "--detach")
+	clone.RunGit(t, "branch", "detached/old")
+
+	res, err := m.AdoptRepository(context.Background(), gitmanager.AdoptOptions{Dir: clone.Path, AllBranches: true})
+	if err != nil {
+		t.Fatalf("AdoptRepository failed: %v", err)
+	}
+	if res.Worktree != filepath.Join(clone.Path, "detached") {
+		t.Errorf("Expected the checkout in the detached worktree, got %s", res.Worktree)
+	}
+	if want := []string{filepath.Join(clone.Path, "main"), filepath.Join(clone.Path, "topic")}; !slices.Equal(res.Added, want) {
+		t.Errorf("Expected worktrees %v, got %v", want, res.Added)
+	}
+	if want := []string{"detached/old"}; !slices.Equal(res.Skipped, want) {
+		t.Errorf("Expected %v to be skipped, got %v", want, res.Skipped)
+	}
+	(&testutil.GitRepo{Path: res.Worktree}).AssertFileContent(t, "topic/notes.txt", "a directory named like a branch\n")
+}
+
+// TestAdoptRepositoryRollsBack tests that a failed adoption leaves the
+// clone as it was
+func TestAdoptRepositoryRollsBack(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	clone := setupClone(t, testWS)
+	status := clone.RunGit(t, "status", "--porcelain")
+
+	fake := testutil.GitVersion(t, "2.39.5")
+	fake.On(filepath.Join(clone.Path, "topic"), "topic").Fail(128, "fatal: could not create worktree")
+	m := gitmanager.New(gitmanager.Options{
+		Git:        fake,
+		ConfigPath: filepath.Join(t.TempDir(), "config.json"),
+	})
+
+	if _, err := m.AdoptRepository(ctx, gitmanager.AdoptOptions{Dir: clone.Path, AllBranches: true}); gitmanager.KindOf(err) != gitmanager.GitFailure {
+		t.Fatalf("Expected a git failure, got %v", err)
+	}
+
+	if got := clone.RunGit(t, "status", "--porcelain"); got != status {
+		t.Errorf("Status changed by the failed adoption, was:\n%s\nnow:\n%s", status, got)
+	}
+	if _, err := os.Stat(filepath.Join(clone.Path, "main", "notes.txt")); err != nil {
+		t.Errorf("Expected the checkout back in place: %v", err)
+	}
+	if _, err := m.Repository("clone"); gitmanager.KindOf(err) != gitmanager.NotFound {
+		t.Errorf("Expected nothing to be registered, got %v", err)
+	}
+}
+
+// TestFindClones tests that scanning finds clones but not workspaces
+func TestFindClones(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	clone := setupClone(t, testWS)
+
+	clones, err := gitmanager.FindClones(testWS.TempDir)
+	if err != nil {
+		t.Fatalf("FindClones failed: %v", err)
+	}
+	// origin is a normal repository too
+	want := []string{clone.Path, testWS.Origin.Path}
+	if len(clones) != len(want) || clones[0] != want[0] || clones[1] != want[1] {
+		t.Errorf("FindClones = %v, want %v", clones, want)
+	}
+}
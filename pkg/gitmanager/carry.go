@@ -0,0 +1,304 @@
+package gitmanager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+	"github.com/ingshtrom/git-manager/internal/plan"
+)
+
+// CarryOptions configures Carry
+type CarryOptions struct {
+	// Target selects the worktree the changes are carried to, see
+	// FindWorktree
+	Target string
+
+	// Source is a directory in the worktree whose uncommitted changes are
+	// carried. Paths are relative to it.
+	Source string
+
+	// Paths limits the carried changes to these pathspecs
+	Paths []string
+
+	// Untracked also carries untracked files that are not ignored
+	Untracked bool
+
+	// FromStash carries the stash entry stash@{Stash} instead of the changes
+	// in Source. The entry is dropped once it is applied.
+	FromStash bool
+	Stash     int
+
+	// DryRun resolves and validates everything but changes nothing
+	DryRun bool
+}
+
+// CarryResult describes changes carried by Carry
+type CarryResult struct {
+	// Target is the directory of the worktree the changes were carried to
+	Target string
+
+	// Files are the paths that changed in the target, relative to it
+	Files []string
+
+	// Plan lists the planned steps, in the form printed for a dry run
+	Plan []string
+}
+
+// Carry moves uncommitted changes, staged and unstaged, from one worktree
+// of ws to another. They are stashed in the source and applied in the target
+// with a 3-way merge, so they also apply to a target on a different commit.
+// Staged changes arrive unstaged. Only when they apply cleanly is the stash
+// entry dropped; otherwise everything is rolled back and a Conflict error
+// names the conflicting files.
+func (m *Manager) Carry(ctx context.Context, ws *Workspace, opts CarryOptions) (*CarryResult, error) {
+	wt, err := m.FindWorktree(ctx, ws, opts.Target)
+	if err != nil {
+		return nil, err
+	}
+	if !dirExists(wt.Path) {
+		return nil, newError(NotFound, "worktree '%s' is missing, its directory %s is gone", opts.Target, wt.Path)
+	}
+
+	if !opts.FromStash {
+		out, err := git.Output(ctx, m.git, opts.Source, "rev-parse", "--show-toplevel")
+		if err != nil {
+			return nil, wrapGit(err, "error locating the current worktree")
+		}
+		if filepath.Clean(strings.TrimSpace(out)) == filepath.Clean(wt.Path) {
+			return nil, newError(Usage, "the changes are already in worktree '%s'", opts.Target)
+		}
+	}
+
+	res := &CarryResult{Target: wt.Path}
+	steps, err := m.carrySteps(ctx, ws, wt.Path, opts, &res.Files)
+	if err != nil {
+		return nil, err
+	}
+	if res.Plan, err = m.run(&plan.Plan{Steps: steps}, opts.DryRun); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// carrySteps returns the steps that carry the changes described by opts to
+// the worktree at target, which need not exist yet. The paths that change in
+// the target are stored in files.
+func (m *Manager) carrySteps(ctx context.Context, ws *Workspace, target string, opts CarryOptions, files *[]string) ([]plan.Step, error) {
+	var steps []plan.Step
+	var stash string
+
+	if opts.FromStash {
+		ref := fmt.Sprintf("stash@{%d}", opts.Stash)
+		out, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "rev-parse", "--quiet", "--verify", ref)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, wrapGit(err, "error reading the stash")
+			}
+			return nil, newError(NotFound, "%s not found", ref)
+		}
+		stash = strings.TrimSpace(out)
+	} else {
+		// Stash the changes, so the source no longer has them if all goes
+		// well
+		args := []string{"stash", "push", "--quiet", "--message", "git-manager carry to " + filepath.Base(target)}
+		if opts.Untracked {
+			args = append(args, "--include-untracked")
+		}
+		args = append(append(args, "--"), opts.Paths...)
+		push := plan.Step{
+			Description: plan.Command(append([]string{"git", "-C", opts.Source}, args...)...),
+			Progress:    "Stashing the changes...",
+			Run: func() error {
+				before := m.stashTop(ctx, opts.Source)
+				if _, err := m.git.Run(ctx, git.Invocation{Dir: opts.Source, Args: args}); err != nil {
+					return wrapGit(err, "error stashing the changes")
+				}
+				if stash = m.stashTop(ctx, opts.Source); stash == before {
+					stash = ""
+					if opts.Untracked {
+						return newError(NotFound, "there are no changes to carry")
+					}
+					return newError(NotFound, "there are no changes to carry, use --untracked to carry untracked files")
+				}
+				return nil
+			},
+			Undo: func() error {
+				if stash == "" {
+					return nil
+				}
+				ref, err := m.stashRef(context.WithoutCancel(ctx), opts.Source, stash)
+				if err != nil {
+					return err
+				}
+				return m.undoStep(ctx, opts.Source, "stash", "pop", "--index", "--quiet", ref)()
+			},
+		}
+		steps = append(steps, push)
+	}
+
+	// Apply the stash in the target with a 3-way merge
+	var applied []string
+	apply := plan.Step{
+		Description: plan.Command("git", "-C", target, "stash", "apply", "<stash>"),
+		Progress:    fmt.Sprintf("Applying the changes in %s...", target),
+		Run: func() error {
+			before, err := m.changedPaths(ctx, target)
+			if err != nil {
+				return err
+			}
+			_, applyErr := m.git.Run(ctx, git.Invocation{Dir: target, Args: []string{"stash", "apply", "--quiet", stash}})
+
+			after, err := m.changedPaths(context.WithoutCancel(ctx), target)
+			if err != nil {
+				return err
+			}
+			// Files changed before were not touched, as git refuses to
+			// overwrite local changes
+			var conflicts []string
+			for path, state := range after {
+				if _, ok := before[path]; ok {
+					continue
+				}
+				applied = append(applied, path)
+				if unmergedStates[state] {
+					conflicts = append(conflicts, path)
+				}
+			}
+			sort.Strings(applied)
+			sort.Strings(conflicts)
+
+			switch {
+			case len(conflicts) > 0:
+				return newError(Conflict, "the changes conflict in %s, nothing was carried", strings.Join(conflicts, ", "))
+			case applyErr != nil:
+				return wrapGit(applyErr, "error applying the changes")
+			}
+			*files = applied
+			return nil
+		},
+		Undo: func() error {
+			return m.restorePaths(context.WithoutCancel(ctx), target, applied)
+		},
+	}
+	steps = append(steps, apply)
+
+	// Only now that the changes are in the target can the stash go
+	drop := plan.Step{
+		Description: plan.Command("git", "-C", target, "stash", "drop", "<stash>"),
+		Run: func() error {
+			// git stash needs a worktree, and the stash is shared by all
+			ref, err := m.stashRef(ctx, target, stash)
+			if err != nil {
+				return err
+			}
+			if _, err := m.git.Run(ctx, git.Invocation{Dir: target, Args: []string{"stash", "drop", "--quiet", ref}}); err != nil {
+				return wrapGit(err, "error dropping the stash")
+			}
+			return nil
+		},
+	}
+	steps = append(steps, drop)
+
+	return steps, nil
+}
+
+// unmergedStates are the `git status --porcelain` states of conflicts
+var unmergedStates = map[string]bool{
+	"DD": true, "AU": true, "UD": true, "UA": true, "DU": true, "AA": true, "UU": true,
+}
+
+// stashTop returns the hash of the latest stash entry, or "" if there is none
+func (m *Manager) stashTop(ctx context.Context, dir string) string {
+	out, err := git.Output(ctx, m.git, dir, "rev-parse", "--quiet", "--verify", "refs/stash")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// stashRef returns the stash@{n} name of the stash entry with the given
+// hash. Entries move up as others are dropped, so they are looked up by hash.
+func (m *Manager) stashRef(ctx context.Context, dir string, hash string) (string, error) {
+	out, err := git.Output(ctx, m.git, dir, "stash", "list", "--format=%H")
+	if err != nil {
+		return "", wrapGit(err, "error listing the stash")
+	}
+	for i, h := range strings.Fields(out) {
+		if h == hash {
+			return fmt.Sprintf("stash@{%d}", i), nil
+		}
+	}
+	return "", newError(NotFound, "stash entry %s not found", hash)
+}
+
+// changedPaths returns the `git status --porcelain` state of every path of
+// the worktree at dir that differs from HEAD, including untracked files
+func (m *Manager) changedPaths(ctx context.Context, dir string) (map[string]string, error) {
+	out, err := git.Output(ctx, m.git, dir, "status", "--porcelain", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, wrapGit(err, "error reading worktree status")
+	}
+	paths := map[string]string{}
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		paths[entry[3:]] = entry[:2]
+		// Renames and copies are followed by the original path
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+	}
+	return paths, nil
+}
+
+// restorePaths sets paths of the worktree at dir back to HEAD, in both the
+// index and the checkout, removing those HEAD does not have
+func (m *Manager) restorePaths(ctx context.Context, dir string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	env := []string{"GIT_LITERAL_PATHSPECS=1"}
+
+	out, err := m.git.Run(ctx, git.Invocation{Dir: dir, Args: append([]string{"ls-tree", "-r", "-z", "--name-only", "HEAD", "--"}, paths...), Env: env})
+	if err != nil {
+		return wrapGit(err, "error listing files")
+	}
+	inHead := map[string]bool{}
+	for _, path := range strings.Split(out.Stdout, "\x00") {
+		inHead[path] = true
+	}
+
+	var tracked, added []string
+	for _, path := range paths {
+		if inHead[path] {
+			tracked = append(tracked, path)
+		} else {
+			added = append(added, path)
+		}
+	}
+
+	if len(tracked) > 0 {
+		if _, err := m.git.Run(ctx, git.Invocation{Dir: dir, Args: append([]string{"checkout", "HEAD", "--"}, tracked...), Env: env}); err != nil {
+			return wrapGit(err, "error restoring files")
+		}
+	}
+	if len(added) > 0 {
+		if _, err := m.git.Run(ctx, git.Invocation{Dir: dir, Args: append([]string{"rm", "--cached", "--quiet", "--ignore-unmatch", "--"}, added...), Env: env}); err != nil {
+			return wrapGit(err, "error removing files")
+		}
+		for _, path := range added {
+			if err := os.Remove(filepath.Join(dir, path)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
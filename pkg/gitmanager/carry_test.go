@@ -0,0 +1,79 @@
+package gitmanager_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// TestCarry tests moving staged, unstaged and untracked changes to another
+// worktree, and rolling back when they conflict there
+func TestCarry(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	main := testWS.Worktree("main")
+	feature := testWS.AddWorktree(t, "feature")
+	feature.CreateFile(t, "feature.txt", "feature\n")
+	feature.AddAndCommit(t, "Add feature", "feature.txt")
+
+	main.CreateFile(t, "README.md", "# Test Repository\n\nMore\n")
+	main.CreateFile(t, "staged.txt", "staged\n")
+	main.RunGit(t, "add", "staged.txt")
+	main.CreateFile(t, "untracked.txt", "untracked\n")
+
+	ws := &gitmanager.Workspace{Root: testWS.Root, GitDir: testWS.GitDir}
+
+	res, err := m.Carry(ctx, ws, gitmanager.CarryOptions{Target: "feature", Source: main.Path, Untracked: true})
+	if err != nil {
+		t.Fatalf("Carry failed: %v", err)
+	}
+	if got := fmt.Sprint(res.Files); got != "[README.md staged.txt untracked.txt]" {
+		t.Errorf("Carry changed %s", got)
+	}
+	feature.AssertFileContent(t, "README.md", "# Test Repository\n\nMore\n")
+	feature.AssertFileContent(t, "untracked.txt", "untracked\n")
+	if status := main.RunGit(t, "status", "--porcelain"); status != "" {
+		t.Errorf("Expected the changes gone from main, got:\n%s", status)
+	}
+	if stash := main.RunGit(t, "stash", "list"); stash != "" {
+		t.Errorf("Expected the stash dropped, got:\n%s", stash)
+	}
+
+	// Conflicting changes stay where they are
+	feature.RunGit(t, "add", "--all")
+	feature.RunGit(t, "commit", "--quiet", "-m", "Carried")
+	main.CreateFile(t, "README.md", "# Main\n")
+	_, err = m.Carry(ctx, ws, gitmanager.CarryOptions{Target: "feature", Source: main.Path})
+	if !gitmanager.IsKind(err, gitmanager.Conflict) {
+		t.Fatalf("Expected a conflict, got %v", err)
+	}
+	main.AssertFileContent(t, "README.md", "# Main\n")
+	if status := feature.RunGit(t, "status", "--porcelain"); status != "" {
+		t.Errorf("Expected feature rolled back, got:\n%s", status)
+	}
+	if stash := main.RunGit(t, "stash", "list"); stash != "" {
+		t.Errorf("Expected the stash popped, got:\n%s", stash)
+	}
+
+	// A stash entry is carried and dropped
+	main.RunGit(t, "checkout", "--quiet", "README.md")
+	main.CreateFile(t, "feature.txt", "from the stash\n")
+	main.RunGit(t, "stash", "push", "--quiet", "--include-untracked")
+	if _, err := m.Carry(ctx, ws, gitmanager.CarryOptions{Target: "main", FromStash: true, Stash: 0}); err != nil {
+		t.Fatalf("Carry from the stash failed: %v", err)
+	}
+	main.AssertFileContent(t, "feature.txt", "from the stash\n")
+	if stash := main.RunGit(t, "stash", "list"); stash != "" {
+		t.Errorf("Expected the stash dropped, got:\n%s", stash)
+	}
+
+	if _, err := m.Carry(ctx, ws, gitmanager.CarryOptions{Target: "feature", Source: main.Path}); !gitmanager.IsKind(err, gitmanager.NotFound) {
+		t.Errorf("Expected nothing to carry, got %v", err)
+	}
+}
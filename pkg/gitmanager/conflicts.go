@@ -0,0 +1,151 @@
+package gitmanager
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+)
+
+// ConflictOptions configures PredictConflicts
+type ConflictOptions struct {
+	// Base is the branch every worktree branch is merged with. Empty uses
+	// the default branch.
+	Base string
+
+	// Pairwise also merges the worktree branches with each other
+	Pairwise bool
+}
+
+// MergePrediction is the predicted outcome of merging two branches
+type MergePrediction struct {
+	// Branch is merged with Against
+	Branch  string
+	Against string
+
+	// Conflicts is set when the merge would conflict, in Files
+	Conflicts bool
+	Files     []string
+
+	// Messages are git's descriptions of the conflicts, such as
+	// "CONFLICT (content): Merge conflict in main.go"
+	Messages []string
+
+	// Err is set when the merge could not be predicted, e.g. because the
+	// branches share no history
+	Err error
+}
+
+// PredictConflicts predicts, for the branch of every worktree of ws, whether
+// merging it with the base branch would conflict, and on which files. With
+// Pairwise the worktree branches are also merged with each other. The merges
+// happen in memory with `git merge-tree --write-tree`, so no worktree is
+// touched. It needs git 2.38 or newer.
+func (m *Manager) PredictConflicts(ctx context.Context, ws *Workspace, opts ConflictOptions) ([]MergePrediction, error) {
+	if err := m.requireGit(ctx, CapMergeTreeWriteTree, "predicting conflicts"); err != nil {
+		return nil, err
+	}
+
+	base := opts.Base
+	if base == "" {
+		var err error
+		if base, err = m.defaultBranch(ctx, ws); err != nil {
+			return nil, err
+		}
+		if base == "" {
+			return nil, newError(NotFound, "HEAD of %s is detached, so there is no default branch, use --base", ws.GitDir)
+		}
+	}
+	if exists, err := m.branchExists(ctx, ws, base); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, newError(NotFound, "branch '%s' not found", base)
+	}
+
+	worktrees, err := m.ListWorktrees(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+	var branches []string
+	for _, wt := range worktrees {
+		if wt.IsBare || wt.Branch == "" || wt.Branch == base {
+			continue
+		}
+		branches = append(branches, wt.Branch)
+	}
+
+	var predictions []MergePrediction
+	for i, branch := range branches {
+		against := []string{base}
+		if opts.Pairwise {
+			against = append(against, branches[i+1:]...)
+		}
+		for _, other := range against {
+			p, err := m.predictMerge(ctx, ws, branch, other)
+			if err != nil {
+				return predictions, err
+			}
+			predictions = append(predictions, p)
+		}
+	}
+	return predictions, nil
+}
+
+// predictMerge merges branch and against in memory. Failures to predict the
+// merge are reported in the prediction, except for interruptions.
+func (m *Manager) predictMerge(ctx context.Context, ws *Workspace, branch string, against string) (MergePrediction, error) {
+	p := MergePrediction{Branch: branch, Against: against}
+
+	res, err := m.git.Run(ctx, git.Invocation{
+		Dir:  ws.Root,
+		Args: []string{"-C", ws.GitDir, "merge-tree", "--write-tree", "--name-only", "-z", "refs/heads/" + against, "refs/heads/" + branch},
+	})
+	switch {
+	case err == nil:
+		return p, nil
+	case ctx.Err() != nil:
+		return p, wrapGit(err, "error predicting conflicts")
+	case res.ExitCode != 1:
+		p.Err = wrapGit(err, fmt.Sprintf("error merging '%s' with '%s'", branch, against))
+		return p, nil
+	}
+
+	p.Conflicts = true
+	p.Files, p.Messages = parseMergeTree(res.Stdout)
+	return p, nil
+}
+
+// parseMergeTree parses the output of `git merge-tree --write-tree
+// --name-only -z` for a conflicted merge: the tree, the conflicted files, an
+// empty field and then the informational messages, each as the number of
+// paths, the paths, a type and the message. It returns the conflicted files
+// and the messages about conflicts.
+func parseMergeTree(out string) ([]string, []string) {
+	fields := strings.Split(out, "\x00")
+
+	var files []string
+	seen := map[string]bool{}
+	i := 1
+	for ; i < len(fields) && fields[i] != ""; i++ {
+		if !seen[fields[i]] {
+			seen[fields[i]] = true
+			files = append(files, fields[i])
+		}
+	}
+
+	var messages []string
+	for i++; i < len(fields); {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil || i+n+2 >= len(fields) {
+			break
+		}
+		kind, msg := fields[i+n+1], strings.TrimSpace(fields[i+n+2])
+		if strings.HasPrefix(kind, "CONFLICT") {
+			messages = append(messages, msg)
+		}
+		i += n + 3
+	}
+	return files, messages
+}
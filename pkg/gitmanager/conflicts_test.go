@@ -0,0 +1,85 @@
+package gitmanager_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// TestPredictConflicts tests predicting conflicts with the default branch
+// and between worktree branches, without touching any worktree
+func TestPredictConflicts(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	feature := testWS.AddWorktree(t, "feature")
+	feature.CreateFile(t, "feature.txt", "feature\n")
+	feature.AddAndCommit(t, "Add feature", "feature.txt")
+
+	rival := testWS.AddWorktree(t, "rival")
+	rival.CreateFile(t, "feature.txt", "rival\n")
+	rival.AddAndCommit(t, "Add rival feature", "feature.txt")
+
+	clash := testWS.AddWorktree(t, "clash")
+	clash.CreateFile(t, "README.md", "# Clash\n")
+	clash.AddAndCommit(t, "Change README", "README.md")
+
+	main := testWS.Worktree("main")
+	main.CreateFile(t, "README.md", "# Main\n")
+	main.AddAndCommit(t, "Change README on main", "README.md")
+
+	ws := &gitmanager.Workspace{Root: testWS.Root, GitDir: testWS.GitDir}
+	predictions, err := m.PredictConflicts(ctx, ws, gitmanager.ConflictOptions{Pairwise: true})
+	if err != nil {
+		t.Fatalf("PredictConflicts failed: %v", err)
+	}
+
+	got := map[string]string{}
+	for _, p := range predictions {
+		if p.Err != nil {
+			t.Fatalf("Predicting %s with %s failed: %v", p.Branch, p.Against, p.Err)
+		}
+		got[p.Branch+"+"+p.Against] = fmt.Sprint(p.Files)
+		if p.Conflicts && (len(p.Messages) == 0 || !strings.HasPrefix(p.Messages[0], "CONFLICT")) {
+			t.Errorf("Expected conflict messages for %s with %s, got %q", p.Branch, p.Against, p.Messages)
+		}
+	}
+	want := map[string]string{
+		"feature+main":  "[]",
+		"feature+rival": "[feature.txt]",
+		"clash+feature": "[]",
+		"rival+main":    "[]",
+		"clash+rival":   "[]",
+		"clash+main":    "[README.md]",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("PredictConflicts = %v, want %v", got, want)
+	}
+
+	if status := clash.RunGit(t, "status", "--porcelain"); status != "" {
+		t.Errorf("Expected worktrees to be untouched, got %q", status)
+	}
+}
+
+// TestPredictConflictsUnsupported tests that an old git is refused
+func TestPredictConflictsUnsupported(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+
+	m := gitmanager.New(gitmanager.Options{
+		Git:        testutil.GitVersion(t, "2.37.1"),
+		ConfigPath: filepath.Join(t.TempDir(), "config.json"),
+	})
+	ws := &gitmanager.Workspace{Root: testWS.Root, GitDir: testWS.GitDir}
+
+	if _, err := m.PredictConflicts(context.Background(), ws, gitmanager.ConflictOptions{}); gitmanager.KindOf(err) != gitmanager.Unsupported {
+		t.Errorf("Expected an unsupported error, got %v", err)
+	}
+}
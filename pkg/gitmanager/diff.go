@@ -0,0 +1,131 @@
+package gitmanager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+)
+
+// DiffOptions configures DiffWorktrees. At most one of Stat, NameOnly and
+// Tool may be set.
+type DiffOptions struct {
+	// Stat prints a diffstat instead of a patch
+	Stat bool
+
+	// NameOnly prints only the names of the changed files
+	NameOnly bool
+
+	// Tool opens the changes in the configured `git difftool`
+	Tool bool
+
+	// Color colors the patch or diffstat
+	Color bool
+
+	// Paths limits the diff to these pathspecs, relative to the worktrees
+	Paths []string
+
+	// Stdin and Stdout are connected to git, so a difftool can prompt. Nil
+	// Stdout discards the output.
+	Stdin  io.Reader
+	Stdout io.Writer
+}
+
+// DiffWorktrees compares what is in the worktrees from and to right now:
+// their commits along with staged, unstaged and untracked changes. Ignored
+// files are left out. The diff shows what turns from into to.
+func (m *Manager) DiffWorktrees(ctx context.Context, ws *Workspace, from, to string, opts DiffOptions) error {
+	modes := 0
+	for _, set := range []bool{opts.Stat, opts.NameOnly, opts.Tool} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return newError(Usage, "only one of stat, name-only and tool may be used")
+	}
+
+	var dirs, trees [2]string
+	for i, name := range []string{from, to} {
+		wt, err := m.FindWorktree(ctx, ws, name)
+		if err != nil {
+			return err
+		}
+		if !dirExists(wt.Path) {
+			return newError(NotFound, "worktree '%s' is missing, its directory %s is gone", name, wt.Path)
+		}
+		dirs[i] = wt.Path
+		if trees[i], err = m.snapshotWorktree(ctx, wt.Path); err != nil {
+			return err
+		}
+	}
+
+	var args []string
+	switch {
+	case opts.Tool:
+		args = []string{"difftool"}
+	case opts.Stat:
+		args = []string{"diff", "--stat"}
+	case opts.NameOnly:
+		args = []string{"diff", "--name-only"}
+	default:
+		args = []string{"diff"}
+	}
+	if opts.Color && !opts.Tool && !opts.NameOnly {
+		args = append(args, "--color=always")
+	}
+	args = append(args, trees[0], trees[1], "--")
+	args = append(args, opts.Paths...)
+
+	stdout := opts.Stdout
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	// The difftool runs from the first worktree, as it needs a work tree
+	if _, err := m.git.Run(ctx, git.Invocation{Dir: dirs[0], Args: args, Stdin: opts.Stdin, Stdout: stdout}); err != nil {
+		return wrapGit(err, fmt.Sprintf("error comparing '%s' with '%s'", from, to))
+	}
+	return nil
+}
+
+// snapshotWorktree writes the tree of everything in the worktree at dir that
+// is not ignored, through a temporary index so the worktree's own index is
+// left alone. It returns the tree's hash.
+func (m *Manager) snapshotWorktree(ctx context.Context, dir string) (string, error) {
+	tmp, err := os.MkdirTemp("", "git-manager-index-*")
+	if err != nil {
+		return "", fmt.Errorf("error creating temporary index: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	index := filepath.Join(tmp, "index")
+
+	// Starting from a copy of the worktree's index spares hashing files
+	// that did not change
+	out, err := git.Output(ctx, m.git, dir, "rev-parse", "--git-path", "index")
+	if err != nil {
+		return "", wrapGit(err, "error locating the index")
+	}
+	current := strings.TrimSpace(out)
+	if !filepath.IsAbs(current) {
+		current = filepath.Join(dir, current)
+	}
+	if data, err := os.ReadFile(current); err == nil {
+		if err := os.WriteFile(index, data, 0644); err != nil {
+			return "", fmt.Errorf("error creating temporary index: %w", err)
+		}
+	}
+
+	env := []string{"GIT_INDEX_FILE=" + index}
+	if _, err := m.git.Run(ctx, git.Invocation{Dir: dir, Args: []string{"add", "--all"}, Env: env}); err != nil {
+		return "", wrapGit(err, fmt.Sprintf("error reading the changes in %s", dir))
+	}
+	res, err := m.git.Run(ctx, git.Invocation{Dir: dir, Args: []string{"write-tree"}, Env: env})
+	if err != nil {
+		return "", wrapGit(err, fmt.Sprintf("error reading the changes in %s", dir))
+	}
+	return strings.TrimSpace(res.Stdout), nil
+}
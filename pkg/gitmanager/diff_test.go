@@ -0,0 +1,59 @@
+package gitmanager_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// TestDiffWorktrees tests comparing committed, staged, unstaged and
+// untracked changes of two worktrees without touching their indexes
+func TestDiffWorktrees(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	feature := testWS.AddWorktree(t, "feature")
+	feature.CreateFile(t, "committed.txt", "committed\n")
+	feature.AddAndCommit(t, "Add committed", "committed.txt")
+	feature.CreateFile(t, "staged.txt", "staged\n")
+	feature.RunGit(t, "add", "staged.txt")
+	feature.CreateFile(t, "untracked.txt", "untracked\n")
+	feature.CreateFile(t, "ignored.log", "ignored\n")
+	testWS.Worktree("main").CreateFile(t, "README.md", "# Unstaged\n")
+	(&testutil.GitRepo{Path: testWS.GitDir}).CreateFile(t, "info/exclude", "*.log\n")
+
+	ws := &gitmanager.Workspace{Root: testWS.Root, GitDir: testWS.GitDir}
+
+	var out bytes.Buffer
+	if err := m.DiffWorktrees(ctx, ws, "main", "feature", gitmanager.DiffOptions{NameOnly: true, Stdout: &out}); err != nil {
+		t.Fatalf("DiffWorktrees failed: %v", err)
+	}
+	want := "README.md\ncommitted.txt\nstaged.txt\nuntracked.txt\n"
+	if out.String() != want {
+		t.Errorf("DiffWorktrees listed %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := m.DiffWorktrees(ctx, ws, "main", "feature", gitmanager.DiffOptions{Paths: []string{"README.md"}, Stdout: &out}); err != nil {
+		t.Fatalf("DiffWorktrees failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "-# Unstaged\n+# Test Repository\n") {
+		t.Errorf("Expected the unstaged README change reversed, got:\n%s", out.String())
+	}
+
+	// The worktrees' own indexes are left alone
+	if status := feature.RunGit(t, "status", "--porcelain"); status != "A  staged.txt\n?? untracked.txt\n" {
+		t.Errorf("Expected the feature worktree unchanged, got:\n%s", status)
+	}
+
+	err := m.DiffWorktrees(ctx, ws, "main", "feature", gitmanager.DiffOptions{Stat: true, NameOnly: true})
+	if !gitmanager.IsKind(err, gitmanager.Usage) {
+		t.Errorf("Expected a usage error for two output modes, got %v", err)
+	}
+}
@@ -0,0 +1,360 @@
+package gitmanager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+	"github.com/ingshtrom/git-manager/internal/plan"
+)
+
+// Severity says how bad a Problem is
+type Severity int
+
+const (
+	// SeverityWarning problems leave git-manager working but waste space or
+	// hide information
+	SeverityWarning Severity = iota
+	// SeverityError problems break git or git-manager for the affected
+	// worktrees
+	SeverityError
+)
+
+// String returns "warning" or "error"
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// The problems Diagnose reports
+const (
+	// ProblemBrokenLink is a worktree whose .git file and the repository's
+	// record of it no longer point at each other, usually after a move
+	ProblemBrokenLink = "broken-link"
+	// ProblemPrunable is a worktree entry whose directory is gone
+	ProblemPrunable = "prunable"
+	// ProblemOrphanDir is a directory in the workspace that is not a
+	// worktree, such as the remains of a failed add
+	ProblemOrphanDir = "orphan-dir"
+	// ProblemMissingRefspec is a remote without a fetch refspec, which bare
+	// clones lack, so fetching never updates remote-tracking branches
+	ProblemMissingRefspec = "missing-refspec"
+)
+
+// Problem is something wrong with a workspace
+type Problem struct {
+	Severity Severity
+
+	// Code identifies the kind of problem, one of the Problem constants
+	Code string
+
+	// Message describes the problem
+	Message string
+
+	// Path is the directory the problem is about, if any
+	Path string
+
+	// Fix describes the repair, in the form printed for a dry run. Empty
+	// means the problem cannot be repaired automatically.
+	Fix string
+
+	// Destructive is set when the repair deletes something, so it should be
+	// confirmed first
+	Destructive bool
+
+	repair plan.Step
+}
+
+// Diagnose checks ws for broken worktree links, prunable worktree entries,
+// stray directories and remotes without a fetch refspec. Problems are
+// returned in the order Repair should fix them: links are repaired before
+// anything is pruned, since an entry that looks prunable may just have
+// moved.
+func (m *Manager) Diagnose(ctx context.Context, ws *Workspace) ([]Problem, error) {
+	worktrees, err := m.ListWorktrees(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	bare := false
+	known := map[string]bool{}
+	for _, wt := range worktrees {
+		known[wt.Path] = true
+		if wt.IsBare {
+			bare = true
+		}
+	}
+
+	var problems []Problem
+
+	// Only bare workspaces keep worktrees next to each other; in a normal
+	// clone the directories under the root are the project's own
+	if bare {
+		found, err := m.checkWorkspaceDirs(ctx, ws, known)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, found...)
+	}
+
+	// One prune fixes every prunable entry, so they share the step and
+	// Repair runs it once
+	prune := m.gitStep(ctx, ws.Root, "error pruning worktrees", "-C", ws.GitDir, "worktree", "prune")
+	for _, wt := range worktrees {
+		if !wt.Prunable {
+			continue
+		}
+		p := Problem{
+			Severity: SeverityWarning,
+			Code:     ProblemPrunable,
+			Message:  fmt.Sprintf("worktree directory %s no longer exists", wt.Path),
+			Path:     wt.Path,
+		}
+		p.setRepair(prune)
+		problems = append(problems, p)
+	}
+
+	if bare {
+		found, err := m.checkRefspecs(ctx, ws)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, found...)
+	}
+
+	return problems, nil
+}
+
+// checkWorkspaceDirs looks at the directories in the workspace root for
+// worktrees with broken links and directories that are not worktrees.
+// Worktrees of branches with slashes are nested, such as <root>/feature/login,
+// so directories on the way to a worktree are walked rather than judged.
+func (m *Manager) checkWorkspaceDirs(ctx context.Context, ws *Workspace, known map[string]bool) ([]Problem, error) {
+	links, orphans, _, err := m.checkDir(ctx, ws, ws.Root, known)
+	if err != nil {
+		return nil, err
+	}
+	return append(links, orphans...), nil
+}
+
+// checkDir checks the directories in dir, see checkWorkspaceDirs. It also
+// reports whether dir holds a worktree, a clone or the path of a registered
+// worktree anywhere below it, in which case it must be kept.
+func (m *Manager) checkDir(ctx context.Context, ws *Workspace, dir string, known map[string]bool) ([]Problem, []Problem, bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("error reading workspace: %w", err)
+	}
+
+	var links, orphans []Problem
+	keep := false
+	for _, entry := range entries {
+		// Hidden directories in the root are the repository's own
+		if !entry.IsDir() || entry.Name() == ".git" || (dir == ws.Root && strings.HasPrefix(entry.Name(), ".")) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+
+		info, err := os.Stat(filepath.Join(path, ".git"))
+		switch {
+		case err == nil && info.IsDir():
+			// A separate clone, not ours to judge
+			keep = true
+			continue
+		case err == nil:
+			keep = true
+			if msg := checkLink(ws, path); msg != "" {
+				p := Problem{Severity: SeverityError, Code: ProblemBrokenLink, Message: msg, Path: path}
+				p.setRepair(m.gitStep(ctx, ws.Root, "error repairing worktree", "-C", ws.GitDir, "worktree", "repair", path))
+				links = append(links, p)
+			}
+			continue
+		}
+
+		subLinks, subOrphans, subKeep, err := m.checkDir(ctx, ws, path, known)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if subKeep || holdsKnown(path, known) {
+			keep = true
+			links = append(links, subLinks...)
+			orphans = append(orphans, subOrphans...)
+			continue
+		}
+
+		p := Problem{
+			Severity:    SeverityWarning,
+			Code:        ProblemOrphanDir,
+			Message:     fmt.Sprintf("%s is not a worktree, maybe left over from a failed add", path),
+			Path:        path,
+			Destructive: true,
+		}
+		p.setRepair(plan.Step{
+			Description: plan.Command("rm", "-rf", path),
+			Run: func() error {
+				return os.RemoveAll(path)
+			},
+		})
+		orphans = append(orphans, p)
+	}
+	return links, orphans, keep, nil
+}
+
+// holdsKnown reports whether dir is the path of a registered worktree or has
+// one inside it
+func holdsKnown(dir string, known map[string]bool) bool {
+	for path := range known {
+		if isWithin(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLink returns what is wrong with the links between the worktree in dir
+// and ws, or "" if they point at each other
+func checkLink(ws *Workspace, dir string) string {
+	dotGit := filepath.Join(dir, ".git")
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return fmt.Sprintf("cannot read %s: %v", dotGit, err)
+	}
+	adminDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return fmt.Sprintf("%s is not a valid .git file", dotGit)
+	}
+	if !filepath.IsAbs(adminDir) {
+		adminDir = filepath.Join(dir, adminDir)
+	}
+	adminDir = filepath.Clean(adminDir)
+
+	if _, err := os.Stat(adminDir); err != nil {
+		return fmt.Sprintf("%s points to %s, which does not exist", dotGit, adminDir)
+	}
+	if filepath.Dir(adminDir) != filepath.Join(ws.GitDir, "worktrees") {
+		return fmt.Sprintf("%s points to %s, which is not part of %s", dotGit, adminDir, ws.GitDir)
+	}
+
+	data, err = os.ReadFile(filepath.Join(adminDir, "gitdir"))
+	if err != nil {
+		return fmt.Sprintf("the repository has no record of where %s is", dir)
+	}
+	back := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(back) {
+		back = filepath.Join(adminDir, back)
+	}
+	if filepath.Clean(back) != dotGit {
+		return fmt.Sprintf("the repository expects this worktree at %s", filepath.Dir(filepath.Clean(back)))
+	}
+	return ""
+}
+
+// checkRefspecs reports remotes without a fetch refspec
+func (m *Manager) checkRefspecs(ctx context.Context, ws *Workspace) ([]Problem, error) {
+	remotes, err := m.missingRefspecs(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	for _, remote := range remotes {
+		p := Problem{
+			Severity: SeverityWarning,
+			Code:     ProblemMissingRefspec,
+			Message:  fmt.Sprintf("remote '%s' has no fetch refspec, so fetching does not update remote-tracking branches", remote),
+			Path:     ws.GitDir,
+		}
+		p.setRepair(m.gitStep(ctx, ws.Root, "error setting fetch refspec", "-C", ws.GitDir, "config", "remote."+remote+".fetch", "+refs/heads/*:refs/remotes/"+remote+"/*"))
+		problems = append(problems, p)
+	}
+	return problems, nil
+}
+
+// missingRefspecs returns the remotes of ws without a fetch refspec, sorted
+func (m *Manager) missingRefspecs(ctx context.Context, ws *Workspace) ([]string, error) {
+	out, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "remote")
+	if err != nil {
+		return nil, wrapGit(err, "error listing remotes")
+	}
+	remotes := strings.Fields(out)
+	sort.Strings(remotes)
+
+	var missing []string
+	for _, remote := range remotes {
+		if _, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "config", "--get-all", "remote."+remote+".fetch"); err == nil {
+			continue
+		} else if ctx.Err() != nil {
+			return nil, wrapGit(err, "error reading remote configuration")
+		}
+		missing = append(missing, remote)
+	}
+	return missing, nil
+}
+
+// setRepair makes step the repair of p
+func (p *Problem) setRepair(step plan.Step) {
+	p.repair = step
+	p.Fix = step.Description
+}
+
+// RepairOptions configures Repair
+type RepairOptions struct {
+	// Confirm is asked before destructive repairs. Nil declines them all.
+	Confirm func(Problem) bool
+
+	// DryRun reports what would be repaired but changes nothing
+	DryRun bool
+}
+
+// RepairResult is what happened to a problem
+type RepairResult struct {
+	Problem Problem
+
+	// Fixed is set when the repair ran and succeeded
+	Fixed bool
+
+	// Skipped is set when the problem has no automatic repair or a
+	// destructive repair was not confirmed
+	Skipped bool
+
+	// Err is the repair's error
+	Err error
+}
+
+// Repair fixes problems found by Diagnose, in order. A failing repair does
+// not stop the others; its error is in its result. Problems sharing a repair,
+// like prunable worktrees, run it once.
+func (m *Manager) Repair(ctx context.Context, problems []Problem, opts RepairOptions) []RepairResult {
+	results := make([]RepairResult, len(problems))
+	done := map[string]error{}
+	for i, p := range problems {
+		results[i].Problem = p
+
+		if p.repair.Run == nil || p.Destructive && (opts.Confirm == nil || !opts.Confirm(p)) {
+			results[i].Skipped = true
+			continue
+		}
+		if opts.DryRun {
+			continue
+		}
+
+		err, ok := done[p.Fix]
+		if !ok {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			} else {
+				err = p.repair.Run()
+			}
+			done[p.Fix] = err
+		}
+		results[i].Err = err
+		results[i].Fixed = err == nil
+	}
+	return results
+}
@@ -0,0 +1,148 @@
+package gitmanager_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// TestDiagnoseAndRepair tests that Diagnose finds each kind of problem and
+// Repair fixes all of them
+func TestDiagnoseAndRepair(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	// A worktree deleted by hand, one moved by hand and a failed add
+	gone := testWS.AddWorktree(t, "gone")
+	if err := os.RemoveAll(gone.Path); err != nil {
+		t.Fatal(err)
+	}
+	moved := testWS.AddWorktree(t, "moved")
+	movedTo := filepath.Join(testWS.Root, "renamed")
+	if err := os.Rename(moved.Path, movedTo); err != nil {
+		t.Fatal(err)
+	}
+	stray := filepath.Join(testWS.Root, "stray")
+	if err := os.Mkdir(stray, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A worktree of a branch with a slash, nested in feature/ next to the
+	// leftovers of a failed add, and a nested worktree moved by hand
+	login := testWS.AddWorktree(t, "feature/login")
+	login.CreateFile(t, "wip.txt", "not committed\n")
+	leftover := filepath.Join(testWS.Root, "feature", "leftover")
+	if err := os.Mkdir(leftover, 0755); err != nil {
+		t.Fatal(err)
+	}
+	nested := testWS.AddWorktree(t, "team/moved")
+	nestedTo := filepath.Join(testWS.Root, "team", "relocated")
+	if err := os.Rename(nested.Path, nestedTo); err != nil {
+		t.Fatal(err)
+	}
+
+	ws, err := m.Resolve(ctx, gitmanager.Target{Dir: testWS.Root})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	problems, err := m.Diagnose(ctx, ws)
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+	found := map[string]string{}
+	for _, p := range problems {
+		found[p.Code+" "+p.Path] = p.Fix
+	}
+	for _, want := range []string{
+		gitmanager.ProblemBrokenLink + " " + movedTo,
+		gitmanager.ProblemBrokenLink + " " + nestedTo,
+		gitmanager.ProblemPrunable + " " + gone.Path,
+		gitmanager.ProblemPrunable + " " + moved.Path,
+		gitmanager.ProblemPrunable + " " + nested.Path,
+		gitmanager.ProblemOrphanDir + " " + stray,
+		gitmanager.ProblemOrphanDir + " " + leftover,
+		gitmanager.ProblemMissingRefspec + " " + ws.GitDir,
+	} {
+		if _, ok := found[want]; !ok {
+			t.Errorf("Expected problem %q, got %+v", want, problems)
+		}
+	}
+	if len(problems) != 8 {
+		t.Errorf("Expected 8 problems, got %+v", problems)
+	}
+	if problems[0].Code != gitmanager.ProblemBrokenLink {
+		t.Errorf("Expected broken links to be repaired first, got %s", problems[0].Code)
+	}
+
+	// Declining the removal keeps the directory
+	results := m.Repair(ctx, problems, gitmanager.RepairOptions{})
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("Repair of %s failed: %v", r.Problem.Code, r.Err)
+		}
+		if r.Problem.Code == gitmanager.ProblemOrphanDir && !r.Skipped {
+			t.Errorf("Expected the unconfirmed removal to be skipped")
+		}
+	}
+	if _, err := os.Stat(stray); err != nil {
+		t.Errorf("Expected %s to be kept: %v", stray, err)
+	}
+
+	problems, err = m.Diagnose(ctx, ws)
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+	if len(problems) != 2 || problems[0].Code != gitmanager.ProblemOrphanDir || problems[1].Code != gitmanager.ProblemOrphanDir {
+		t.Fatalf("Expected only the stray directories to be left, got %+v", problems)
+	}
+
+	m.Repair(ctx, problems, gitmanager.RepairOptions{Confirm: func(gitmanager.Problem) bool { return true }})
+	for _, dir := range []string{stray, leftover} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", dir, err)
+		}
+	}
+	// The nested worktree and its uncommitted work are untouched
+	login.AssertFileContent(t, "wip.txt", "not committed\n")
+
+	// The moved worktree is usable at its new path
+	wt, err := m.FindWorktree(ctx, ws, "renamed")
+	if err != nil {
+		t.Fatalf("FindWorktree failed after repair: %v", err)
+	}
+	if wt.Branch != "moved" {
+		t.Errorf("Expected the repaired worktree on branch moved, got %q", wt.Branch)
+	}
+}
+
+// TestDiagnoseCleanWorkspace tests that a healthy non-bare clone has no
+// problems, and that its directories are not mistaken for stray worktrees
+func TestDiagnoseCleanWorkspace(t *testing.T) {
+	repo, cleanup := testutil.SetupGitRepo(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	if err := os.Mkdir(filepath.Join(repo.Path, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ws, err := m.Resolve(ctx, gitmanager.Target{Dir: repo.Path})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	problems, err := m.Diagnose(ctx, ws)
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Expected no problems, got %+v", problems)
+	}
+}
@@ -0,0 +1,401 @@
+package gitmanager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+	"github.com/ingshtrom/git-manager/internal/plan"
+)
+
+// ejectStaging is where the kept checkout is parked while the workspace
+// turns back into a clone
+const ejectStaging = ".git-manager-eject"
+
+// EjectOptions configures EjectRepository
+type EjectOptions struct {
+	// Keep selects the worktree that becomes the clone's checkout, see
+	// FindWorktree. Empty picks the worktree on the repository's default
+	// branch.
+	Keep string
+
+	// Dest is where the clone ends up. Empty keeps it in the workspace
+	// directory.
+	Dest string
+
+	// Stash stashes the uncommitted changes of the other worktrees instead
+	// of refusing to remove them. Everything is stashed, ignored files such
+	// as .env included, as removing a worktree deletes them.
+	Stash bool
+
+	// DryRun resolves and validates everything but changes nothing
+	DryRun bool
+}
+
+// EjectResult describes a workspace converted by EjectRepository
+type EjectResult struct {
+	// Path is the clone's directory
+	Path string
+
+	// Kept is the worktree that became the clone's checkout
+	Kept Worktree
+
+	// Removed lists the other worktrees, which were removed
+	Removed []Worktree
+
+	// Stashed lists the worktrees whose changes, ignored files included,
+	// were stashed
+	Stashed []Worktree
+
+	// Plan lists the planned steps, in the form printed for a dry run
+	Plan []string
+}
+
+// EjectRepository turns the workspace ws back into a normal clone: the
+// other worktrees are removed, the kept worktree's checkout, with its
+// uncommitted changes, moves to the top of the workspace and the bare
+// repository becomes its .git directory. Branches and stashes are kept and
+// the workspace is unregistered. The clone gets a fetch refspec for every
+// remote that lacks one, as bare clones do. It returns a Dirty error when
+// another worktree has uncommitted changes or ignored files, which removing
+// it would delete, and Stash is not set.
+func (m *Manager) EjectRepository(ctx context.Context, ws *Workspace, opts EjectOptions) (*EjectResult, error) {
+	if filepath.Base(ws.GitDir) != ".git" || filepath.Dir(ws.GitDir) != ws.Root {
+		return nil, newError(Usage, "%s is not a git-manager workspace", ws.Root)
+	}
+
+	dest := ws.Root
+	if opts.Dest != "" {
+		var err error
+		if dest, err = filepath.Abs(opts.Dest); err != nil {
+			return nil, fmt.Errorf("error resolving destination: %w", err)
+		}
+		if _, err := os.Stat(dest); err == nil && dest != ws.Root {
+			return nil, newError(Conflict, "%s already exists", dest)
+		}
+	}
+
+	worktrees, err := m.ListWorktrees(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	var bareEntry *Worktree
+	var linked []Worktree
+	prunable := false
+	for i, wt := range worktrees {
+		switch {
+		case wt.IsBare:
+			bareEntry = &worktrees[i]
+		case wt.Prunable:
+			prunable = true
+		default:
+			linked = append(linked, wt)
+		}
+	}
+	if bareEntry == nil {
+		return nil, newError(Usage, "%s is already a normal clone", ws.Root)
+	}
+
+	keep, err := m.ejectKeep(ctx, ws, linked, opts.Keep)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check everything before changing anything
+	var others, dirty []Worktree
+	var changed, ignored []string
+	for _, wt := range linked {
+		if wt.Path == keep.Path {
+			continue
+		}
+		if wt.Locked {
+			return nil, newError(Conflict, "worktree %s is locked, unlock it with `git worktree unlock` first", wt.Path)
+		}
+		out, err := git.Output(ctx, m.git, wt.Path, "status", "--porcelain", "--ignored")
+		if err != nil {
+			return nil, wrapGit(err, "error checking worktree status")
+		}
+		isDirty, hasIgnored := false, false
+		for _, line := range strings.Split(out, "\n") {
+			if strings.HasPrefix(line, "!! ") {
+				hasIgnored = true
+			} else if line != "" {
+				isDirty = true
+			}
+		}
+		if isDirty {
+			changed = append(changed, wt.Path)
+		} else if hasIgnored {
+			ignored = append(ignored, wt.Path)
+		}
+		if isDirty || hasIgnored {
+			dirty = append(dirty, wt)
+		}
+		others = append(others, wt)
+	}
+	if !opts.Stash {
+		// git worktree remove deletes ignored files without asking
+		switch {
+		case len(changed) > 0:
+			return nil, newError(Dirty, "these worktrees have uncommitted changes: %s, commit them or use --stash", strings.Join(changed, ", "))
+		case len(ignored) > 0:
+			return nil, newError(Dirty, "these worktrees have ignored files that removing them would delete: %s, move them out or use --stash", strings.Join(ignored, ", "))
+		}
+	}
+	if strays := ejectStrays(ws, linked); len(strays) > 0 {
+		return nil, newError(Conflict, "%s contains %s, which would end up in the clone, move them out first", ws.Root, strings.Join(strays, ", "))
+	}
+
+	// HEAD of the bare repository may be detached
+	restoreHeadArgs := []string{"-C", ws.GitDir, "symbolic-ref", "HEAD"}
+	oldHead, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "symbolic-ref", "--quiet", "HEAD")
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, wrapGit(err, "error reading HEAD")
+		}
+		if oldHead, err = git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "rev-parse", "--verify", "HEAD"); err != nil {
+			return nil, wrapGit(err, "error reading HEAD")
+		}
+		restoreHeadArgs = []string{"-C", ws.GitDir, "update-ref", "--no-deref", "HEAD"}
+	}
+	restoreHeadArgs = append(restoreHeadArgs, strings.TrimSpace(oldHead))
+
+	refspecs, err := m.missingRefspecs(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	var p plan.Plan
+
+	if prunable {
+		p.Add(m.gitStep(ctx, ws.Root, "error pruning worktrees", "-C", ws.GitDir, "worktree", "prune"))
+	}
+
+	for _, wt := range dirty {
+		stash := m.gitStep(ctx, wt.Path, "error stashing changes", "-C", wt.Path, "stash", "push", "--all", "-m", "git-manager eject: "+filepath.Base(wt.Path))
+		stash.Progress = fmt.Sprintf("Stashing changes in %s...", wt.Path)
+		stash.Undo = m.undoStep(ctx, wt.Path, "-C", wt.Path, "stash", "pop", "--index")
+		p.Add(stash)
+	}
+
+	for _, wt := range others {
+		remove := m.gitStep(ctx, ws.Root, "error removing worktree", "-C", ws.GitDir, "worktree", "remove", wt.Path)
+		remove.Progress = fmt.Sprintf("Removing worktree %s...", wt.Path)
+		readd := m.undoStep(ctx, ws.Root, "-C", ws.GitDir, "worktree", "add", wt.Path, wt.Branch)
+		if wt.Branch == "" {
+			readd = m.undoStep(ctx, ws.Root, "-C", ws.GitDir, "worktree", "add", "--detach", wt.Path, wt.Commit)
+		}
+		run := remove.Run
+		path := wt.Path
+		remove.Run = func() error {
+			if err := run(); err != nil {
+				return err
+			}
+			removeEmptyParents(filepath.Dir(path), ws.Root)
+			return nil
+		}
+		remove.Undo = readd
+		p.Add(remove)
+	}
+
+	staging := filepath.Join(ws.Root, ejectStaging)
+	p.Add(plan.Step{
+		Description: fmt.Sprintf("move the checkout in %s to %s", keep.Path, staging),
+		Progress:    fmt.Sprintf("Turning %s into a clone...", ws.Root),
+		Run: func() error {
+			if err := os.Mkdir(staging, 0755); err != nil {
+				return fmt.Errorf("error creating %s: %w", staging, err)
+			}
+			return moveEntries(keep.Path, staging, ".git")
+		},
+		Undo: func() error {
+			if err := moveEntries(staging, keep.Path); err != nil {
+				return err
+			}
+			return os.Remove(staging)
+		},
+	})
+
+	// Hand the kept worktree's HEAD and index to the repository
+	head := []string{"-C", ws.GitDir, "symbolic-ref", "HEAD", "refs/heads/" + keep.Branch}
+	if keep.Branch == "" {
+		head = []string{"-C", ws.GitDir, "update-ref", "--no-deref", "HEAD", keep.Commit}
+	}
+	setHead := m.gitStep(ctx, ws.Root, "error setting HEAD", head...)
+	restoreHead := m.undoStep(ctx, ws.Root, restoreHeadArgs...)
+	readdKeep := m.undoStep(ctx, ws.Root, "-C", ws.GitDir, "worktree", "add", "--no-checkout", keep.Path, keep.Branch)
+	if keep.Branch == "" {
+		readdKeep = m.undoStep(ctx, ws.Root, "-C", ws.GitDir, "worktree", "add", "--no-checkout", "--detach", keep.Path, keep.Commit)
+	}
+	p.Add(plan.Step{
+		Description: fmt.Sprintf("make %s the checkout of %s", keep.Path, ws.GitDir),
+		Run: func() error {
+			adminDir, err := worktreeAdminDir(keep.Path)
+			if err != nil {
+				return err
+			}
+			if err := setHead.Run(); err != nil {
+				return err
+			}
+			if err := os.Rename(filepath.Join(adminDir, "index"), filepath.Join(ws.GitDir, "index")); err != nil {
+				return fmt.Errorf("error moving the index: %w", err)
+			}
+			if err := os.RemoveAll(adminDir); err != nil {
+				return fmt.Errorf("error removing %s: %w", adminDir, err)
+			}
+			if err := os.RemoveAll(keep.Path); err != nil {
+				return fmt.Errorf("error removing %s: %w", keep.Path, err)
+			}
+			removeEmptyParents(filepath.Dir(keep.Path), ws.Root)
+			return nil
+		},
+		Undo: func() error {
+			if err := restoreHead(); err != nil {
+				return err
+			}
+			if _, err := os.Stat(keep.Path); err == nil {
+				return nil
+			}
+			if err := readdKeep(); err != nil {
+				return err
+			}
+			adminDir, err := worktreeAdminDir(keep.Path)
+			if err != nil {
+				return err
+			}
+			return os.Rename(filepath.Join(ws.GitDir, "index"), filepath.Join(adminDir, "index"))
+		},
+	})
+
+	// A normal clone fetches into remote-tracking branches
+	for _, remote := range refspecs {
+		key := "remote." + remote + ".fetch"
+		refspec := m.gitStep(ctx, ws.Root, "error setting fetch refspec", "-C", ws.GitDir, "config", key, "+refs/heads/*:refs/remotes/"+remote+"/*")
+		refspec.Undo = m.undoStep(ctx, ws.Root, "-C", ws.GitDir, "config", "--unset-all", key)
+		p.Add(refspec)
+	}
+
+	notBare := m.gitStep(ctx, ws.Root, "error converting to a normal repository", "-C", ws.GitDir, "config", "core.bare", "false")
+	notBare.Undo = m.undoStep(ctx, ws.Root, "-C", ws.GitDir, "config", "core.bare", "true")
+	p.Add(notBare)
+
+	p.Add(plan.Step{
+		Description: fmt.Sprintf("move the checkout from %s to %s", staging, ws.Root),
+		Run: func() error {
+			if err := moveEntries(staging, ws.Root); err != nil {
+				return err
+			}
+			return os.Remove(staging)
+		},
+		Undo: func() error {
+			if err := os.MkdirAll(staging, 0755); err != nil {
+				return err
+			}
+			return moveEntries(ws.Root, staging, ".git", ejectStaging)
+		},
+	})
+
+	if dest != ws.Root {
+		if parent := filepath.Dir(dest); !dirExists(parent) {
+			p.Add(plan.Mkdir(parent))
+		}
+		p.Add(plan.Step{
+			Description: fmt.Sprintf("move %s to %s", ws.Root, dest),
+			Run: func() error {
+				if err := os.Rename(ws.Root, dest); err != nil {
+					return fmt.Errorf("error moving clone: %w", err)
+				}
+				return nil
+			},
+			Undo: func() error {
+				return os.Rename(dest, ws.Root)
+			},
+		})
+	}
+
+	if ws.Name != "" {
+		name := ws.Name
+		p.Add(plan.Step{
+			Description: fmt.Sprintf("unregister repository '%s'", name),
+			Run: func() error {
+				return m.Unregister(name)
+			},
+			Undo: func() error {
+				return m.register(name, ws.Root)
+			},
+		})
+	}
+
+	steps, err := m.run(&p, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	return &EjectResult{Path: dest, Kept: *keep, Removed: others, Stashed: dirty, Plan: steps}, nil
+}
+
+// ejectKeep picks the worktree to keep: the one called name, or the one on
+// the repository's default branch
+func (m *Manager) ejectKeep(ctx context.Context, ws *Workspace, linked []Worktree, name string) (*Worktree, error) {
+	if name != "" {
+		return m.FindWorktree(ctx, ws, name)
+	}
+	if len(linked) == 1 {
+		return &linked[0], nil
+	}
+
+	head, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err == nil {
+		for i, wt := range linked {
+			if wt.Branch == strings.TrimSpace(head) {
+				return &linked[i], nil
+			}
+		}
+	} else if ctx.Err() != nil {
+		return nil, wrapGit(err, "error reading HEAD")
+	}
+	if len(linked) == 0 {
+		return nil, newError(NotFound, "%s has no worktree to keep", ws.Root)
+	}
+	return nil, newError(Usage, "choose the worktree to keep with --keep")
+}
+
+// ejectStrays returns the entries of the workspace directory that are
+// neither its repository nor part of a worktree's path
+func ejectStrays(ws *Workspace, linked []Worktree) []string {
+	entries, err := os.ReadDir(ws.Root)
+	if err != nil {
+		return nil
+	}
+
+	known := map[string]bool{".git": true}
+	for _, wt := range linked {
+		if rel, err := filepath.Rel(ws.Root, wt.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			known[strings.Split(rel, string(filepath.Separator))[0]] = true
+		}
+	}
+
+	var strays []string
+	for _, entry := range entries {
+		if !known[entry.Name()] {
+			strays = append(strays, entry.Name())
+		}
+	}
+	sort.Strings(strays)
+	return strays
+}
+
+// removeEmptyParents removes dir and its parents up to, but not including,
+// root, for as long as they are empty
+func removeEmptyParents(dir string, root string) {
+	for dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
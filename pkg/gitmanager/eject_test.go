@@ -0,0 +1,129 @@
+package gitmanager_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// TestEjectRepository tests turning a workspace back into a clone without
+// losing branches, stashes or the kept worktree's changes
+func TestEjectRepository(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	main := testWS.Worktree("main")
+	main.CreateFile(t, "README.md", "unstaged\n")
+	main.CreateFile(t, "staged.txt", "staged\n")
+	main.RunGit(t, "add", "staged.txt")
+	status := main.RunGit(t, "status", "--porcelain")
+
+	// Removing a worktree would delete its ignored files
+	if err := os.WriteFile(filepath.Join(testWS.GitDir, "info", "exclude"), []byte(".env\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	feature := testWS.AddWorktree(t, "feature/login")
+	feature.CreateFile(t, ".env", "SECRET=1\n")
+
+	if _, err := m.Register(testWS.Root, "api"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	ws, err := m.Resolve(ctx, gitmanager.Target{Repo: "api"})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	dest := filepath.Join(testWS.TempDir, "clone")
+	if _, err := m.EjectRepository(ctx, ws, gitmanager.EjectOptions{Dest: dest}); gitmanager.KindOf(err) != gitmanager.Dirty || !strings.Contains(err.Error(), "ignored files") {
+		t.Fatalf("Expected a dirty error for the ignored file, got %v", err)
+	}
+	feature.CreateFile(t, "login.txt", "work in progress\n")
+	if _, err := m.EjectRepository(ctx, ws, gitmanager.EjectOptions{Dest: dest}); gitmanager.KindOf(err) != gitmanager.Dirty {
+		t.Fatalf("Expected a dirty error for the feature worktree, got %v", err)
+	}
+
+	res, err := m.EjectRepository(ctx, ws, gitmanager.EjectOptions{Dest: dest, Stash: true})
+	if err != nil {
+		t.Fatalf("EjectRepository failed: %v", err)
+	}
+	if res.Kept.Branch != "main" || len(res.Stashed) != 1 || res.Stashed[0].Path != feature.Path {
+		t.Errorf("Unexpected result %+v", res)
+	}
+
+	clone := &testutil.GitRepo{Path: dest}
+	if got := clone.RunGit(t, "status", "--porcelain"); got != status {
+		t.Errorf("Status changed by ejecting, was:\n%s\nnow:\n%s", status, got)
+	}
+	if branch := clone.RunGit(t, "branch", "--show-current"); branch != "main\n" {
+		t.Errorf("Expected main checked out, got %q", branch)
+	}
+	clone.AssertBranchExists(t, "feature/login")
+	if stash := clone.RunGit(t, "stash", "list"); !strings.Contains(stash, "git-manager eject: login") {
+		t.Errorf("Expected the feature worktree's changes in a stash, got %q", stash)
+	}
+	if env := clone.RunGit(t, "show", "stash@{0}^3:.env"); env != "SECRET=1\n" {
+		t.Errorf("Expected the ignored file in the stash, got %q", env)
+	}
+	if refspec := clone.RunGit(t, "config", "--get-all", "remote.origin.fetch"); refspec != "+refs/heads/*:refs/remotes/origin/*\n" {
+		t.Errorf("Expected the clone to fetch into remote-tracking branches, got %q", refspec)
+	}
+	if worktrees := clone.RunGit(t, "worktree", "list", "--porcelain"); strings.Count(worktrees, "worktree ") != 1 {
+		t.Errorf("Expected only the clone's own worktree, got:\n%s", worktrees)
+	}
+	if _, err := os.Stat(testWS.Root); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be gone, got %v", testWS.Root, err)
+	}
+	if _, err := m.Repository("api"); gitmanager.KindOf(err) != gitmanager.NotFound {
+		t.Errorf("Expected api to be unregistered, got %v", err)
+	}
+}
+
+// TestEjectRepositoryRollsBack tests that a failed eject leaves the
+// workspace as it was
+func TestEjectRepositoryRollsBack(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	main := testWS.Worktree("main")
+	main.CreateFile(t, "README.md", "unstaged\n")
+	status := main.RunGit(t, "status", "--porcelain")
+	testWS.AddWorktree(t, "feature")
+	// The bare repository's HEAD may be detached
+	bare := &testutil.GitRepo{Path: testWS.GitDir}
+	bare.RunGit(t, "update-ref", "--no-deref", "HEAD", "main")
+	head := bare.RunGit(t, "rev-parse", "HEAD")
+
+	fake := testutil.GitVersion(t, "2.39.5")
+	fake.On("core.bare", "false").Fail(1, "error: could not lock config file")
+	m := gitmanager.New(gitmanager.Options{
+		Git:        fake,
+		ConfigPath: filepath.Join(t.TempDir(), "config.json"),
+	})
+	ws := &gitmanager.Workspace{Root: testWS.Root, GitDir: testWS.GitDir}
+
+	if _, err := m.EjectRepository(ctx, ws, gitmanager.EjectOptions{Keep: "main"}); gitmanager.KindOf(err) != gitmanager.GitFailure {
+		t.Fatalf("Expected a git failure, got %v", err)
+	}
+
+	if got := main.RunGit(t, "status", "--porcelain"); got != status {
+		t.Errorf("Status changed by the failed eject, was:\n%s\nnow:\n%s", status, got)
+	}
+	testWS.Worktree("feature").RunGit(t, "status")
+	if got := bare.RunGit(t, "rev-parse", "HEAD"); got != head {
+		t.Errorf("Expected HEAD back at %s, got %s", head, got)
+	}
+	if _, err := testutil.NewCommand("git", "-C", testWS.GitDir, "symbolic-ref", "--quiet", "HEAD").Output(); err == nil {
+		t.Errorf("Expected HEAD to be detached again")
+	}
+	if refspec, err := testutil.NewCommand("git", "-C", testWS.GitDir, "config", "remote.origin.fetch").Output(); err == nil {
+		t.Errorf("Expected the fetch refspec to be rolled back, got %q", refspec)
+	}
+}
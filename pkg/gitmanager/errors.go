@@ -0,0 +1,59 @@
+package gitmanager
+
+import (
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/internal/git"
+)
+
+// Error is the error type returned by a Manager. Use KindOf or errors.Is with
+// &Error{Kind: k} to tell failures apart.
+type Error = errs.Error
+
+// GitError describes a failed git invocation, including git's stderr. Errors
+// of kind GitFailure wrap one; use errors.As to get at it.
+type GitError = git.Error
+
+// ErrorKind classifies an Error
+type ErrorKind = errs.Kind
+
+// The kinds of error a Manager returns. Each maps to a distinct exit code of
+// the git-manager binary, see ExitCode.
+const (
+	Unknown     = errs.Unknown
+	Usage       = errs.Usage
+	NotInRepo   = errs.NotInRepo
+	NotFound    = errs.NotFound
+	Ambiguous   = errs.Ambiguous
+	Dirty       = errs.Dirty
+	Conflict    = errs.Conflict
+	GitFailure  = errs.GitFailure
+	Timeout     = errs.Timeout
+	Interrupted = errs.Interrupted
+	Unsupported = errs.Unsupported
+)
+
+// KindOf returns the kind of err, or Unknown
+func KindOf(err error) ErrorKind {
+	return errs.KindOf(err)
+}
+
+// IsKind reports whether err, or any error it wraps, has the given kind
+func IsKind(err error, kind ErrorKind) bool {
+	return errs.Is(err, kind)
+}
+
+// ExitCode returns the exit code the git-manager binary uses for err
+func ExitCode(err error) int {
+	return errs.ExitCode(err)
+}
+
+// newError returns an error of the given kind with a formatted message
+func newError(kind ErrorKind, format string, args ...any) error {
+	return errs.New(kind, format, args...)
+}
+
+// wrapGit adds msg as context to an error from git, keeping its kind so
+// timeouts and interruptions are not reported as git failures
+func wrapGit(err error, msg string) error {
+	return errs.Annotate(errs.GitFailure, err, "%s", msg)
+}
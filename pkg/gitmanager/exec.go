@@ -0,0 +1,166 @@
+package gitmanager
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+)
+
+// DefaultExecJobs is how many commands Exec runs at once unless told
+// otherwise
+const DefaultExecJobs = 4
+
+// ExecStatus is how running a command in a worktree went
+type ExecStatus string
+
+// The outcomes of running a command
+const (
+	ExecOK        ExecStatus = "ok"
+	ExecFailed    ExecStatus = "failed"
+	ExecCancelled ExecStatus = "cancelled"
+	ExecSkipped   ExecStatus = "skipped"
+)
+
+// ExecTarget is a worktree Exec runs a command in
+type ExecTarget struct {
+	Workspace *Workspace
+	Worktree  Worktree
+}
+
+// ExecOptions configures Exec
+type ExecOptions struct {
+	// Jobs is how many commands run at once. Zero uses DefaultExecJobs.
+	Jobs int
+
+	// FailFast stops the running commands at the first failure and skips
+	// those not started yet
+	FailFast bool
+
+	// Output returns the writer the command in the i-th target writes its
+	// stdout and stderr to. It is called from several goroutines at once. A
+	// writer that is an io.Closer is closed once the command exits. Nil
+	// discards the output.
+	Output func(i int) io.Writer
+}
+
+// ExecResult is the outcome of running the command in a target
+type ExecResult struct {
+	Status ExecStatus
+
+	// ExitCode is the command's exit status, or -1 when it did not exit by
+	// itself
+	ExitCode int
+
+	Duration time.Duration
+
+	// Err is set when the command could not be started
+	Err error
+}
+
+// ExecTargets lists the worktrees of the given workspaces whose directory
+// name or branch matches one of the glob patterns, in the order of the
+// workspaces and then as git lists them. No patterns match every worktree.
+func (m *Manager) ExecTargets(ctx context.Context, workspaces []*Workspace, patterns []string) ([]ExecTarget, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, newError(Usage, "invalid worktree pattern %q", pattern)
+		}
+	}
+
+	var targets []ExecTarget
+	for _, ws := range workspaces {
+		worktrees, err := m.ListWorktrees(ctx, ws)
+		if err != nil {
+			return nil, err
+		}
+		for _, wt := range worktrees {
+			if wt.IsBare || wt.Prunable || !matchesAny(patterns, filepath.Base(wt.Path), wt.Branch) {
+				continue
+			}
+			targets = append(targets, ExecTarget{Workspace: ws, Worktree: wt})
+		}
+	}
+	return targets, nil
+}
+
+// matchesAny reports whether any of names matches one of patterns. No
+// patterns match everything.
+func matchesAny(patterns []string, names ...string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); ok && name != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Exec runs argv in every target, up to opts.Jobs at once, and returns a
+// result per target in the order given. Each command runs in a process
+// group of its own, so stopping it also stops the processes it started.
+func (m *Manager) Exec(ctx context.Context, targets []ExecTarget, argv []string, opts ExecOptions) []ExecResult {
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = DefaultExecJobs
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]ExecResult, len(targets))
+	parallel(len(targets), jobs, func(i int) {
+		if runCtx.Err() != nil {
+			results[i] = ExecResult{Status: ExecSkipped, ExitCode: -1}
+			return
+		}
+		var out io.Writer = io.Discard
+		if opts.Output != nil {
+			out = opts.Output(i)
+		}
+		results[i] = m.execIn(runCtx, targets[i].Worktree.Path, argv, out)
+		if results[i].Status == ExecFailed && opts.FailFast {
+			cancel()
+		}
+	})
+	return results
+}
+
+// execIn runs argv in dir with its output going to out
+func (m *Manager) execIn(ctx context.Context, dir string, argv []string, out io.Writer) ExecResult {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = dir
+	cmd.Env = m.env
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.WaitDelay = 5 * time.Second
+	git.SetProcessGroup(cmd)
+
+	start := time.Now()
+	err := cmd.Run()
+	res := ExecResult{Status: ExecOK, Duration: time.Since(start)}
+	if c, ok := out.(io.Closer); ok {
+		c.Close()
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		res.Status, res.ExitCode = ExecCancelled, -1
+	case errors.As(err, &exitErr):
+		res.Status, res.ExitCode = ExecFailed, exitErr.ExitCode()
+	default:
+		res.Status, res.ExitCode, res.Err = ExecFailed, -1, err
+	}
+	return res
+}
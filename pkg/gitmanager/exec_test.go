@@ -0,0 +1,57 @@
+package gitmanager_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// TestExecFailFast tests that the first failure stops the other commands
+// along with the processes they started, and skips the rest
+func TestExecFailFast(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are not supported on windows")
+	}
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	testWS.AddWorktree(t, "fail")
+	testWS.AddWorktree(t, "slow")
+	ws := &gitmanager.Workspace{Root: testWS.Root, GitDir: testWS.GitDir}
+
+	targets, err := m.ExecTargets(ctx, []*gitmanager.Workspace{ws}, []string{"fail", "slow", "ma*"})
+	if err != nil {
+		t.Fatalf("ExecTargets failed: %v", err)
+	}
+	if len(targets) != 3 {
+		t.Fatalf("Expected 3 targets, got %+v", targets)
+	}
+
+	// The shell's child keeps the output open unless it is stopped too
+	script := `case "$PWD" in */fail) sleep 0.2; exit 3;; esac; sleep 30 & wait`
+	start := time.Now()
+	results := m.Exec(ctx, targets, []string{"sh", "-c", script}, gitmanager.ExecOptions{Jobs: 2, FailFast: true})
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Expected the slow command to be stopped quickly, took %s", elapsed)
+	}
+
+	// Worktrees are listed sorted by path: fail, main, slow
+	var got []string
+	for _, res := range results {
+		got = append(got, fmt.Sprintf("%s:%d", res.Status, res.ExitCode))
+	}
+	if want := "[failed:3 cancelled:-1 skipped:-1]"; fmt.Sprint(got) != want {
+		t.Errorf("Exec returned %v, want %s", got, want)
+	}
+
+	if _, err := m.ExecTargets(ctx, []*gitmanager.Workspace{ws}, []string{"["}); !gitmanager.IsKind(err, gitmanager.Usage) {
+		t.Errorf("Expected a usage error for an invalid pattern, got %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package gitmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+)
+
+// DefaultFetchJobs is how many repositories Fetch fetches at once unless
+// told otherwise
+const DefaultFetchJobs = 4
+
+// RefChange says how a fetch changed a ref
+type RefChange string
+
+// The ways a fetch changes refs
+const (
+	RefNew      RefChange = "new"
+	RefUpdated  RefChange = "updated"
+	RefForced   RefChange = "forced"
+	RefDeleted  RefChange = "deleted"
+	RefRejected RefChange = "rejected"
+)
+
+// RefUpdate is a ref changed by a fetch
+type RefUpdate struct {
+	// Remote is the remote fetched from
+	Remote string
+
+	// Ref is the local ref, such as origin/main or v1.0
+	Ref string
+
+	Change RefChange
+}
+
+// FetchOptions configures Fetch
+type FetchOptions struct {
+	// Prune deletes remote-tracking branches whose branch is gone from the
+	// remote
+	Prune bool
+
+	// Jobs is how many repositories are fetched at once. Zero uses
+	// DefaultFetchJobs.
+	Jobs int
+
+	// OnProgress, when set, receives progress while fetching. It is called
+	// from several goroutines at once.
+	OnProgress func(FetchProgress)
+}
+
+// FetchProgress reports how fetching a remote of a workspace is going
+type FetchProgress struct {
+	Workspace *Workspace
+	Remote    string
+
+	// Phase and Percent are git's latest progress report, empty and zero
+	// until git sends one
+	Phase   string
+	Percent int
+
+	// Done is set once the workspace is fetched, with Err set if that failed.
+	// Remote is then empty.
+	Done bool
+	Err  error
+}
+
+// FetchResult is the outcome of fetching a workspace
+type FetchResult struct {
+	Workspace *Workspace
+
+	// Remotes are the remotes fetched from
+	Remotes []string
+
+	// Refs are the refs that changed
+	Refs []RefUpdate
+
+	// Err is set when fetching any remote failed. It joins the errors of
+	// all the remotes that failed.
+	Err error
+}
+
+// Count returns how many refs changed in the way c
+func (r FetchResult) Count(c RefChange) int {
+	n := 0
+	for _, ref := range r.Refs {
+		if ref.Change == c {
+			n++
+		}
+	}
+	return n
+}
+
+// Fetch fetches every remote of each workspace, up to opts.Jobs workspaces
+// at once. Remotes without a fetch refspec, as bare clones have, still get
+// their branches fetched into remote-tracking branches. It returns a result
+// per workspace in the order given; failures are reported in the results.
+func (m *Manager) Fetch(ctx context.Context, workspaces []*Workspace, opts FetchOptions) []FetchResult {
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = DefaultFetchJobs
+	}
+
+	results := make([]FetchResult, len(workspaces))
+	parallel(len(workspaces), jobs, func(i int) {
+		results[i] = m.fetchWorkspace(ctx, workspaces[i], opts)
+	})
+	return results
+}
+
+// fetchWorkspace fetches every remote of ws, one after the other
+func (m *Manager) fetchWorkspace(ctx context.Context, ws *Workspace, opts FetchOptions) FetchResult {
+	res := FetchResult{Workspace: ws}
+	report := func(p FetchProgress) {
+		if opts.OnProgress != nil {
+			p.Workspace = ws
+			opts.OnProgress(p)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		res.Err = newError(Interrupted, "fetch was interrupted")
+		report(FetchProgress{Done: true, Err: res.Err})
+		return res
+	}
+
+	out, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "remote")
+	if err != nil {
+		res.Err = wrapGit(err, "error listing remotes")
+		report(FetchProgress{Done: true, Err: res.Err})
+		return res
+	}
+
+	// A failing remote does not keep the others from being fetched
+	var failures []error
+	for _, remote := range strings.Fields(out) {
+		if ctx.Err() != nil {
+			break
+		}
+		res.Remotes = append(res.Remotes, remote)
+		report(FetchProgress{Remote: remote})
+		refs, err := m.fetchRemote(ctx, ws, remote, opts.Prune, func(p git.Progress) {
+			report(FetchProgress{Remote: remote, Phase: p.Phase, Percent: p.Percent})
+		})
+		res.Refs = append(res.Refs, refs...)
+		if err != nil {
+			failures = append(failures, err)
+		}
+	}
+	res.Err = errors.Join(failures...)
+
+	report(FetchProgress{Done: true, Err: res.Err})
+	return res
+}
+
+// fetchRemote fetches remote into ws and returns the refs that changed
+func (m *Manager) fetchRemote(ctx context.Context, ws *Workspace, remote string, prune bool, progress func(git.Progress)) ([]RefUpdate, error) {
+	args := []string{"-C", ws.GitDir, "fetch", "--progress"}
+	if prune {
+		args = append(args, "--prune")
+	}
+	args = append(args, remote)
+
+	// Without a refspec git would only update FETCH_HEAD
+	if _, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "config", "--get-all", "remote."+remote+".fetch"); err != nil {
+		if ctx.Err() != nil {
+			return nil, wrapGit(err, "error reading remote configuration")
+		}
+		args = append(args, "+refs/heads/*:refs/remotes/"+remote+"/*")
+	}
+
+	var refs []RefUpdate
+	stderr := &git.ProgressWriter{
+		OnProgress: progress,
+		OnLine: func(line string) {
+			if ref, ok := parseRefUpdate(line); ok {
+				ref.Remote = remote
+				refs = append(refs, ref)
+			}
+		},
+	}
+	_, err := m.git.Run(ctx, git.Invocation{
+		Dir:  ws.Root,
+		Args: args,
+		// The ref updates are parsed, so they must not be translated
+		Env:    []string{"LC_ALL=C"},
+		Stderr: stderr,
+	})
+	stderr.Flush()
+	if err != nil {
+		return refs, wrapGit(err, fmt.Sprintf("error fetching remote '%s'", remote))
+	}
+	return refs, nil
+}
+
+// refChanges maps the flag git prints in front of a ref update to the change
+var refChanges = map[byte]RefChange{
+	' ': RefUpdated,
+	't': RefUpdated,
+	'+': RefForced,
+	'*': RefNew,
+	'-': RefDeleted,
+	'!': RefRejected,
+}
+
+// parseRefUpdate parses a ref update line of `git fetch`, such as
+// " * [new branch]      topic      -> origin/topic"
+func parseRefUpdate(line string) (RefUpdate, bool) {
+	if len(line) < 4 || line[0] != ' ' || line[2] != ' ' {
+		return RefUpdate{}, false
+	}
+	change, ok := refChanges[line[1]]
+	if !ok {
+		return RefUpdate{}, false
+	}
+	_, to, ok := strings.Cut(line[3:], " -> ")
+	if !ok {
+		return RefUpdate{}, false
+	}
+	fields := strings.Fields(to)
+	if len(fields) == 0 {
+		return RefUpdate{}, false
+	}
+	return RefUpdate{Ref: fields[0], Change: change}, true
+}
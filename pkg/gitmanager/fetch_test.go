@@ -0,0 +1,114 @@
+package gitmanager_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// TestFetch tests fetching several workspaces at once, including the
+// remote-tracking branches of bare clones without a refspec and pruning
+func TestFetch(t *testing.T) {
+	first, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	second, cleanup2 := testutil.SetupWorkspace(t)
+	defer cleanup2()
+	m := newManager(t)
+	ctx := context.Background()
+
+	workspaces := []*gitmanager.Workspace{
+		{Name: "first", Root: first.Root, GitDir: first.GitDir},
+		{Name: "second", Root: second.Root, GitDir: second.GitDir},
+	}
+
+	var mu sync.Mutex
+	done := map[string]bool{}
+	results := m.Fetch(ctx, workspaces, gitmanager.FetchOptions{
+		Jobs: 2,
+		OnProgress: func(p gitmanager.FetchProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			if p.Done {
+				done[p.Workspace.Name] = true
+			}
+		},
+	})
+	for i, res := range results {
+		if res.Err != nil {
+			t.Fatalf("Fetching %s failed: %v", res.Workspace.Name, res.Err)
+		}
+		if res.Workspace != workspaces[i] || !done[res.Workspace.Name] {
+			t.Errorf("Expected results in order and a done report for %s", workspaces[i].Name)
+		}
+		if res.Count(gitmanager.RefNew) == 0 {
+			t.Errorf("Expected the first fetch of %s to create remote-tracking branches, got %+v", res.Workspace.Name, res.Refs)
+		}
+	}
+	(&testutil.GitRepo{Path: first.GitDir}).RunGit(t, "rev-parse", "--verify", "refs/remotes/origin/main")
+
+	// A new commit, a new branch, then a deleted branch
+	first.Origin.CreateFile(t, "new.txt", "new")
+	first.Origin.AddAndCommit(t, "New file", "new.txt")
+	first.Origin.RunGit(t, "branch", "topic")
+
+	res := m.Fetch(ctx, workspaces[:1], gitmanager.FetchOptions{})[0]
+	if res.Err != nil {
+		t.Fatalf("Fetch failed: %v", res.Err)
+	}
+	got := refChanges(res.Refs)
+	if got["origin/main"] != gitmanager.RefUpdated || got["origin/topic"] != gitmanager.RefNew {
+		t.Errorf("Expected origin/main updated and origin/topic new, got %+v", res.Refs)
+	}
+
+	first.Origin.RunGit(t, "branch", "-D", "topic")
+	res = m.Fetch(ctx, workspaces[:1], gitmanager.FetchOptions{Prune: true})[0]
+	if res.Err != nil {
+		t.Fatalf("Fetch --prune failed: %v", res.Err)
+	}
+	if got := refChanges(res.Refs); len(got) != 1 || got["origin/topic"] != gitmanager.RefDeleted {
+		t.Errorf("Expected only origin/topic to be pruned, got %+v", res.Refs)
+	}
+}
+
+// TestFetchFailure tests that a failing workspace is reported without
+// stopping the others
+func TestFetchFailure(t *testing.T) {
+	good, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	bad, cleanup2 := testutil.SetupWorkspace(t)
+	defer cleanup2()
+	m := newManager(t)
+
+	badRepo := &testutil.GitRepo{Path: bad.GitDir}
+	badRepo.RunGit(t, "remote", "set-url", "origin", bad.TempDir+"/gone")
+	badRepo.RunGit(t, "remote", "add", "upstream", good.Origin.Path)
+
+	results := m.Fetch(context.Background(), []*gitmanager.Workspace{
+		{Root: bad.Root, GitDir: bad.GitDir},
+		{Root: good.Root, GitDir: good.GitDir},
+	}, gitmanager.FetchOptions{Jobs: 1})
+
+	if !gitmanager.IsKind(results[0].Err, gitmanager.GitFailure) || !strings.Contains(results[0].Err.Error(), "'origin'") {
+		t.Errorf("Expected a git failure for the missing remote, got %v", results[0].Err)
+	}
+	// The remote after the failing one is still fetched
+	if refChanges(results[0].Refs)["upstream/main"] != gitmanager.RefNew {
+		t.Errorf("Expected upstream to be fetched after origin failed, got %+v", results[0].Refs)
+	}
+	if results[1].Err != nil {
+		t.Errorf("Expected the other workspace to be fetched, got %v", results[1].Err)
+	}
+}
+
+// refChanges maps each updated ref to its change
+func refChanges(refs []gitmanager.RefUpdate) map[string]gitmanager.RefChange {
+	changes := map[string]gitmanager.RefChange{}
+	for _, ref := range refs {
+		changes[ref.Ref] = ref.Change
+	}
+	return changes
+}
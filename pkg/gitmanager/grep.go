@@ -0,0 +1,261 @@
+package gitmanager
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+)
+
+// DefaultGrepJobs is how many searches Grep runs at once unless told
+// otherwise
+const DefaultGrepJobs = 4
+
+// GrepOptions configures Grep
+type GrepOptions struct {
+	// Pattern is the regular expression to search for
+	Pattern string
+
+	// IgnoreCase matches without regard to case
+	IgnoreCase bool
+
+	// FixedStrings takes Pattern literally instead of as a regular expression
+	FixedStrings bool
+
+	// Committed searches the committed tree of every local branch instead
+	// of the checkout of every worktree, which includes untracked files
+	Committed bool
+
+	// Paths limits the search to these pathspecs
+	Paths []string
+
+	// Jobs is how many searches run at once. Zero uses DefaultGrepJobs.
+	Jobs int
+}
+
+// GrepResult holds the matches in one worktree, or one branch with
+// GrepOptions.Committed
+type GrepResult struct {
+	Workspace *Workspace
+
+	// Source is the worktree directory name, or the branch
+	Source string
+
+	// Files are the files with matches, in the order git lists them
+	Files []GrepFile
+
+	// Err is set when the search failed
+	Err error
+}
+
+// GrepFile is a file with matches
+type GrepFile struct {
+	Path    string
+	Matches []GrepLine
+
+	// Also lists the other sources in which this file has the same content.
+	// Their matches in it are left out.
+	Also []string
+}
+
+// GrepLine is a matching line
+type GrepLine struct {
+	Line int
+	Text string
+}
+
+// grepSource is a worktree or branch to search
+type grepSource struct {
+	ws   *Workspace
+	name string
+	dir  string
+
+	// rev is the full ref of the branch, so a tag of the same name does not
+	// shadow it
+	rev string
+}
+
+// Grep searches every worktree of the given workspaces with `git grep`, or
+// with Committed every local branch, several at once. Files with the same
+// path and content in several sources of a workspace are reported once, in
+// the first source, with the others in GrepFile.Also. Failed searches are
+// reported in the results.
+func (m *Manager) Grep(ctx context.Context, workspaces []*Workspace, opts GrepOptions) ([]GrepResult, error) {
+	if opts.Pattern == "" {
+		return nil, newError(Usage, "the pattern must not be empty")
+	}
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = DefaultGrepJobs
+	}
+
+	var sources []grepSource
+	for _, ws := range workspaces {
+		s, err := m.grepSources(ctx, ws, opts.Committed)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, s...)
+	}
+
+	results := make([]GrepResult, len(sources))
+	keys := make([]map[string]string, len(sources))
+	parallel(len(sources), jobs, func(i int) {
+		results[i], keys[i] = m.grepSource(ctx, sources[i], opts)
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, newError(Interrupted, "grep was interrupted")
+	}
+
+	// Report files with the same content once per workspace
+	first := map[string]*GrepFile{}
+	for i := range results {
+		files := results[i].Files[:0]
+		for _, f := range results[i].Files {
+			key := keys[i][f.Path]
+			if key == "" {
+				files = append(files, f)
+				continue
+			}
+			key = sources[i].ws.GitDir + "\x00" + f.Path + "\x00" + key
+			if earlier, ok := first[key]; ok {
+				earlier.Also = append(earlier.Also, sources[i].name)
+				continue
+			}
+			files = append(files, f)
+			first[key] = &files[len(files)-1]
+		}
+		results[i].Files = files
+	}
+	return results, nil
+}
+
+// grepSources lists the worktrees of ws, or its local branches
+func (m *Manager) grepSources(ctx context.Context, ws *Workspace, committed bool) ([]grepSource, error) {
+	var sources []grepSource
+	if committed {
+		out, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "for-each-ref", "--format=%(refname)", "refs/heads")
+		if err != nil {
+			return nil, wrapGit(err, "error listing branches")
+		}
+		for _, ref := range strings.Fields(out) {
+			sources = append(sources, grepSource{ws: ws, name: strings.TrimPrefix(ref, "refs/heads/"), dir: ws.GitDir, rev: ref})
+		}
+		return sources, nil
+	}
+
+	worktrees, err := m.ListWorktrees(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+	for _, wt := range worktrees {
+		if wt.IsBare || wt.Prunable || !dirExists(wt.Path) {
+			continue
+		}
+		sources = append(sources, grepSource{ws: ws, name: filepath.Base(wt.Path), dir: wt.Path})
+	}
+	return sources, nil
+}
+
+// grepSource searches s. Along with the matches it returns a key per file
+// that is the same for the same content.
+func (m *Manager) grepSource(ctx context.Context, s grepSource, opts GrepOptions) (GrepResult, map[string]string) {
+	res := GrepResult{Workspace: s.ws, Source: s.name}
+
+	args := []string{"-C", s.dir, "grep", "-n", "-I", "--null", "--no-color"}
+	if opts.IgnoreCase {
+		args = append(args, "-i")
+	}
+	if opts.FixedStrings {
+		args = append(args, "-F")
+	}
+	args = append(args, "-e", opts.Pattern)
+	if s.rev != "" {
+		args = append(args, s.rev)
+	} else {
+		// The checkout includes new files that are not ignored
+		args = append(args, "--untracked")
+	}
+	args = append(append(args, "--"), opts.Paths...)
+
+	out, err := m.git.Run(ctx, git.Invocation{Dir: s.dir, Args: args})
+	if err != nil {
+		// git grep exits with 1 when nothing matches
+		if out.ExitCode != 1 || strings.TrimSpace(out.Stderr) != "" {
+			res.Err = wrapGit(err, fmt.Sprintf("error searching %s", s.name))
+		}
+		return res, nil
+	}
+	res.Files = parseGrep(out.Stdout, s.rev)
+
+	keys, err := m.contentKeys(ctx, s, res.Files)
+	if err != nil {
+		res.Err = err
+	}
+	return res, keys
+}
+
+// parseGrep parses `git grep -n --null` output. Searching a revision
+// prefixes paths with "<rev>:".
+func parseGrep(out string, rev string) []GrepFile {
+	var files []GrepFile
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\x00", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		path := fields[0]
+		if rev != "" {
+			path = strings.TrimPrefix(path, rev+":")
+		}
+		n, _ := strconv.Atoi(fields[1])
+		if len(files) == 0 || files[len(files)-1].Path != path {
+			files = append(files, GrepFile{Path: path})
+		}
+		f := &files[len(files)-1]
+		f.Matches = append(f.Matches, GrepLine{Line: n, Text: fields[2]})
+	}
+	return files
+}
+
+// contentKeys returns a key per file that is the same for the same content:
+// the blob of committed files, a hash of the checked out ones
+func (m *Manager) contentKeys(ctx context.Context, s grepSource, files []GrepFile) (map[string]string, error) {
+	keys := map[string]string{}
+	if len(files) == 0 {
+		return keys, nil
+	}
+
+	if s.rev == "" {
+		for _, f := range files {
+			data, err := os.ReadFile(filepath.Join(s.dir, f.Path))
+			if err != nil {
+				continue
+			}
+			keys[f.Path] = fmt.Sprintf("%x", sha256.Sum256(data))
+		}
+		return keys, nil
+	}
+
+	args := []string{"-C", s.dir, "ls-tree", "-z", s.rev, "--"}
+	for _, f := range files {
+		args = append(args, f.Path)
+	}
+	out, err := git.Output(ctx, m.git, s.dir, args...)
+	if err != nil {
+		return nil, wrapGit(err, fmt.Sprintf("error listing files of %s", s.name))
+	}
+	for _, entry := range strings.Split(out, "\x00") {
+		// <mode> <type> <object>\t<path>
+		meta, path, ok := strings.Cut(entry, "\t")
+		if fields := strings.Fields(meta); ok && len(fields) == 3 {
+			keys[path] = fields[2]
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,75 @@
+package gitmanager_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// grepSummary describes grep results as "<source> <path>:<line> also=<sources>"
+// lines, sorted
+func grepSummary(results []gitmanager.GrepResult) []string {
+	var lines []string
+	for _, res := range results {
+		for _, f := range res.Files {
+			for _, m := range f.Matches {
+				lines = append(lines, fmt.Sprintf("%s %s:%d also=%v", res.Source, f.Path, m.Line, f.Also))
+			}
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// TestGrep tests searching worktrees and branches, with identical files
+// reported once
+func TestGrep(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	testWS.AddWorktree(t, "same")
+	edit := testWS.AddWorktree(t, "edit")
+	edit.CreateFile(t, "README.md", "# Edited\n\nTest Repository\n")
+	edit.AddAndCommit(t, "Edit README", "README.md")
+	testWS.Worktree("main").CreateFile(t, "notes.txt", "test notes\n")
+
+	ws := &gitmanager.Workspace{Root: testWS.Root, GitDir: testWS.GitDir}
+
+	results, err := m.Grep(ctx, []*gitmanager.Workspace{ws}, gitmanager.GrepOptions{Pattern: "test", IgnoreCase: true})
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+	got := strings.Join(grepSummary(results), "\n")
+	// The worktrees are searched in the order git lists them
+	want := strings.Join([]string{
+		"edit README.md:3 also=[]",
+		"main README.md:1 also=[same]",
+		"main notes.txt:1 also=[]",
+	}, "\n")
+	if got != want {
+		t.Errorf("Grep found\n%s\nwant\n%s", got, want)
+	}
+
+	// Committed trees leave out the untracked notes. A tag named like a
+	// branch does not hide the branch.
+	(&testutil.GitRepo{Path: testWS.GitDir}).RunGit(t, "tag", "edit", "main")
+	results, err = m.Grep(ctx, []*gitmanager.Workspace{ws}, gitmanager.GrepOptions{Pattern: "Test", Committed: true})
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+	got = strings.Join(grepSummary(results), "\n")
+	want = strings.Join([]string{
+		"edit README.md:3 also=[]",
+		"main README.md:1 also=[same]",
+	}, "\n")
+	if got != want {
+		t.Errorf("Grep --committed found\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,193 @@
+package gitmanager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/importer"
+	"github.com/ingshtrom/git-manager/internal/worktree"
+)
+
+// The sources ImportRepositories reads
+const (
+	ImportGhq          = importer.SourceGhq
+	ImportMr           = importer.SourceMr
+	ImportRepoManifest = importer.SourceRepoManifest
+)
+
+// How ImportRepositories brought in a repository
+const (
+	// ImportRegistered means the checkout already was a workspace
+	ImportRegistered = "registered"
+	// ImportAdopted means an existing clone was adopted
+	ImportAdopted = "adopted"
+	// ImportCloned means a missing checkout was cloned as a new workspace
+	ImportCloned = "cloned"
+)
+
+// ImportOptions configures ImportRepositories
+type ImportOptions struct {
+	// From is the source: ImportGhq, ImportMr or ImportRepoManifest
+	From string
+
+	// Path is the ghq root, the .mrconfig file or the manifest file
+	Path string
+
+	// DryRun resolves and validates everything but changes nothing
+	DryRun bool
+}
+
+// ImportResult is what happened to one repository of the source
+type ImportResult struct {
+	// Name is the registry name: the checkout's directory name, or when
+	// another repository has that name, its path from as many parent
+	// directories as it takes to tell them apart, such as "owner/api"
+	Name string
+
+	// Path is the checkout's directory
+	Path string
+
+	// URL is where the source says the repository comes from, if it says
+	URL string
+
+	// Action is ImportRegistered, ImportAdopted or ImportCloned, or empty
+	// when Err is set
+	Action string
+
+	// Err is why the repository could not be imported
+	Err error
+
+	// Plan lists the planned steps, in the form printed for a dry run
+	Plan []string
+}
+
+// ImportRepositories brings the repositories listed by another checkout
+// manager into git-manager. Existing clones are adopted as with
+// AdoptRepository, missing ones are cloned as with InitRepository, and
+// existing workspaces are registered. A repository that cannot be imported
+// does not stop the others; its error is in its result.
+func (m *Manager) ImportRepositories(ctx context.Context, opts ImportOptions) ([]ImportResult, error) {
+	entries, err := importer.Read(opts.From, opts.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	registered, err := m.Repositories()
+	if err != nil {
+		return nil, err
+	}
+	names, clashes := importNames(entries, registered)
+
+	results := make([]ImportResult, 0, len(entries))
+	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return results, newError(Interrupted, "import was interrupted")
+		}
+		res := ImportResult{Name: names[i], Path: entry.Path, URL: entry.URL}
+		if clashes[i] {
+			// Checked up front, so nothing is cloned or adopted in vain
+			res.Err = newError(Conflict, "a repository named %q is already registered or imported from another path", res.Name)
+		} else {
+			res.Action, res.Plan, res.Err = m.importEntry(ctx, res.Name, entry, opts.DryRun)
+		}
+		if res.Err != nil {
+			res.Action = ""
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// importNames picks a registry name for every entry: the name it is already
+// registered under, or the checkout's directory name prefixed with as many
+// parent directories as it takes to tell it apart from the other entries and
+// from registered repositories at other paths. Names that still clash are
+// flagged.
+func importNames(entries []importer.Entry, registered []Repository) ([]string, []bool) {
+	taken := map[string]string{}
+	known := map[string]string{}
+	for _, repo := range registered {
+		taken[repo.Name] = repo.Path
+		known[repo.Path] = repo.Name
+	}
+
+	parts := make([][]string, len(entries))
+	depth := make([]int, len(entries))
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		parts[i] = strings.Split(filepath.ToSlash(strings.TrimPrefix(entry.Path, filepath.VolumeName(entry.Path))), "/")
+		depth[i] = 1
+	}
+	name := func(i int) string {
+		if n, ok := known[entries[i].Path]; ok {
+			return n
+		}
+		return strings.Join(parts[i][len(parts[i])-depth[i]:], "/")
+	}
+
+	clashes := make([]bool, len(entries))
+	for {
+		byName := map[string][]int{}
+		for i := range entries {
+			names[i] = name(i)
+			byName[names[i]] = append(byName[names[i]], i)
+		}
+
+		grown := false
+		for n, group := range byName {
+			path, isTaken := taken[n]
+			if len(group) == 1 && (!isTaken || path == entries[group[0]].Path) {
+				clashes[group[0]] = false
+				continue
+			}
+			for _, i := range group {
+				clashes[i] = true
+				// The leading "" of an absolute path is no name
+				if _, ok := known[entries[i].Path]; !ok && depth[i] < len(parts[i])-1 {
+					depth[i]++
+					grown = true
+				}
+			}
+		}
+		if !grown {
+			return names, clashes
+		}
+	}
+}
+
+// importEntry imports one repository under name
+func (m *Manager) importEntry(ctx context.Context, name string, entry importer.Entry, dryRun bool) (string, []string, error) {
+	if _, err := os.Stat(entry.Path); err != nil {
+		if entry.URL == "" {
+			return "", nil, newError(NotFound, "%s does not exist and the source has no URL to clone it from", entry.Path)
+		}
+		res, err := m.InitRepository(ctx, InitOptions{
+			URL:    entry.URL,
+			Name:   name,
+			Path:   entry.Path,
+			DryRun: dryRun,
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		return ImportCloned, res.Plan, nil
+	}
+
+	if worktree.IsBareGitDir(filepath.Join(entry.Path, ".git")) {
+		if dryRun {
+			return ImportRegistered, []string{registerStepDescription(name, entry.Path)}, nil
+		}
+		if _, err := m.Register(entry.Path, name); err != nil {
+			return "", nil, err
+		}
+		return ImportRegistered, nil, nil
+	}
+
+	res, err := m.AdoptRepository(ctx, AdoptOptions{Dir: entry.Path, Name: name, DryRun: dryRun})
+	if err != nil {
+		return "", nil, err
+	}
+	return ImportAdopted, res.Plan, nil
+}
@@ -0,0 +1,115 @@
+package gitmanager_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// TestImportRepositories tests that importing from an .mrconfig adopts
+// clones, clones what is missing and registers existing workspaces
+func TestImportRepositories(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	if out, err := testutil.NewCommand("git", "clone", testWS.Origin.Path, filepath.Join(testWS.TempDir, "adopted")).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to clone: %v\nOutput: %s", err, out)
+	}
+	mrconfig := filepath.Join(testWS.TempDir, ".mrconfig")
+	content := fmt.Sprintf(`[adopted]
+checkout = git clone '%[1]s' adopted
+
+[cloned]
+checkout = git clone '%[1]s' cloned
+
+[workspace]
+checkout = git clone '%[1]s' workspace
+
+[missing]
+update = git pull
+`, testWS.Origin.Path)
+	if err := os.WriteFile(mrconfig, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := m.ImportRepositories(ctx, gitmanager.ImportOptions{From: gitmanager.ImportMr, Path: mrconfig})
+	if err != nil {
+		t.Fatalf("ImportRepositories failed: %v", err)
+	}
+
+	want := map[string]string{
+		"adopted":   gitmanager.ImportAdopted,
+		"cloned":    gitmanager.ImportCloned,
+		"workspace": gitmanager.ImportRegistered,
+		"missing":   "",
+	}
+	if len(results) != len(want) {
+		t.Fatalf("Expected %d results, got %+v", len(want), results)
+	}
+	for _, res := range results {
+		if res.Action != want[res.Name] {
+			t.Errorf("Expected %s to be %q, got %q (%v)", res.Name, want[res.Name], res.Action, res.Err)
+		}
+		if res.Name == "missing" && gitmanager.KindOf(res.Err) != gitmanager.NotFound {
+			t.Errorf("Expected a not-found error for missing, got %v", res.Err)
+		}
+	}
+
+	repos, err := m.Repositories()
+	if err != nil {
+		t.Fatalf("Repositories failed: %v", err)
+	}
+	if len(repos) != 3 {
+		t.Errorf("Expected 3 registered repositories, got %+v", repos)
+	}
+	(&testutil.GitRepo{Path: filepath.Join(testWS.TempDir, "cloned", "main")}).RunGit(t, "status")
+	(&testutil.GitRepo{Path: filepath.Join(testWS.TempDir, "adopted", "main")}).RunGit(t, "status")
+}
+
+// TestImportRepositoriesSameName tests that ghq checkouts sharing a
+// directory name are registered under their owner
+func TestImportRepositoriesSameName(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	root := filepath.Join(testWS.TempDir, "ghq")
+	for _, owner := range []string{"a", "b"} {
+		dir := filepath.Join(root, "github.com", owner, "api")
+		if out, err := testutil.NewCommand("git", "clone", testWS.Origin.Path, dir).CombinedOutput(); err != nil {
+			t.Fatalf("Failed to clone: %v\nOutput: %s", err, out)
+		}
+	}
+
+	results, err := m.ImportRepositories(ctx, gitmanager.ImportOptions{From: gitmanager.ImportGhq, Path: root})
+	if err != nil {
+		t.Fatalf("ImportRepositories failed: %v", err)
+	}
+	var got []string
+	for _, res := range results {
+		got = append(got, fmt.Sprintf("%s:%s:%v", res.Name, res.Action, res.Err))
+	}
+	if want := "[a/api:adopted:<nil> b/api:adopted:<nil>]"; fmt.Sprint(got) != want {
+		t.Errorf("ImportRepositories returned %v, want %s", got, want)
+	}
+
+	// Importing again keeps the names
+	results, err = m.ImportRepositories(ctx, gitmanager.ImportOptions{From: gitmanager.ImportGhq, Path: root})
+	if err != nil {
+		t.Fatalf("ImportRepositories failed: %v", err)
+	}
+	for _, res := range results {
+		if res.Err != nil || res.Action != gitmanager.ImportRegistered || !strings.HasSuffix(res.Name, "/api") {
+			t.Errorf("Expected %s to be registered again, got %q (%v)", res.Name, res.Action, res.Err)
+		}
+	}
+}
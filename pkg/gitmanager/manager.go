@@ -0,0 +1,282 @@
+// Package gitmanager is the embeddable Go API of git-manager. It manages
+// workspaces: a bare repository in <root>/.git with one worktree per branch
+// checked out next to it.
+//
+// A Manager never prints. Methods that run git take a context, and all of
+// them return typed results and errors whose Kind says what went wrong (see
+// ErrorKind). The git-manager command line tool is a thin layer on top of
+// this package.
+package gitmanager
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/config"
+	"github.com/ingshtrom/git-manager/internal/git"
+	"github.com/ingshtrom/git-manager/internal/plan"
+	"github.com/ingshtrom/git-manager/internal/worktree"
+)
+
+// GitRunner runs git. Provide one in Options to trace, fake or sandbox the
+// git invocations a Manager makes.
+type GitRunner = git.GitRunner
+
+// Invocation describes a single git call made through a GitRunner
+type Invocation = git.Invocation
+
+// Result is the outcome of an Invocation
+type Result = git.Result
+
+// Options configures a Manager. The zero value runs the git binary with the
+// current process environment and the default configuration file.
+type Options struct {
+	// Git runs git. Nil runs the git binary with Env.
+	Git GitRunner
+
+	// Env is the environment in os.Environ form, used to locate the
+	// configuration file and passed to git. Nil uses the process environment.
+	Env []string
+
+	// ConfigPath is the configuration file holding the repository registry.
+	// Empty picks the default location, see the README.
+	ConfigPath string
+
+	// Progress receives progress messages and git's stdout from operations
+	// that change things. Nil discards them.
+	Progress io.Writer
+
+	// ProgressErr receives git's stderr from those operations, such as clone
+	// progress. Nil discards it. Errors include git's stderr either way.
+	ProgressErr io.Writer
+
+	// Timeout limits how long each git command may run, overriding the
+	// timeouts in the configuration file. Zero uses the configuration.
+	Timeout time.Duration
+
+	// Backend answers read-only queries such as listing worktrees: BackendCLI
+	// runs git, BackendNative reads the repository files directly and falls
+	// back to git for repositories it cannot read. Empty uses the "backend"
+	// setting of the configuration file.
+	Backend string
+}
+
+// The read-only query backends, see Options.Backend
+const (
+	BackendCLI    = worktree.BackendCLI
+	BackendNative = worktree.BackendNative
+)
+
+// Manager manages git-manager workspaces
+type Manager struct {
+	git         GitRunner
+	env         []string
+	configPath  string
+	progress    io.Writer
+	progressErr io.Writer
+	configErr   error
+	backendName string
+	queries     worktree.Backend
+	features    *git.FeatureDetector
+
+	timeout      time.Duration
+	timeoutsOnce sync.Once
+	timeouts     *config.Config
+}
+
+// New returns a Manager configured by opts
+func New(opts Options) *Manager {
+	m := &Manager{
+		git:         opts.Git,
+		env:         opts.Env,
+		configPath:  opts.ConfigPath,
+		progress:    opts.Progress,
+		progressErr: opts.ProgressErr,
+		backendName: opts.Backend,
+		timeout:     opts.Timeout,
+	}
+	if m.configPath == "" {
+		m.configPath, m.configErr = config.Path(m.getenv)
+	}
+	if m.git == nil {
+		m.git = &git.ExecRunner{Env: opts.Env}
+	}
+	m.git = git.WithTimeoutFunc(m.git, m.timeoutFor)
+	m.features = &git.FeatureDetector{Git: m.git}
+	if m.progress == nil {
+		m.progress = io.Discard
+	}
+	return m
+}
+
+// Workspace is a resolved git-manager workspace, or any other git repository
+// git-manager was pointed at
+type Workspace struct {
+	// Name is the registered name, or "" if the workspace is not registered
+	Name string
+
+	// Root is the workspace directory, the parent of GitDir
+	Root string
+
+	// GitDir is the repository's git directory, <Root>/.git
+	GitDir string
+}
+
+// Target selects a workspace by directory or by registered name
+type Target struct {
+	// Dir is any directory inside the workspace
+	Dir string
+
+	// Repo is the registered name of the workspace. It wins over Dir.
+	Repo string
+}
+
+// Resolve finds the workspace selected by target. It returns a NotInRepo
+// error when Dir is not inside a repository and a NotFound error when Repo
+// is not registered.
+func (m *Manager) Resolve(ctx context.Context, target Target) (*Workspace, error) {
+	cfg, err := m.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dir := target.Dir
+	if target.Repo != "" {
+		repo, err := cfg.Repository(target.Repo)
+		if err != nil {
+			return nil, err
+		}
+		dir = repo.Path
+	}
+
+	gitDir, err := worktree.FindGitDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &Workspace{Root: filepath.Dir(gitDir), GitDir: gitDir}
+	for _, repo := range cfg.Repositories {
+		if repo.Path == ws.Root {
+			ws.Name = repo.Name
+			break
+		}
+	}
+	return ws, nil
+}
+
+// getenv looks up key in the manager's environment
+func (m *Manager) getenv(key string) string {
+	if m.env == nil {
+		return os.Getenv(key)
+	}
+	value := ""
+	for _, kv := range m.env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == key {
+			value = v
+		}
+	}
+	return value
+}
+
+// loadConfig returns the user configuration
+func (m *Manager) loadConfig() (*config.Config, error) {
+	if m.configErr != nil {
+		return nil, m.configErr
+	}
+	return config.Load(m.configPath)
+}
+
+// timeoutFor returns how long inv may run. The configured timeouts are read
+// once; a configuration that cannot be read means no timeouts, and the error
+// surfaces from whatever reads the configuration next.
+func (m *Manager) timeoutFor(inv git.Invocation) time.Duration {
+	if m.timeout > 0 {
+		return m.timeout
+	}
+
+	m.timeoutsOnce.Do(func() {
+		m.timeouts, _ = m.loadConfig()
+	})
+	if m.timeouts == nil {
+		return 0
+	}
+	d, _ := m.timeouts.Timeout(git.Subcommand(inv.Args))
+	return d
+}
+
+// saveConfig writes the user configuration back
+func (m *Manager) saveConfig(cfg *config.Config) error {
+	return cfg.Save(m.configPath)
+}
+
+// backend returns the backend answering read-only queries
+func (m *Manager) backend() (worktree.Backend, error) {
+	if m.queries != nil {
+		return m.queries, nil
+	}
+
+	name := m.backendName
+	if name == "" {
+		cfg, err := m.loadConfig()
+		if err != nil {
+			return nil, err
+		}
+		name = cfg.Backend
+	}
+
+	b, err := worktree.NewBackend(name, m.git, m.features)
+	if err != nil {
+		return nil, err
+	}
+	m.queries = b
+	return b, nil
+}
+
+// IsRepository reports whether dir is inside a git repository, either in a
+// worktree or in the repository itself
+func (m *Manager) IsRepository(ctx context.Context, dir string) bool {
+	b, err := m.backend()
+	if err != nil {
+		b = &worktree.CLIBackend{Git: m.git}
+	}
+	return worktree.IsRepository(ctx, b, dir)
+}
+
+// gitStep returns a plan step that runs git with args in dir, streaming its
+// output to the progress writers. Failures are git failures with msg as
+// context.
+func (m *Manager) gitStep(ctx context.Context, dir string, msg string, args ...string) plan.Step {
+	return plan.Step{
+		Description: plan.Command(append([]string{"git"}, args...)...),
+		Run: func() error {
+			if err := git.Run(ctx, m.git, dir, m.progress, m.progressErr, args...); err != nil {
+				return wrapGit(err, msg)
+			}
+			return nil
+		},
+	}
+}
+
+// undoStep returns a function that runs git with args in dir to undo a
+// step. It runs even when ctx was cancelled, which is usually why a step
+// needs undoing.
+func (m *Manager) undoStep(ctx context.Context, dir string, args ...string) func() error {
+	ctx = context.WithoutCancel(ctx)
+	return func() error {
+		return git.Run(ctx, m.git, dir, nil, nil, args...)
+	}
+}
+
+// run executes p unless dryRun is set, and returns the planned steps
+func (m *Manager) run(p *plan.Plan, dryRun bool) ([]string, error) {
+	steps := p.Descriptions()
+	if dryRun {
+		return steps, nil
+	}
+	return steps, p.Execute(m.progress)
+}
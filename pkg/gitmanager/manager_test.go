@@ -0,0 +1,265 @@
+package gitmanager_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// newManager returns a Manager with its own empty configuration file
+func newManager(t *testing.T) *gitmanager.Manager {
+	t.Helper()
+	return gitmanager.New(gitmanager.Options{
+		ConfigPath: filepath.Join(t.TempDir(), "config.json"),
+	})
+}
+
+// TestResolve tests resolving a workspace by directory and by name
+func TestResolve(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	got, err := m.Resolve(ctx, gitmanager.Target{Dir: ws.Worktree("main").Path})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if got.Root != ws.Root || got.GitDir != ws.GitDir || got.Name != "" {
+		t.Errorf("Resolve by directory = %+v, want root %s and no name", got, ws.Root)
+	}
+
+	if _, err := m.Resolve(ctx, gitmanager.Target{Repo: "api"}); gitmanager.KindOf(err) != gitmanager.NotFound {
+		t.Errorf("Expected a not-found error for an unregistered name, got %v", err)
+	}
+
+	if _, err := m.Register(ws.Root, "api"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	got, err = m.Resolve(ctx, gitmanager.Target{Dir: t.TempDir(), Repo: "api"})
+	if err != nil {
+		t.Fatalf("Resolve by name failed: %v", err)
+	}
+	if got.Root != ws.Root || got.Name != "api" {
+		t.Errorf("Resolve by name = %+v, want root %s named api", got, ws.Root)
+	}
+
+	if _, err := m.Resolve(ctx, gitmanager.Target{Dir: t.TempDir()}); gitmanager.KindOf(err) != gitmanager.NotInRepo {
+		t.Errorf("Expected a not-in-repo error outside a repository, got %v", err)
+	}
+}
+
+// TestInitRepository tests creating and registering a workspace
+func TestInitRepository(t *testing.T) {
+	ws, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	opts := gitmanager.InitOptions{URL: ws.Origin.Path, Dir: dir, Name: "api", DryRun: true}
+	res, err := m.InitRepository(ctx, opts)
+	if err != nil {
+		t.Fatalf("InitRepository --dry-run failed: %v", err)
+	}
+	if len(res.Plan) != 4 {
+		t.Errorf("Expected 4 planned steps, got %q", res.Plan)
+	}
+	if repos, _ := m.Repositories(); len(repos) != 0 {
+		t.Errorf("Expected a dry run to register nothing, got %v", repos)
+	}
+
+	opts.DryRun = false
+	res, err = m.InitRepository(ctx, opts)
+	if err != nil {
+		t.Fatalf("InitRepository failed: %v", err)
+	}
+	if want := filepath.Join(dir, "api", "main"); res.MainWorktree != want {
+		t.Errorf("MainWorktree = %s, want %s", res.MainWorktree, want)
+	}
+
+	repos, err := m.Repositories()
+	if err != nil {
+		t.Fatalf("Repositories failed: %v", err)
+	}
+	if len(repos) != 1 || repos[0] != (gitmanager.Repository{Name: "api", Path: res.Workspace.Root}) {
+		t.Errorf("Expected api to be registered at %s, got %v", res.Workspace.Root, repos)
+	}
+
+	if _, err := m.InitRepository(ctx, opts); gitmanager.KindOf(err) != gitmanager.Conflict {
+		t.Errorf("Expected a conflict initializing over a workspace, got %v", err)
+	}
+
+	if err := m.Unregister("api"); err != nil {
+		t.Fatalf("Unregister failed: %v", err)
+	}
+	if err := m.Unregister("api"); gitmanager.KindOf(err) != gitmanager.NotFound {
+		t.Errorf("Expected a not-found error unregistering twice, got %v", err)
+	}
+}
+
+// TestRepositoryName tests deriving workspace names from URLs
+func TestRepositoryName(t *testing.T) {
+	tests := map[string]string{
+		"https://github.com/org/api.git": "api",
+		"https://github.com/org/api/":    "api",
+		"git@github.com:api.git":         "api",
+		"/srv/git/api":                   "api",
+	}
+	for url, want := range tests {
+		if got := gitmanager.RepositoryName(url); got != want {
+			t.Errorf("RepositoryName(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+// TestMoveRepository tests that moving a workspace keeps every worktree,
+// including one outside the workspace, usable and updates the registry
+func TestMoveRepository(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	testWS.AddWorktree(t, "feature")
+	outside := filepath.Join(testWS.TempDir, "outside")
+	(&testutil.GitRepo{Path: testWS.GitDir}).RunGit(t, "worktree", "add", "-b", "outside", outside, "main")
+	dotted := filepath.Join(testWS.Root, "..dotted")
+	(&testutil.GitRepo{Path: testWS.GitDir}).RunGit(t, "worktree", "add", "-b", "dotted", dotted, "main")
+	if _, err := m.Register(testWS.Root, "api"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	ws, err := m.Resolve(ctx, gitmanager.Target{Repo: "api"})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	dest := filepath.Join(testWS.TempDir, "src", "github.com", "org", "api")
+	res, err := m.MoveRepository(ctx, ws, gitmanager.MoveOptions{Dest: dest})
+	if err != nil {
+		t.Fatalf("MoveRepository failed: %v", err)
+	}
+	if res.Moved[filepath.Join(testWS.Root, "feature")] != filepath.Join(dest, "feature") {
+		t.Errorf("Expected feature to move into %s, got %v", dest, res.Moved)
+	}
+	if res.Moved[dotted] != filepath.Join(dest, "..dotted") {
+		t.Errorf("Expected ..dotted to move into %s, got %v", dest, res.Moved)
+	}
+
+	for _, dir := range []string{filepath.Join(dest, "main"), filepath.Join(dest, "feature"), filepath.Join(dest, "..dotted"), outside} {
+		(&testutil.GitRepo{Path: dir}).RunGit(t, "status")
+	}
+	repo, err := m.Repository("api")
+	if err != nil || repo.Path != dest {
+		t.Errorf("Expected api to be registered at %s, got %+v, %v", dest, repo, err)
+	}
+
+	moved, err := m.Resolve(ctx, gitmanager.Target{Repo: "api"})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	problems, err := m.Diagnose(ctx, moved)
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+	for _, p := range problems {
+		if p.Code != gitmanager.ProblemMissingRefspec {
+			t.Errorf("Unexpected problem after the move: %s: %s", p.Code, p.Message)
+		}
+	}
+}
+
+// TestMoveRepositoryRollsBack tests that a failed repair moves the
+// workspace back where it was
+func TestMoveRepositoryRollsBack(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	fake := testutil.GitVersion(t, "2.39.5")
+	fake.On("worktree", "repair").Times(1).Fail(1, "fatal: repair failed")
+	m := gitmanager.New(gitmanager.Options{
+		Git:        fake,
+		ConfigPath: filepath.Join(t.TempDir(), "config.json"),
+	})
+
+	ws, err := m.Resolve(ctx, gitmanager.Target{Dir: testWS.Root})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	dest := filepath.Join(testWS.TempDir, "moved")
+	if _, err := m.MoveRepository(ctx, ws, gitmanager.MoveOptions{Dest: dest}); gitmanager.KindOf(err) != gitmanager.GitFailure {
+		t.Fatalf("Expected a git failure, got %v", err)
+	}
+
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be gone after the rollback, got %v", dest, err)
+	}
+	testWS.Worktree("main").RunGit(t, "status")
+}
+
+// TestMoveRepositoryAcrossFilesystems tests that a move to another filesystem
+// is refused before anything is touched
+func TestMoveRepositoryAcrossFilesystems(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	other, err := os.MkdirTemp("/dev/shm", "git-manager-move-")
+	if err != nil {
+		t.Skipf("no /dev/shm to move into: %v", err)
+	}
+	defer os.RemoveAll(other)
+	probe := filepath.Join(testWS.TempDir, "probe")
+	if err := os.Mkdir(probe, 0o755); err != nil {
+		t.Fatalf("Failed to create probe: %v", err)
+	}
+	if err := os.Rename(probe, filepath.Join(other, "probe")); !errors.Is(err, syscall.EXDEV) {
+		t.Skipf("%s is on the same filesystem as %s", other, testWS.TempDir)
+	}
+
+	ws, err := m.Resolve(ctx, gitmanager.Target{Dir: testWS.Root})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	dest := filepath.Join(other, "api")
+	_, err = m.MoveRepository(ctx, ws, gitmanager.MoveOptions{Dest: dest})
+	if gitmanager.KindOf(err) != gitmanager.Unsupported {
+		t.Fatalf("Expected an unsupported error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "tool doctor --fix") {
+		t.Errorf("Expected the error to explain the manual move, got %v", err)
+	}
+
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("Expected %s not to exist, got %v", dest, err)
+	}
+	testWS.Worktree("main").RunGit(t, "status")
+}
+
+// TestMoveRepositoryRelativePaths tests that relative worktree paths are
+// refused when git is too old for them
+func TestMoveRepositoryRelativePaths(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	m := gitmanager.New(gitmanager.Options{
+		Git:        testutil.GitVersion(t, "2.47.1"),
+		ConfigPath: filepath.Join(t.TempDir(), "config.json"),
+	})
+	ws := &gitmanager.Workspace{Root: testWS.Root, GitDir: testWS.GitDir}
+
+	_, err := m.MoveRepository(ctx, ws, gitmanager.MoveOptions{Dest: filepath.Join(testWS.TempDir, "moved"), RelativePaths: true})
+	if gitmanager.KindOf(err) != gitmanager.Unsupported {
+		t.Errorf("Expected an unsupported error, got %v", err)
+	}
+}
@@ -0,0 +1,344 @@
+package gitmanager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/errs"
+	"github.com/ingshtrom/git-manager/internal/git"
+	"github.com/ingshtrom/git-manager/internal/manifest"
+)
+
+// Manifest describes a set of workspaces so they can be recreated on
+// another machine. Write it with WriteManifest and read it back with
+// ReadManifest.
+type Manifest = manifest.Manifest
+
+// ManifestRepository is a workspace in a Manifest
+type ManifestRepository = manifest.Repository
+
+// ManifestRemote is a remote of a ManifestRepository
+type ManifestRemote = manifest.Remote
+
+// ManifestWorktree is a worktree of a ManifestRepository
+type ManifestWorktree = manifest.Worktree
+
+// WriteManifest writes man to w as YAML
+func WriteManifest(w io.Writer, man *Manifest) error {
+	return manifest.Write(w, man)
+}
+
+// ReadManifest reads a YAML manifest. Invalid manifests are Usage errors.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	return manifest.Read(r)
+}
+
+// ExportOptions configures ExportManifest
+type ExportOptions struct {
+	// Worktrees also records the active worktrees and their branches
+	Worktrees bool
+}
+
+// ExportManifest describes every registered workspace: its path, remotes
+// and default branch, and with opts.Worktrees its worktrees. Paths in the
+// home directory are written relative to "~".
+func (m *Manager) ExportManifest(ctx context.Context, opts ExportOptions) (*Manifest, error) {
+	repos, err := m.Repositories()
+	if err != nil {
+		return nil, err
+	}
+
+	man := &Manifest{Version: manifest.Version}
+	for _, repo := range repos {
+		ws, err := m.Resolve(ctx, Target{Repo: repo.Name})
+		if err != nil {
+			return nil, errs.Annotate(errs.Unknown, err, "error exporting repository '%s'", repo.Name)
+		}
+
+		entry := ManifestRepository{Name: repo.Name, Path: m.collapseHome(ws.Root)}
+		if entry.Remotes, err = m.remotes(ctx, ws); err != nil {
+			return nil, err
+		}
+		if entry.DefaultBranch, err = m.defaultBranch(ctx, ws); err != nil {
+			return nil, err
+		}
+
+		if opts.Worktrees {
+			worktrees, err := m.ListWorktrees(ctx, ws)
+			if err != nil {
+				return nil, err
+			}
+			for _, wt := range worktrees {
+				rel, err := filepath.Rel(ws.Root, wt.Path)
+				if wt.IsBare || wt.Prunable || wt.Branch == "" || err != nil || strings.HasPrefix(rel, "..") || rel == "." {
+					continue
+				}
+				entry.Worktrees = append(entry.Worktrees, ManifestWorktree{Path: filepath.ToSlash(rel), Branch: wt.Branch})
+			}
+		}
+
+		man.Repositories = append(man.Repositories, entry)
+	}
+	return man, nil
+}
+
+// remotes returns the remotes of ws with origin first, as it is cloned from
+func (m *Manager) remotes(ctx context.Context, ws *Workspace) ([]ManifestRemote, error) {
+	out, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "remote")
+	if err != nil {
+		return nil, wrapGit(err, "error listing remotes")
+	}
+	names := strings.Fields(out)
+	sort.SliceStable(names, func(i, j int) bool {
+		return names[i] == "origin" && names[j] != "origin"
+	})
+
+	remotes := make([]ManifestRemote, 0, len(names))
+	for _, name := range names {
+		url, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "remote", "get-url", name)
+		if err != nil {
+			return nil, wrapGit(err, fmt.Sprintf("error reading the URL of remote '%s'", name))
+		}
+		remotes = append(remotes, ManifestRemote{Name: name, URL: strings.TrimSpace(url)})
+	}
+	return remotes, nil
+}
+
+// defaultBranch returns the branch HEAD of the repository of ws points to,
+// or "" if it is detached
+func (m *Manager) defaultBranch(ctx context.Context, ws *Workspace) (string, error) {
+	out, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", wrapGit(err, "error reading HEAD")
+		}
+		return "", nil
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// collapseHome writes path relative to "~" when it is in the home directory
+func (m *Manager) collapseHome(path string) string {
+	home := m.getenv("HOME")
+	if home == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(home, path); err == nil && !strings.HasPrefix(rel, "..") && rel != "." {
+		return "~/" + filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// expandHome undoes collapseHome
+func (m *Manager) expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home := m.getenv("HOME"); home != "" {
+			return filepath.Join(home, filepath.FromSlash(rest))
+		}
+	}
+	return filepath.Clean(filepath.FromSlash(path))
+}
+
+// ApplyOptions configures ApplyManifest
+type ApplyOptions struct {
+	// DryRun reports what would change but changes nothing
+	DryRun bool
+}
+
+// ApplyResult is what ApplyManifest did for one repository
+type ApplyResult struct {
+	Name string
+	Path string
+
+	// Actions describes the changes made, or with DryRun the changes that
+	// would be made
+	Actions []string
+
+	// Drift describes differences from the manifest that ApplyManifest does
+	// not change, because they may be intended, such as a remote pointing
+	// elsewhere or a worktree the manifest does not list
+	Drift []string
+
+	// Err is why the repository could not be applied
+	Err error
+}
+
+// ApplyManifest makes the registered workspaces match man: missing
+// workspaces are cloned from their first remote, and missing registrations,
+// remotes and worktrees are added. Applying the same manifest again changes
+// nothing. A repository that fails does not stop the others; its error is
+// in its result.
+func (m *Manager) ApplyManifest(ctx context.Context, man *Manifest, opts ApplyOptions) ([]ApplyResult, error) {
+	results := make([]ApplyResult, 0, len(man.Repositories))
+	for _, repo := range man.Repositories {
+		if err := ctx.Err(); err != nil {
+			return results, newError(Interrupted, "apply was interrupted")
+		}
+		res := ApplyResult{Name: repo.Name, Path: m.expandHome(repo.Path)}
+		res.Err = m.applyRepository(ctx, repo, &res, opts.DryRun)
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// applyRepository applies one repository of a manifest, recording what it
+// did in res
+func (m *Manager) applyRepository(ctx context.Context, repo ManifestRepository, res *ApplyResult, dryRun bool) error {
+	// A relative path would depend on where git-manager runs
+	if !filepath.IsAbs(res.Path) {
+		return newError(Usage, "the path %s of repository '%s' must be absolute or start with ~/", repo.Path, repo.Name)
+	}
+	for _, wt := range repo.Worktrees {
+		path := filepath.Join(res.Path, filepath.FromSlash(wt.Path))
+		if filepath.IsAbs(filepath.FromSlash(wt.Path)) || path == res.Path || !isWithin(path, res.Path) {
+			return newError(Usage, "the worktree path %s of repository '%s' is not inside the workspace", wt.Path, repo.Name)
+		}
+	}
+
+	if _, err := os.Stat(res.Path); err != nil {
+		if len(repo.Remotes) == 0 {
+			return newError(NotFound, "%s does not exist and the manifest has no remote to clone it from", res.Path)
+		}
+		init, err := m.InitRepository(ctx, InitOptions{
+			URL:    repo.Remotes[0].URL,
+			Name:   repo.Name,
+			Path:   res.Path,
+			Remote: repo.Remotes[0].Name,
+			DryRun: dryRun,
+		})
+		if err != nil {
+			return err
+		}
+		res.Actions = append(res.Actions, fmt.Sprintf("clone %s as remote '%s'", repo.Remotes[0].URL, repo.Remotes[0].Name))
+		if dryRun {
+			// Nothing to compare against until the clone exists
+			for _, remote := range repo.Remotes[1:] {
+				res.Actions = append(res.Actions, fmt.Sprintf("add remote '%s' %s", remote.Name, remote.URL))
+			}
+			for _, wt := range repo.Worktrees {
+				if filepath.Join(res.Path, wt.Path) != init.MainWorktree {
+					res.Actions = append(res.Actions, fmt.Sprintf("add worktree %s for branch '%s'", wt.Path, wt.Branch))
+				}
+			}
+			return nil
+		}
+	}
+
+	ws, err := m.Resolve(ctx, Target{Dir: res.Path})
+	if err != nil {
+		return err
+	}
+	if filepath.Clean(ws.Root) != res.Path {
+		return newError(Conflict, "%s is inside the workspace %s", res.Path, ws.Root)
+	}
+
+	// Registration
+	switch existing, err := m.Repository(repo.Name); {
+	case err == nil && existing.Path != ws.Root:
+		res.Drift = append(res.Drift, fmt.Sprintf("the name '%s' is registered for %s", repo.Name, existing.Path))
+	case err == nil:
+	case KindOf(err) == NotFound:
+		res.Actions = append(res.Actions, registerStepDescription(repo.Name, ws.Root))
+		if !dryRun {
+			if err := m.register(repo.Name, ws.Root); err != nil {
+				return err
+			}
+		}
+	default:
+		return err
+	}
+
+	// Remotes
+	current, err := m.remotes(ctx, ws)
+	if err != nil {
+		return err
+	}
+	urls := map[string]string{}
+	for _, remote := range current {
+		urls[remote.Name] = remote.URL
+	}
+	listed := map[string]bool{}
+	for _, remote := range repo.Remotes {
+		listed[remote.Name] = true
+		url, ok := urls[remote.Name]
+		switch {
+		case !ok:
+			res.Actions = append(res.Actions, fmt.Sprintf("add remote '%s' %s", remote.Name, remote.URL))
+			if !dryRun {
+				if err := git.Run(ctx, m.git, ws.Root, nil, nil, "-C", ws.GitDir, "remote", "add", remote.Name, remote.URL); err != nil {
+					return wrapGit(err, "error adding remote")
+				}
+			}
+		case url != remote.URL:
+			res.Drift = append(res.Drift, fmt.Sprintf("remote '%s' is %s, the manifest says %s", remote.Name, url, remote.URL))
+		}
+	}
+	for _, remote := range current {
+		if !listed[remote.Name] {
+			res.Drift = append(res.Drift, fmt.Sprintf("remote '%s' is not in the manifest", remote.Name))
+		}
+	}
+
+	// Default branch
+	if repo.DefaultBranch != "" {
+		branch, err := m.defaultBranch(ctx, ws)
+		if err != nil {
+			return err
+		}
+		if branch != repo.DefaultBranch {
+			res.Drift = append(res.Drift, fmt.Sprintf("the default branch is '%s', the manifest says '%s'", branch, repo.DefaultBranch))
+		}
+	}
+
+	// Worktrees
+	if repo.Worktrees == nil {
+		return nil
+	}
+	worktrees, err := m.ListWorktrees(ctx, ws)
+	if err != nil {
+		return err
+	}
+	byPath := map[string]Worktree{}
+	for _, wt := range worktrees {
+		byPath[wt.Path] = wt
+	}
+	wanted := map[string]bool{}
+	for _, want := range repo.Worktrees {
+		path := filepath.Join(ws.Root, filepath.FromSlash(want.Path))
+		wanted[path] = true
+
+		if wt, ok := byPath[path]; ok {
+			if wt.Branch != want.Branch {
+				res.Drift = append(res.Drift, fmt.Sprintf("worktree %s has '%s' checked out, the manifest says '%s'", want.Path, wt.Branch, want.Branch))
+			}
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			res.Drift = append(res.Drift, fmt.Sprintf("%s exists but is not a worktree", want.Path))
+			continue
+		}
+		exists, err := m.branchExists(ctx, ws, want.Branch)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			res.Drift = append(res.Drift, fmt.Sprintf("branch '%s' for worktree %s does not exist", want.Branch, want.Path))
+			continue
+		}
+		res.Actions = append(res.Actions, fmt.Sprintf("add worktree %s for branch '%s'", want.Path, want.Branch))
+		if _, err := m.AddWorktree(ctx, ws, AddOptions{Branch: want.Branch, Path: want.Path, DryRun: dryRun}); err != nil {
+			return err
+		}
+	}
+	for _, wt := range worktrees {
+		if !wt.IsBare && !wanted[wt.Path] && wt.Path != ws.Root {
+			res.Drift = append(res.Drift, fmt.Sprintf("worktree %s is not in the manifest", wt.Path))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package gitmanager_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// TestExportAndApplyManifest tests exporting a workspace and recreating it
+// on a "fresh machine" with a different home directory, using only file://
+// remotes
+func TestExportAndApplyManifest(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	originURL := "file://" + testWS.Origin.Path
+	bare := &testutil.GitRepo{Path: testWS.GitDir}
+	bare.RunGit(t, "remote", "set-url", "origin", originURL)
+	testWS.Origin.RunGit(t, "branch", "feature/login")
+	bare.RunGit(t, "fetch", "origin", "feature/login:feature/login")
+	bare.RunGit(t, "worktree", "add", filepath.Join(testWS.Root, "login"), "feature/login")
+
+	t.Setenv("HOME", testWS.TempDir)
+	m := newManager(t)
+	if _, err := m.Register(testWS.Root, "api"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	man, err := m.ExportManifest(ctx, gitmanager.ExportOptions{Worktrees: true})
+	if err != nil {
+		t.Fatalf("ExportManifest failed: %v", err)
+	}
+	want := []gitmanager.ManifestRepository{{
+		Name:          "api",
+		Path:          "~/workspace",
+		DefaultBranch: "main",
+		Remotes:       []gitmanager.ManifestRemote{{Name: "origin", URL: originURL}},
+		Worktrees: []gitmanager.ManifestWorktree{
+			{Path: "login", Branch: "feature/login"},
+			{Path: "main", Branch: "main"},
+		},
+	}}
+	if !reflect.DeepEqual(man.Repositories, want) {
+		t.Fatalf("ExportManifest = %+v, want %+v", man.Repositories, want)
+	}
+
+	var buf bytes.Buffer
+	if err := gitmanager.WriteManifest(&buf, man); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+	man, err = gitmanager.ReadManifest(&buf)
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+
+	// A fresh machine: another home directory and an empty registry
+	fresh := filepath.Join(testWS.TempDir, "fresh")
+	t.Setenv("HOME", fresh)
+	m = newManager(t)
+
+	results, err := m.ApplyManifest(ctx, man, gitmanager.ApplyOptions{})
+	if err != nil || len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("ApplyManifest failed: %v, %+v", err, results)
+	}
+	res := results[0]
+	if res.Path != filepath.Join(fresh, "workspace") || len(res.Actions) != 2 || len(res.Drift) != 0 {
+		t.Errorf("Expected a clone and one added worktree, got %+v", res)
+	}
+	(&testutil.GitRepo{Path: filepath.Join(fresh, "workspace", "login")}).RunGit(t, "status")
+	if repo, err := m.Repository("api"); err != nil || repo.Path != res.Path {
+		t.Errorf("Expected api to be registered at %s, got %+v, %v", res.Path, repo, err)
+	}
+
+	// Applying again changes nothing, also when the path is written with a
+	// trailing slash, and changes made by hand are drift
+	man.Repositories[0].Path = res.Path + "/"
+	(&testutil.GitRepo{Path: filepath.Join(fresh, "workspace", ".git")}).RunGit(t, "remote", "add", "fork", originURL)
+	results, err = m.ApplyManifest(ctx, man, gitmanager.ApplyOptions{})
+	if err != nil || results[0].Err != nil {
+		t.Fatalf("ApplyManifest failed: %v, %+v", err, results)
+	}
+	if len(results[0].Actions) != 0 {
+		t.Errorf("Expected nothing to do, got %v", results[0].Actions)
+	}
+	if len(results[0].Drift) != 1 || !strings.Contains(results[0].Drift[0], "remote 'fork' is not in the manifest") {
+		t.Errorf("Expected the extra remote as drift, got %v", results[0].Drift)
+	}
+}
+
+// TestApplyManifestRemoteName tests that a workspace is cloned from a first
+// remote not named origin without drift on the next apply
+func TestApplyManifestRemoteName(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	ctx := context.Background()
+	t.Setenv("HOME", testWS.TempDir)
+	m := newManager(t)
+
+	man := &gitmanager.Manifest{Repositories: []gitmanager.ManifestRepository{{
+		Name:    "api",
+		Path:    "~/api",
+		Remotes: []gitmanager.ManifestRemote{{Name: "upstream", URL: "file://" + testWS.Origin.Path}},
+	}}}
+
+	for i := range 2 {
+		results, err := m.ApplyManifest(ctx, man, gitmanager.ApplyOptions{})
+		if err != nil || results[0].Err != nil {
+			t.Fatalf("ApplyManifest %d failed: %v, %+v", i, err, results)
+		}
+		if len(results[0].Drift) != 0 {
+			t.Errorf("ApplyManifest %d reported drift %v", i, results[0].Drift)
+		}
+		if i == 1 && len(results[0].Actions) != 0 {
+			t.Errorf("Expected nothing to do the second time, got %v", results[0].Actions)
+		}
+	}
+	git := &testutil.GitRepo{Path: filepath.Join(testWS.TempDir, "api", ".git")}
+	if remotes := git.RunGit(t, "remote"); remotes != "upstream\n" {
+		t.Errorf("Expected only the upstream remote, got %q", remotes)
+	}
+}
+
+// TestApplyManifestPaths tests that paths that depend on the working
+// directory or leave the workspace are refused before anything is done
+func TestApplyManifestPaths(t *testing.T) {
+	m := newManager(t)
+	root := filepath.Join(t.TempDir(), "api")
+
+	tests := []struct {
+		name string
+		repo gitmanager.ManifestRepository
+	}{
+		{"relative path", gitmanager.ManifestRepository{Name: "api", Path: "code/api"}},
+		{"escaping worktree", gitmanager.ManifestRepository{
+			Name:      "api",
+			Path:      root,
+			Worktrees: []gitmanager.ManifestWorktree{{Path: "../../elsewhere", Branch: "main"}},
+		}},
+		{"absolute worktree", gitmanager.ManifestRepository{
+			Name:      "api",
+			Path:      root,
+			Worktrees: []gitmanager.ManifestWorktree{{Path: "/tmp/main", Branch: "main"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.repo.Remotes = []gitmanager.ManifestRemote{{Name: "origin", URL: "file:///nonexistent"}}
+			results, err := m.ApplyManifest(context.Background(), &gitmanager.Manifest{Repositories: []gitmanager.ManifestRepository{tt.repo}}, gitmanager.ApplyOptions{})
+			if err != nil || gitmanager.KindOf(results[0].Err) != gitmanager.Usage {
+				t.Errorf("Expected a usage error, got %v, %+v", err, results)
+			}
+		})
+	}
+	if _, err := os.Stat(root); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be created, got %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+package gitmanager
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+)
+
+// OverlapOptions configures FindOverlaps
+type OverlapOptions struct {
+	// Base is the branch changes are measured from. Empty uses the default
+	// branch.
+	Base string
+}
+
+// LineRange is a range of lines, both ends included
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// String formats r as "3-5", or "3" for a single line
+func (r LineRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// Overlap is a pair of worktrees that change the same files
+type Overlap struct {
+	A Worktree
+	B Worktree
+
+	Files []FileOverlap
+}
+
+// FileOverlap is a file both worktrees of an Overlap change
+type FileOverlap struct {
+	Path string
+
+	// Whole is set when either worktree adds, or changes without line
+	// information, the whole file, e.g. because it is new or binary
+	Whole bool
+
+	// Lines are the lines of the file in the merge base that both worktrees
+	// change. It is empty when they change different parts of the file.
+	Lines []LineRange
+}
+
+// changedFile is a file a worktree changed since its merge base, with the
+// changed lines of the merge base version, or no lines for the whole file
+type changedFile struct {
+	path  string
+	lines []LineRange
+}
+
+// FindOverlaps finds the pairs of worktrees of ws that change the same
+// files. The changes of a worktree are everything that differs from its merge
+// base with the base branch: its commits, staged and unstaged changes and
+// untracked files. For files both change, the overlapping lines tell whether
+// the same hunks are touched.
+func (m *Manager) FindOverlaps(ctx context.Context, ws *Workspace, opts OverlapOptions) ([]Overlap, error) {
+	base := opts.Base
+	if base == "" {
+		var err error
+		if base, err = m.defaultBranch(ctx, ws); err != nil {
+			return nil, err
+		}
+		if base == "" {
+			return nil, newError(NotFound, "HEAD of %s is detached, so there is no default branch, use --base", ws.GitDir)
+		}
+	}
+	if exists, err := m.branchExists(ctx, ws, base); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, newError(NotFound, "branch '%s' not found", base)
+	}
+
+	worktrees, err := m.ListWorktrees(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	var active []Worktree
+	var changes []map[string]changedFile
+	for _, wt := range worktrees {
+		if wt.IsBare || wt.Prunable || !dirExists(wt.Path) {
+			continue
+		}
+		files, err := m.changedFiles(ctx, wt.Path, base)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) > 0 {
+			active = append(active, wt)
+			changes = append(changes, files)
+		}
+	}
+
+	var overlaps []Overlap
+	for i := range active {
+		for j := i + 1; j < len(active); j++ {
+			files := overlappingFiles(changes[i], changes[j])
+			if len(files) > 0 {
+				overlaps = append(overlaps, Overlap{A: active[i], B: active[j], Files: files})
+			}
+		}
+	}
+	return overlaps, nil
+}
+
+// changedFiles returns the files the worktree at dir changed since its
+// merge base with base, committed or not
+func (m *Manager) changedFiles(ctx context.Context, dir string, base string) (map[string]changedFile, error) {
+	out, err := git.Output(ctx, m.git, dir, "-C", dir, "merge-base", "refs/heads/"+base, "HEAD")
+	if err != nil {
+		return nil, wrapGit(err, fmt.Sprintf("error finding the merge base of %s with '%s'", dir, base))
+	}
+	mergeBase := strings.TrimSpace(out)
+
+	diff, err := git.Output(ctx, m.git, dir, "-C", dir, "-c", "core.quotePath=false", "diff", "-U0", "--no-renames", "--no-color", "--no-ext-diff", mergeBase, "--")
+	if err != nil {
+		return nil, wrapGit(err, fmt.Sprintf("error diffing %s", dir))
+	}
+	files := parseHunks(diff)
+
+	untracked, err := git.Output(ctx, m.git, dir, "-C", dir, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, wrapGit(err, fmt.Sprintf("error listing untracked files of %s", dir))
+	}
+	for _, path := range strings.Split(untracked, "\x00") {
+		if path != "" {
+			files[path] = changedFile{path: path}
+		}
+	}
+	return files, nil
+}
+
+// hunkHeader matches the old side of a hunk header, "@@ -start[,count] ..."
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? `)
+
+// parseHunks parses `git diff -U0 --no-renames` output into the changed
+// lines of the old version of each file. Files without hunks, such as binary
+// files, get no lines, meaning the whole file.
+func parseHunks(diff string) map[string]changedFile {
+	files := map[string]changedFile{}
+	current := ""
+	for _, line := range strings.Split(diff, "\n") {
+		if rest, ok := strings.CutPrefix(line, "diff --git "); ok {
+			current = diffPath(rest)
+			if current != "" {
+				files[current] = changedFile{path: current}
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "new file mode ") {
+			// A new file is new as a whole
+			current = ""
+			continue
+		}
+		match := hunkHeader.FindStringSubmatch(line)
+		if match == nil || current == "" {
+			continue
+		}
+		start, _ := strconv.Atoi(match[1])
+		count := 1
+		if match[2] != "" {
+			count, _ = strconv.Atoi(match[2])
+		}
+		r := LineRange{Start: start, End: start + count - 1}
+		if count == 0 {
+			// Lines added after start touch the line they follow
+			r.End = start
+		}
+		f := files[current]
+		f.lines = append(f.lines, r)
+		files[current] = f
+	}
+	return files
+}
+
+// diffPath returns the path in the paths of a "diff --git" line. Without
+// renames both paths are the same: "a/<path> b/<path>". Paths with quotes,
+// backslashes or control characters are C-style quoted even with
+// core.quotePath=false, as in "\"a/tab\there\" \"b/tab\there\"".
+func diffPath(paths string) string {
+	if strings.HasPrefix(paths, `"`) {
+		quoted, err := strconv.QuotedPrefix(paths)
+		if err != nil {
+			return ""
+		}
+		path, err := strconv.Unquote(quoted)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimPrefix(path, "a/")
+	}
+	rest, ok := strings.CutPrefix(paths, "a/")
+	if !ok {
+		return ""
+	}
+	return rest[:(len(rest)-len(" b/"))/2]
+}
+
+// overlappingFiles returns the files changed in both a and b, sorted by path
+func overlappingFiles(a map[string]changedFile, b map[string]changedFile) []FileOverlap {
+	var overlaps []FileOverlap
+	for path, fa := range a {
+		fb, ok := b[path]
+		if !ok {
+			continue
+		}
+		o := FileOverlap{Path: path, Whole: len(fa.lines) == 0 || len(fb.lines) == 0}
+		if !o.Whole {
+			o.Lines = intersect(fa.lines, fb.lines)
+		}
+		overlaps = append(overlaps, o)
+	}
+	sort.Slice(overlaps, func(i, j int) bool { return overlaps[i].Path < overlaps[j].Path })
+	return overlaps
+}
+
+// intersect returns the lines in both a and b, sorted and merged
+func intersect(a []LineRange, b []LineRange) []LineRange {
+	var both []LineRange
+	for _, ra := range a {
+		for _, rb := range b {
+			if start, end := max(ra.Start, rb.Start), min(ra.End, rb.End); start <= end {
+				both = append(both, LineRange{Start: start, End: end})
+			}
+		}
+	}
+	sort.Slice(both, func(i, j int) bool { return both[i].Start < both[j].Start })
+
+	var out []LineRange
+	for _, r := range both {
+		if n := len(out); n > 0 && r.Start <= out[n-1].End+1 {
+			out[n-1].End = max(out[n-1].End, r.End)
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
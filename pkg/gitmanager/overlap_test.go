@@ -0,0 +1,82 @@
+package gitmanager_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// TestFindOverlaps tests finding worktrees that change the same files and
+// lines, counting commits, staged and unstaged changes and untracked files
+func TestFindOverlaps(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+
+	lines := make([]string, 10)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+	}
+	withLines := func(changes map[int]string) string {
+		out := append([]string(nil), lines...)
+		for i, line := range changes {
+			out[i-1] = line
+		}
+		return strings.Join(out, "\n") + "\n"
+	}
+	main := testWS.Worktree("main")
+	main.CreateFile(t, "app.txt", withLines(nil))
+	main.AddAndCommit(t, "Add app", "app.txt")
+	// git quotes names like this one even with core.quotePath=false
+	odd := "say \"hi\"\t.txt"
+	main.CreateFile(t, odd, withLines(nil))
+	main.AddAndCommit(t, "Add odd", odd)
+
+	// a commits a change to line 2 and has an untracked file
+	a := testWS.AddWorktree(t, "a")
+	a.CreateFile(t, "app.txt", withLines(map[int]string{2: "a"}))
+	a.AddAndCommit(t, "Change line 2", "app.txt")
+	a.CreateFile(t, "notes.md", "a\n")
+
+	// b changes lines 2 and 9 without committing
+	b := testWS.AddWorktree(t, "b")
+	b.CreateFile(t, "app.txt", withLines(map[int]string{2: "b", 9: "b"}))
+	b.CreateFile(t, odd, withLines(map[int]string{5: "b"}))
+	a.CreateFile(t, odd, withLines(map[int]string{5: "a"}))
+
+	// c stages a change to line 9
+	c := testWS.AddWorktree(t, "c")
+	c.CreateFile(t, "app.txt", withLines(map[int]string{9: "c"}))
+	c.RunGit(t, "add", "app.txt")
+
+	// d has the same untracked file as a
+	testWS.AddWorktree(t, "d").CreateFile(t, "notes.md", "d\n")
+
+	ws := &gitmanager.Workspace{Root: testWS.Root, GitDir: testWS.GitDir}
+	overlaps, err := m.FindOverlaps(context.Background(), ws, gitmanager.OverlapOptions{})
+	if err != nil {
+		t.Fatalf("FindOverlaps failed: %v", err)
+	}
+
+	var got []string
+	for _, o := range overlaps {
+		for _, f := range o.Files {
+			got = append(got, fmt.Sprintf("%s+%s %s whole=%v lines=%v", filepath.Base(o.A.Path), filepath.Base(o.B.Path), f.Path, f.Whole, f.Lines))
+		}
+	}
+	want := []string{
+		"a+b app.txt whole=false lines=[2]",
+		"a+b say \"hi\"\t.txt whole=false lines=[5]",
+		"a+c app.txt whole=false lines=[]",
+		"a+d notes.md whole=true lines=[]",
+		"b+c app.txt whole=false lines=[9]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("FindOverlaps =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,23 @@
+package gitmanager
+
+import "sync"
+
+// parallel calls fn for 0 to n-1, running up to jobs calls at once
+func parallel(n int, jobs int, fn func(i int)) {
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+}
@@ -0,0 +1,175 @@
+package gitmanager
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+)
+
+// ReportOptions configures Report
+type ReportOptions struct {
+	// Since leaves out commits authored before this time. Rebased and
+	// amended commits keep their author date, so old work that was just
+	// rebased is left out.
+	Since time.Time
+
+	// Author is a pattern matched against the author name and email of
+	// commits, as with `git log --author`. "" and "me" stand for the
+	// user.email configured for the repository.
+	Author string
+}
+
+// BranchReport lists the commits on a local branch since
+// ReportOptions.Since. A commit on several branches is in the report of only
+// one of them: the default branch if it has the commit, otherwise the first
+// of the branches by name.
+type BranchReport struct {
+	Branch string
+
+	// Commits are newest first
+	Commits []ReportCommit
+
+	// InProgress is set when a worktree with the branch checked out has
+	// uncommitted changes
+	InProgress bool
+}
+
+// Insertions returns the lines added by all commits
+func (b BranchReport) Insertions() int {
+	n := 0
+	for _, c := range b.Commits {
+		n += c.Insertions
+	}
+	return n
+}
+
+// Deletions returns the lines removed by all commits
+func (b BranchReport) Deletions() int {
+	n := 0
+	for _, c := range b.Commits {
+		n += c.Deletions
+	}
+	return n
+}
+
+// ReportCommit is a commit in a report with its diffstat
+type ReportCommit struct {
+	Hash    string
+	Subject string
+
+	// Time is the author date, which ReportOptions.Since filters on
+	Time time.Time
+
+	Files      int
+	Insertions int
+	Deletions  int
+}
+
+// Report collects the commits by opts.Author on the local branches of ws,
+// merges left out. The default branch comes first and the others by name. A
+// commit on several branches is listed only under the first of them, see
+// BranchReport. Branches without commits are left out unless they are in progress.
+func (m *Manager) Report(ctx context.Context, ws *Workspace, opts ReportOptions) ([]BranchReport, error) {
+	authorArgs := []string{"--author=" + opts.Author}
+	if opts.Author == "" || opts.Author == "me" {
+		out, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "config", "user.email")
+		email := strings.TrimSpace(out)
+		if err != nil || email == "" {
+			if ctx.Err() != nil {
+				return nil, wrapGit(err, "error reading user.email")
+			}
+			return nil, newError(Usage, "user.email is not configured, set it or name an author")
+		}
+		authorArgs = []string{"--fixed-strings", "--author=<" + email + ">"}
+	}
+
+	out, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "for-each-ref", "--sort=refname", "--format=%(refname:short)", "refs/heads")
+	if err != nil {
+		return nil, wrapGit(err, "error listing branches")
+	}
+	branches := strings.Fields(out)
+	def, err := m.defaultBranch(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(branches, func(i, j int) bool {
+		return branches[i] == def && branches[j] != def
+	})
+
+	statuses, err := m.Status(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+	inProgress := map[string]bool{}
+	for _, st := range statuses {
+		if st.Branch != "" && st.Dirty() {
+			inProgress[st.Branch] = true
+		}
+	}
+
+	// git filters on the committer date, which is normally no earlier than
+	// the author date, so --since only narrows the walk and the author date
+	// is checked below
+	seen := map[string]bool{}
+	var reports []BranchReport
+	for _, branch := range branches {
+		args := []string{"-C", ws.GitDir, "log", "--no-merges", "--numstat",
+			"--format=%x1e%H%x1f%aI%x1f%s",
+			"--since=" + opts.Since.Format("2006-01-02T15:04:05-07:00")}
+		args = append(args, authorArgs...)
+		args = append(args, "refs/heads/"+branch, "--")
+		out, err := git.Output(ctx, m.git, ws.Root, args...)
+		if err != nil {
+			return nil, wrapGit(err, "error reading the log of "+branch)
+		}
+
+		report := BranchReport{Branch: branch, InProgress: inProgress[branch]}
+		for _, c := range parseReportLog(out) {
+			if c.Time.Before(opts.Since) {
+				continue
+			}
+			if !seen[c.Hash] {
+				seen[c.Hash] = true
+				report.Commits = append(report.Commits, c)
+			}
+		}
+		if len(report.Commits) > 0 || report.InProgress {
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
+
+// parseReportLog parses `git log --numstat` output with a format of
+// "%x1e%H%x1f%aI%x1f%s"
+func parseReportLog(out string) []ReportCommit {
+	var commits []ReportCommit
+	for _, record := range strings.Split(out, "\x1e") {
+		header, stat, _ := strings.Cut(record, "\n")
+		fields := strings.SplitN(header, "\x1f", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		c := ReportCommit{Hash: fields[0], Subject: fields[2]}
+		c.Time, _ = time.Parse(time.RFC3339, fields[1])
+
+		for _, line := range strings.Split(stat, "\n") {
+			// <added>\t<deleted>\t<path>, with "-" for binary files
+			parts := strings.SplitN(line, "\t", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			c.Files++
+			added, _ := strconv.Atoi(parts[0])
+			deleted, _ := strconv.Atoi(parts[1])
+			c.Insertions += added
+			c.Deletions += deleted
+		}
+		commits = append(commits, c)
+	}
+	return commits
+}
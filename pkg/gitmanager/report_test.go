@@ -0,0 +1,77 @@
+package gitmanager_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// TestReport tests collecting my commits per branch by author date, with
+// commits on several branches listed once and dirty worktrees in progress
+func TestReport(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	feature := testWS.AddWorktree(t, "feature")
+	feature.CreateFile(t, "a.txt", "one\ntwo\n")
+	feature.CreateFile(t, "b.txt", "three\n")
+	feature.AddAndCommit(t, "Add feature", "a.txt", "b.txt")
+	feature.CreateFile(t, "c.txt", "theirs\n")
+	feature.RunGit(t, "add", "c.txt")
+	feature.RunGit(t, "commit", "-m", "Their work", "--author", "Other <other@example.com>")
+	// Authored long ago and only now committed, as after a rebase
+	feature.CreateFile(t, "d.txt", "old\n")
+	feature.RunGit(t, "add", "d.txt")
+	feature.RunGit(t, "commit", "-m", "Old work", "--date", time.Now().Add(-48*time.Hour).Format(time.RFC3339))
+	fix := testWS.AddWorktree(t, "fix")
+	fix.CreateFile(t, "README.md", "# Fixing\n")
+
+	ws := &gitmanager.Workspace{Root: testWS.Root, GitDir: testWS.GitDir}
+
+	reports, err := m.Report(ctx, ws, gitmanager.ReportOptions{Since: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	got := fmt.Sprint(reportSummary(reports))
+	want := "[main:false:[Initial commit 1 +1 -0] feature:false:[Add feature 2 +3 -0] fix:true:[]]"
+	if got != want {
+		t.Errorf("Report returned %s, want %s", got, want)
+	}
+
+	// Another author, and nothing committed since
+	reports, err = m.Report(ctx, ws, gitmanager.ReportOptions{Since: time.Now().Add(-time.Hour), Author: "other@"})
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if got := fmt.Sprint(reportSummary(reports)); got != "[feature:false:[Their work 1 +1 -0] fix:true:[]]" {
+		t.Errorf("Report for another author returned %s", got)
+	}
+	reports, err = m.Report(ctx, ws, gitmanager.ReportOptions{Since: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if got := fmt.Sprint(reportSummary(reports)); got != "[fix:true:[]]" {
+		t.Errorf("Report of the future returned %s", got)
+	}
+}
+
+// reportSummary describes reports as "<branch>:<in progress>:[<subject>
+// <files> +<insertions> -<deletions>...]"
+func reportSummary(reports []gitmanager.BranchReport) []string {
+	var out []string
+	for _, r := range reports {
+		var commits []string
+		for _, c := range r.Commits {
+			commits = append(commits, fmt.Sprintf("%s %d +%d -%d", c.Subject, c.Files, c.Insertions, c.Deletions))
+		}
+		out = append(out, fmt.Sprintf("%s:%v:[%s]", r.Branch, r.InProgress, strings.Join(commits, ", ")))
+	}
+	return out
+}
@@ -0,0 +1,403 @@
+package gitmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/ingshtrom/git-manager/internal/config"
+	"github.com/ingshtrom/git-manager/internal/plan"
+	"github.com/ingshtrom/git-manager/internal/worktree"
+)
+
+// Repository is a registered workspace
+type Repository struct {
+	Name string
+	Path string
+}
+
+// InitOptions configures InitRepository
+type InitOptions struct {
+	// URL is the repository to clone
+	URL string
+
+	// Dir is the directory the workspace is created in
+	Dir string
+
+	// Name names the workspace directory and its registry entry. Empty
+	// derives it from URL.
+	Name string
+
+	// Path is the workspace directory. Empty uses <Dir>/<Name>.
+	Path string
+
+	// Remote names the remote cloned from. Empty uses origin.
+	Remote string
+
+	// DryRun resolves and validates everything but changes nothing
+	DryRun bool
+}
+
+// InitResult describes a workspace created by InitRepository
+type InitResult struct {
+	Workspace Workspace
+
+	// MainWorktree is the path of the initial worktree
+	MainWorktree string
+
+	// Plan lists the planned steps, in the form printed for a dry run
+	Plan []string
+}
+
+// InitRepository clones URL as a bare repository into <Dir>/<Name>/.git,
+// checks out its default branch in <Dir>/<Name>/main and registers the
+// workspace under Name. Path moves the workspace elsewhere.
+func (m *Manager) InitRepository(ctx context.Context, opts InitOptions) (*InitResult, error) {
+	repoName := opts.Name
+	if repoName == "" {
+		repoName = RepositoryName(opts.URL)
+	}
+
+	repoDir := opts.Path
+	if repoDir == "" {
+		repoDir = filepath.Join(opts.Dir, repoName)
+	}
+	cloneDir := opts.Dir
+	if cloneDir == "" {
+		cloneDir = filepath.Dir(repoDir)
+	}
+	gitDir := filepath.Join(repoDir, ".git")
+	mainDir := filepath.Join(repoDir, "main")
+
+	// Refuse to clone over an existing workspace
+	if _, err := os.Stat(gitDir); err == nil {
+		return nil, newError(Conflict, "%s is already a git repository", repoDir)
+	}
+
+	// Refuse to reuse a registered name before doing any work
+	cfg, err := m.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := cfg.Repository(repoName); err == nil && existing.Path != repoDir {
+		return nil, newError(Conflict, "a repository named %q is already registered at %s, use another name", repoName, existing.Path)
+	}
+
+	var p plan.Plan
+
+	// Create directories
+	p.Add(plan.Mkdir(mainDir))
+
+	// Clone the repository
+	cloneArgs := []string{"clone", "--bare"}
+	if opts.Remote != "" {
+		cloneArgs = append(cloneArgs, "--origin", opts.Remote)
+	}
+	clone := m.gitStep(ctx, cloneDir, "error cloning repository", append(cloneArgs, opts.URL, gitDir)...)
+	clone.Progress = fmt.Sprintf("Cloning repository %s...", opts.URL)
+	clone.Undo = func() error {
+		return os.RemoveAll(gitDir)
+	}
+	p.Add(clone)
+
+	// Create initial worktree
+	addMain := m.gitStep(ctx, cloneDir, "error creating worktree", "-C", gitDir, "worktree", "add", mainDir)
+	addMain.Progress = "Creating initial worktree..."
+	p.Add(addMain)
+
+	// Register the workspace so it can be found by name
+	p.Add(m.registerStep(repoName, repoDir))
+
+	steps, err := m.run(&p, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InitResult{
+		Workspace:    Workspace{Name: repoName, Root: repoDir, GitDir: gitDir},
+		MainWorktree: mainDir,
+		Plan:         steps,
+	}, nil
+}
+
+// RepositoryName derives a workspace name from a repository URL or path
+func RepositoryName(url string) string {
+	name := filepath.Base(strings.TrimRight(url, "/"))
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, ".git")
+}
+
+// Register adds the workspace containing dir to the registry. An empty name
+// uses the workspace directory name.
+func (m *Manager) Register(dir string, name string) (*Repository, error) {
+	// Find the workspace that contains dir
+	gitDir, err := worktree.FindGitDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	repoDir := filepath.Dir(gitDir)
+
+	if name == "" {
+		name = filepath.Base(repoDir)
+	}
+
+	if err := m.register(name, repoDir); err != nil {
+		return nil, err
+	}
+	return &Repository{Name: name, Path: repoDir}, nil
+}
+
+// Unregister removes the repository called name from the registry. The
+// workspace itself is left untouched.
+func (m *Manager) Unregister(name string) error {
+	cfg, err := m.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := cfg.RemoveRepository(name); err != nil {
+		return err
+	}
+	return m.saveConfig(cfg)
+}
+
+// Repositories returns the registered repositories, sorted by name
+func (m *Manager) Repositories() ([]Repository, error) {
+	cfg, err := m.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]Repository, len(cfg.Repositories))
+	for i, repo := range cfg.Repositories {
+		repos[i] = Repository{Name: repo.Name, Path: repo.Path}
+	}
+	return repos, nil
+}
+
+// Repository returns the repository registered as name. It returns a
+// NotFound error when there is none.
+func (m *Manager) Repository(name string) (*Repository, error) {
+	cfg, err := m.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	repo, err := cfg.Repository(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Repository{Name: repo.Name, Path: repo.Path}, nil
+}
+
+// registerStep returns a plan step that registers the workspace at repoDir.
+// Undoing it only removes a registration the step added.
+func (m *Manager) registerStep(name string, repoDir string) plan.Step {
+	added := false
+	return plan.Step{
+		Description: registerStepDescription(name, repoDir),
+		Run: func() error {
+			if _, err := m.Repository(name); err == nil {
+				return m.register(name, repoDir)
+			}
+			if err := m.register(name, repoDir); err != nil {
+				return err
+			}
+			added = true
+			return nil
+		},
+		Undo: func() error {
+			if !added {
+				return nil
+			}
+			return m.Unregister(name)
+		},
+	}
+}
+
+// registerStepDescription describes registering the workspace at repoDir
+// under name
+func registerStepDescription(name string, repoDir string) string {
+	return fmt.Sprintf("register repository '%s' at %s", name, repoDir)
+}
+
+// register adds the workspace at repoDir to the registry under name
+func (m *Manager) register(name string, repoDir string) error {
+	cfg, err := m.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := cfg.AddRepository(config.Repository{Name: name, Path: repoDir}); err != nil {
+		return err
+	}
+	return m.saveConfig(cfg)
+}
+
+// MoveOptions configures MoveRepository
+type MoveOptions struct {
+	// Dest is the new workspace directory. It must not exist yet.
+	Dest string
+
+	// RelativePaths switches the workspace to relative worktree paths, so
+	// later moves need no repair. It needs git 2.48 or newer.
+	RelativePaths bool
+
+	// DryRun resolves and validates everything but changes nothing
+	DryRun bool
+}
+
+// MoveResult describes a workspace moved by MoveRepository
+type MoveResult struct {
+	Workspace Workspace
+
+	// Moved maps the old path of every worktree that moved to its new path
+	Moved map[string]string
+
+	// Plan lists the planned steps, in the form printed for a dry run
+	Plan []string
+}
+
+// MoveRepository moves the workspace ws to opts.Dest, repairs the links
+// between the repository and every worktree, including worktrees outside
+// the workspace, and updates the registry. A failure moves everything back.
+func (m *Manager) MoveRepository(ctx context.Context, ws *Workspace, opts MoveOptions) (*MoveResult, error) {
+	dest, err := filepath.Abs(opts.Dest)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving destination: %w", err)
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return nil, newError(Conflict, "%s already exists", dest)
+	}
+	if dest == ws.Root || strings.HasPrefix(dest, ws.Root+string(filepath.Separator)) {
+		return nil, newError(Usage, "cannot move %s into itself", ws.Root)
+	}
+	if opts.RelativePaths {
+		if err := m.requireGit(ctx, CapRelativeWorktrees, "--relative-paths"); err != nil {
+			return nil, err
+		}
+	}
+
+	worktrees, err := m.ListWorktrees(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	// Worktrees inside the workspace move with it, the others only need
+	// their links repaired
+	moved := map[string]string{}
+	var oldPaths, newPaths []string
+	for _, wt := range worktrees {
+		if wt.IsBare || wt.Prunable {
+			continue
+		}
+		newPath := wt.Path
+		if isWithin(wt.Path, ws.Root) {
+			rel, _ := filepath.Rel(ws.Root, wt.Path)
+			newPath = filepath.Join(dest, rel)
+			moved[wt.Path] = newPath
+		}
+		if newPath == dest {
+			// The main worktree of a normal clone needs no repair
+			continue
+		}
+		oldPaths = append(oldPaths, wt.Path)
+		newPaths = append(newPaths, newPath)
+	}
+
+	gitDir := filepath.Join(dest, strings.TrimPrefix(ws.GitDir, ws.Root+string(filepath.Separator)))
+
+	var p plan.Plan
+
+	if parent := filepath.Dir(dest); !dirExists(parent) {
+		p.Add(plan.Mkdir(parent))
+	}
+
+	p.Add(plan.Step{
+		Description: fmt.Sprintf("move %s to %s", ws.Root, dest),
+		Progress:    fmt.Sprintf("Moving %s to %s...", ws.Root, dest),
+		Run: func() error {
+			if err := os.Rename(ws.Root, dest); err != nil {
+				if errors.Is(err, syscall.EXDEV) {
+					return newError(Unsupported, "%s and %s are on different filesystems, which move does not support; move the directory yourself, then run `git-manager tool doctor --fix` in it to repair the worktrees and `git-manager repository register` to update the registry", ws.Root, dest)
+				}
+				return fmt.Errorf("error moving workspace: %w", err)
+			}
+			return nil
+		},
+		Undo: func() error {
+			if err := os.Rename(dest, ws.Root); err != nil {
+				return err
+			}
+			if len(oldPaths) == 0 {
+				return nil
+			}
+			return m.undoStep(ctx, ws.Root, append([]string{"-C", ws.GitDir, "worktree", "repair"}, oldPaths...)...)()
+		},
+	})
+
+	if opts.RelativePaths {
+		p.Add(m.gitStep(ctx, dest, "error enabling relative worktree paths", "-C", gitDir, "config", "worktree.useRelativePaths", "true"))
+	}
+	if len(newPaths) > 0 || opts.RelativePaths {
+		args := []string{"-C", gitDir, "worktree", "repair"}
+		if opts.RelativePaths {
+			args = append(args, "--relative-paths")
+		}
+		repair := m.gitStep(ctx, dest, "error repairing worktrees", append(args, newPaths...)...)
+		repair.Progress = "Repairing worktree links..."
+		p.Add(repair)
+	}
+
+	if ws.Name != "" {
+		p.Add(m.moveRegistrationStep(ws.Name, ws.Root, dest))
+	}
+
+	steps, err := m.run(&p, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MoveResult{
+		Workspace: Workspace{Name: ws.Name, Root: dest, GitDir: gitDir},
+		Moved:     moved,
+		Plan:      steps,
+	}, nil
+}
+
+// dirExists reports whether dir is an existing directory
+func dirExists(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
+
+// moveRegistrationStep returns a plan step that points the registry entry
+// name from oldDir to newDir
+func (m *Manager) moveRegistrationStep(name string, oldDir string, newDir string) plan.Step {
+	repoint := func(dir string) error {
+		cfg, err := m.loadConfig()
+		if err != nil {
+			return err
+		}
+		if err := cfg.RemoveRepository(name); err != nil {
+			return err
+		}
+		if err := cfg.AddRepository(config.Repository{Name: name, Path: dir}); err != nil {
+			return err
+		}
+		return m.saveConfig(cfg)
+	}
+
+	return plan.Step{
+		Description: registerStepDescription(name, newDir),
+		Run: func() error {
+			return repoint(newDir)
+		},
+		Undo: func() error {
+			return repoint(oldDir)
+		},
+	}
+}
@@ -0,0 +1,87 @@
+package gitmanager
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+)
+
+// WorktreeStatus is the state of a single worktree
+type WorktreeStatus struct {
+	Worktree
+
+	// Missing is set when the worktree directory no longer exists. The
+	// other fields are then left empty.
+	Missing bool
+
+	// Changes counts changed, staged, unmerged and untracked paths
+	Changes int
+
+	// Upstream is the branch's upstream, such as origin/main, or ""
+	Upstream string
+
+	// Ahead and Behind count the commits the branch is ahead of and behind
+	// its upstream
+	Ahead  int
+	Behind int
+}
+
+// Dirty reports whether the worktree has uncommitted changes
+func (s WorktreeStatus) Dirty() bool {
+	return s.Changes > 0
+}
+
+// Status returns the state of every worktree of ws. The bare repository
+// entry is left out.
+func (m *Manager) Status(ctx context.Context, ws *Workspace) ([]WorktreeStatus, error) {
+	worktrees, err := m.ListWorktrees(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []WorktreeStatus
+	for _, wt := range worktrees {
+		if wt.IsBare {
+			continue
+		}
+
+		status := WorktreeStatus{Worktree: wt}
+		if _, err := os.Stat(wt.Path); os.IsNotExist(err) {
+			status.Missing = true
+			statuses = append(statuses, status)
+			continue
+		}
+
+		out, err := git.Output(ctx, m.git, wt.Path, "status", "--porcelain=v2", "--branch")
+		if err != nil {
+			return nil, wrapGit(err, "error reading worktree status")
+		}
+		parseStatus(out, &status)
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// parseStatus fills status from `git status --porcelain=v2 --branch` output
+func parseStatus(out string, status *WorktreeStatus) {
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "# branch.upstream "):
+			status.Upstream = strings.TrimPrefix(line, "# branch.upstream ")
+		case strings.HasPrefix(line, "# branch.ab "):
+			fields := strings.Fields(strings.TrimPrefix(line, "# branch.ab "))
+			if len(fields) == 2 {
+				status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[0], "+"))
+				status.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[1], "-"))
+			}
+		case strings.HasPrefix(line, "#"):
+			// Other branch headers repeat what the worktree list says
+		default:
+			status.Changes++
+		}
+	}
+}
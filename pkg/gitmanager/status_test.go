@@ -0,0 +1,98 @@
+package gitmanager_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// TestStatus tests reporting dirty and missing worktrees
+func TestStatus(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	feature := testWS.AddWorktree(t, "feature")
+	feature.CreateFile(t, "wip.txt", "not committed\n")
+	gone := testWS.AddWorktree(t, "gone")
+	if err := os.RemoveAll(gone.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	ws, err := m.Resolve(ctx, gitmanager.Target{Dir: testWS.Root})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	statuses, err := m.Status(ctx, ws)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+
+	got := map[string]gitmanager.WorktreeStatus{}
+	for _, st := range statuses {
+		got[st.Branch] = st
+	}
+	if len(statuses) != 3 {
+		t.Fatalf("Expected three worktrees without the bare entry, got %+v", statuses)
+	}
+	if got["main"].Dirty() || got["main"].Missing {
+		t.Errorf("Expected main to be clean, got %+v", got["main"])
+	}
+	if !got["feature"].Dirty() || got["feature"].Changes != 1 {
+		t.Errorf("Expected feature to have one change, got %+v", got["feature"])
+	}
+	if !got["gone"].Missing {
+		t.Errorf("Expected gone to be missing, got %+v", got["gone"])
+	}
+}
+
+// TestStatusUpstream tests parsing the upstream and ahead/behind counts
+func TestStatusUpstream(t *testing.T) {
+	dir := t.TempDir()
+	r := testutil.NewFakeRunner(t)
+	r.On("--version").Return("git version 2.30.0\n")
+	r.On("worktree", "list").Return("worktree /ws/.git\nbare\n\n" +
+		"worktree " + dir + "\nHEAD 0123456789012345678901234567890123456789\nbranch refs/heads/main\n\n")
+	r.On("status", "--porcelain=v2").Return("# branch.oid 0123456789012345678901234567890123456789\n" +
+		"# branch.head main\n# branch.upstream origin/main\n# branch.ab +2 -3\n" +
+		"1 .M N... 100644 100644 100644 0123 0123 README.md\n? new.txt\n")
+
+	m := gitmanager.New(gitmanager.Options{Git: r, ConfigPath: "unused"})
+	statuses, err := m.Status(context.Background(), &gitmanager.Workspace{Root: "/ws", GitDir: "/ws/.git"})
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+
+	want := gitmanager.WorktreeStatus{
+		Worktree: gitmanager.Worktree{Path: dir, Branch: "main", Commit: "0123456789012345678901234567890123456789"},
+		Changes:  2,
+		Upstream: "origin/main",
+		Ahead:    2,
+		Behind:   3,
+	}
+	if len(statuses) != 1 || statuses[0] != want {
+		t.Errorf("Status = %+v, want %+v", statuses, want)
+	}
+}
+
+// TestStatusGitFailure tests that git failures keep their kind and stderr
+func TestStatusGitFailure(t *testing.T) {
+	r := testutil.NewFakeRunner(t)
+	r.On("--version").Return("git version 2.30.0\n")
+	r.On("worktree", "list").Fail(128, "fatal: not a git repository\n")
+
+	m := gitmanager.New(gitmanager.Options{Git: r, ConfigPath: "unused"})
+	_, err := m.Status(context.Background(), &gitmanager.Workspace{Root: "/ws", GitDir: "/ws/.git"})
+	if gitmanager.KindOf(err) != gitmanager.GitFailure {
+		t.Fatalf("Expected a git failure, got %v", err)
+	}
+	var gitErr *gitmanager.GitError
+	if !errors.As(err, &gitErr) || gitErr.ExitCode != 128 {
+		t.Errorf("Expected the git error to be wrapped, got %v", err)
+	}
+}
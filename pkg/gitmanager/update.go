@@ -0,0 +1,255 @@
+package gitmanager
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+)
+
+// UpdateState is what Update did to a worktree
+type UpdateState string
+
+// The outcomes of updating a worktree
+const (
+	UpdateUpdated  UpdateState = "updated"
+	UpdateUpToDate UpdateState = "up-to-date"
+	UpdateDirty    UpdateState = "skipped (dirty)"
+	UpdateSkipped  UpdateState = "skipped"
+	UpdateConflict UpdateState = "conflict"
+	UpdateFailed   UpdateState = "failed"
+)
+
+// UpdateOptions configures Update
+type UpdateOptions struct {
+	// Rebase rebases the worktrees of other branches onto the default branch,
+	// after the default branch was fast-forwarded to its upstream
+	Rebase bool
+
+	// Autostash stashes the changes of dirty worktrees before updating them
+	// and applies them again afterwards, instead of skipping them
+	Autostash bool
+}
+
+// UpdateResult is the outcome of updating a worktree
+type UpdateResult struct {
+	Worktree Worktree
+
+	State UpdateState
+
+	// Onto is what the worktree was fast-forwarded or rebased onto, such as
+	// origin/main
+	Onto string
+
+	// Commits counts the commits the worktree was behind Onto
+	Commits int
+
+	// Reason says why a worktree was skipped
+	Reason string
+
+	// Conflicts lists the files that conflicted, for UpdateConflict
+	Conflicts []string
+
+	// Err is set for UpdateFailed
+	Err error
+}
+
+// Update brings every worktree of ws up to date: it fast-forwards each
+// worktree to the upstream of its branch, or to origin/<branch> when no
+// upstream is configured, as in bare clones. With Rebase, the worktrees of
+// other branches are rebased onto the default branch instead. Dirty
+// worktrees are skipped unless Autostash is set. A rebase that conflicts is
+// aborted, leaving the worktree as it was.
+func (m *Manager) Update(ctx context.Context, ws *Workspace, opts UpdateOptions) ([]UpdateResult, error) {
+	statuses, err := m.Status(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	base := ""
+	if opts.Rebase {
+		if base, err = m.defaultBranch(ctx, ws); err != nil {
+			return nil, err
+		}
+		if base == "" {
+			return nil, newError(NotFound, "HEAD of %s is detached, so there is no default branch to rebase onto", ws.GitDir)
+		}
+
+		// Refresh the default branch before anything is rebased onto it
+		sort.SliceStable(statuses, func(i, j int) bool {
+			return statuses[i].Branch == base && statuses[j].Branch != base
+		})
+		if len(statuses) == 0 || statuses[0].Branch != base {
+			if err := m.fastForwardBranch(ctx, ws, base); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var results []UpdateResult
+	for _, st := range statuses {
+		res := m.updateWorktree(ctx, ws, st, base, opts)
+		if err := ctx.Err(); err != nil {
+			return results, newError(Interrupted, "update was interrupted")
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// updateWorktree updates the worktree st, rebasing it onto base when base is
+// set and it is not the worktree of base
+func (m *Manager) updateWorktree(ctx context.Context, ws *Workspace, st WorktreeStatus, base string, opts UpdateOptions) UpdateResult {
+	res := UpdateResult{Worktree: st.Worktree}
+	skip := func(reason string) UpdateResult {
+		res.State = UpdateSkipped
+		res.Reason = reason
+		return res
+	}
+	fail := func(err error) UpdateResult {
+		res.State = UpdateFailed
+		res.Err = err
+		return res
+	}
+
+	switch {
+	case st.Missing:
+		return skip("directory is missing")
+	case st.Branch == "":
+		return skip("HEAD is detached")
+	}
+
+	rebase := base != "" && st.Branch != base
+	if rebase {
+		res.Onto = base
+	} else {
+		upstream, err := m.upstream(ctx, ws, st.Branch)
+		if err != nil {
+			return fail(err)
+		}
+		if upstream == "" {
+			return skip("no upstream")
+		}
+		res.Onto = upstream
+	}
+
+	ahead, behind, err := m.aheadBehind(ctx, st.Path, "HEAD", res.Onto)
+	if err != nil {
+		return fail(err)
+	}
+	res.Commits = behind
+	switch {
+	case behind == 0:
+		res.State = UpdateUpToDate
+		return res
+	case ahead > 0 && !rebase:
+		return skip(fmt.Sprintf("diverged from %s", res.Onto))
+	case st.Dirty() && !opts.Autostash:
+		res.State = UpdateDirty
+		return res
+	}
+
+	if !rebase {
+		args := []string{"-C", st.Path, "merge", "--ff-only"}
+		if opts.Autostash {
+			args = append(args, "--autostash")
+		}
+		if _, err := git.Output(ctx, m.git, st.Path, append(args, res.Onto)...); err != nil {
+			return fail(wrapGit(err, fmt.Sprintf("error fast-forwarding to %s", res.Onto)))
+		}
+		res.State = UpdateUpdated
+		return res
+	}
+
+	args := []string{"-C", st.Path, "rebase"}
+	if opts.Autostash {
+		args = append(args, "--autostash")
+	}
+	if _, err := git.Output(ctx, m.git, st.Path, append(args, base)...); err != nil {
+		// Never leave a worktree half rebased, even when interrupted
+		bg := context.WithoutCancel(ctx)
+		conflicts, _ := git.Output(bg, m.git, st.Path, "-C", st.Path, "diff", "--name-only", "--diff-filter=U")
+		if m.rebaseInProgress(bg, st.Path) {
+			if abortErr := m.undoStep(ctx, st.Path, "-C", st.Path, "rebase", "--abort")(); abortErr != nil {
+				return fail(wrapGit(abortErr, "error aborting the rebase, finish it with `git rebase --continue` or `git rebase --abort`"))
+			}
+		}
+		if files := strings.Fields(conflicts); len(files) > 0 && ctx.Err() == nil {
+			res.State = UpdateConflict
+			res.Conflicts = files
+			return res
+		}
+		return fail(wrapGit(err, fmt.Sprintf("error rebasing onto %s", base)))
+	}
+	res.State = UpdateUpdated
+	return res
+}
+
+// rebaseInProgress reports whether the worktree at dir is in the middle of
+// a rebase
+func (m *Manager) rebaseInProgress(ctx context.Context, dir string) bool {
+	out, err := git.Output(ctx, m.git, dir, "-C", dir, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return false
+	}
+	gitDir := strings.TrimSpace(out)
+	return dirExists(filepath.Join(gitDir, "rebase-merge")) || dirExists(filepath.Join(gitDir, "rebase-apply"))
+}
+
+// upstream returns the upstream of branch, such as origin/main. Without a
+// configured upstream it falls back to origin/<branch> if that exists. It
+// returns "" when there is neither.
+func (m *Manager) upstream(ctx context.Context, ws *Workspace, branch string) (string, error) {
+	out, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "rev-parse", "--abbrev-ref", "--symbolic-full-name", branch+"@{upstream}")
+	if err == nil {
+		return strings.TrimSpace(out), nil
+	}
+	if ctx.Err() != nil {
+		return "", wrapGit(err, "error looking up upstream")
+	}
+
+	fallback := "origin/" + branch
+	if _, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "rev-parse", "--verify", "--quiet", "refs/remotes/"+fallback); err != nil {
+		if ctx.Err() != nil {
+			return "", wrapGit(err, "error looking up upstream")
+		}
+		return "", nil
+	}
+	return fallback, nil
+}
+
+// aheadBehind counts the commits from is ahead of and behind to
+func (m *Manager) aheadBehind(ctx context.Context, dir string, from string, to string) (int, int, error) {
+	out, err := git.Output(ctx, m.git, dir, "-C", dir, "rev-list", "--left-right", "--count", from+"..."+to)
+	if err != nil {
+		return 0, 0, wrapGit(err, fmt.Sprintf("error comparing with %s", to))
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return 0, 0, newError(GitFailure, "unexpected output from git rev-list: %q", out)
+	}
+	ahead, _ := strconv.Atoi(fields[0])
+	behind, _ := strconv.Atoi(fields[1])
+	return ahead, behind, nil
+}
+
+// fastForwardBranch moves branch, which no worktree has checked out, to its
+// upstream when that is a fast-forward
+func (m *Manager) fastForwardBranch(ctx context.Context, ws *Workspace, branch string) error {
+	upstream, err := m.upstream(ctx, ws, branch)
+	if err != nil || upstream == "" {
+		return err
+	}
+	ahead, behind, err := m.aheadBehind(ctx, ws.GitDir, "refs/heads/"+branch, upstream)
+	if err != nil || ahead > 0 || behind == 0 {
+		return err
+	}
+	if _, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "update-ref", "refs/heads/"+branch, upstream); err != nil {
+		return wrapGit(err, fmt.Sprintf("error fast-forwarding %s to %s", branch, upstream))
+	}
+	return nil
+}
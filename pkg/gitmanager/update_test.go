@@ -0,0 +1,131 @@
+package gitmanager_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// setupUpdate returns a workspace whose origin moved on after it was cloned,
+// with worktrees for a feature branch, a branch that conflicts with origin
+// and a branch with uncommitted changes
+func setupUpdate(t *testing.T) (*testutil.Workspace, *gitmanager.Workspace, func()) {
+	t.Helper()
+	testWS, cleanup := testutil.SetupWorkspace(t)
+
+	feature := testWS.AddWorktree(t, "feature")
+	feature.CreateFile(t, "feature.txt", "feature\n")
+	feature.AddAndCommit(t, "Add feature", "feature.txt")
+
+	clash := testWS.AddWorktree(t, "clash")
+	clash.CreateFile(t, "README.md", "# Clash\n")
+	clash.AddAndCommit(t, "Change README", "README.md")
+
+	dirty := testWS.AddWorktree(t, "dirty")
+	dirty.CreateFile(t, "notes.txt", "notes\n")
+	dirty.AddAndCommit(t, "Add notes", "notes.txt")
+	dirty.CreateFile(t, "notes.txt", "wip\n")
+
+	testWS.Origin.CreateFile(t, "README.md", "# Upstream\n")
+	testWS.Origin.AddAndCommit(t, "Update README", "README.md")
+	(&testutil.GitRepo{Path: testWS.GitDir}).RunGit(t, "fetch", "origin", "+refs/heads/*:refs/remotes/origin/*")
+
+	return testWS, &gitmanager.Workspace{Root: testWS.Root, GitDir: testWS.GitDir}, cleanup
+}
+
+// updateStates maps each worktree directory name to what Update did to it
+func updateStates(results []gitmanager.UpdateResult) map[string]gitmanager.UpdateState {
+	states := map[string]gitmanager.UpdateState{}
+	for _, res := range results {
+		states[filepath.Base(res.Worktree.Path)] = res.State
+	}
+	return states
+}
+
+// TestUpdateFastForward tests that clean worktrees are fast-forwarded to
+// origin and the others left alone
+func TestUpdateFastForward(t *testing.T) {
+	testWS, ws, cleanup := setupUpdate(t)
+	defer cleanup()
+	m := newManager(t)
+
+	results, err := m.Update(context.Background(), ws, gitmanager.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	states := updateStates(results)
+	if states["main"] != gitmanager.UpdateUpdated {
+		t.Errorf("Expected main to be fast-forwarded, got %+v", results)
+	}
+	for _, name := range []string{"feature", "clash", "dirty"} {
+		if states[name] != gitmanager.UpdateSkipped {
+			t.Errorf("Expected %s without an upstream to be skipped, got %s", name, states[name])
+		}
+	}
+	testWS.Worktree("main").AssertFileContent(t, "README.md", "# Upstream\n")
+
+	results, err = m.Update(context.Background(), ws, gitmanager.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if states := updateStates(results); states["main"] != gitmanager.UpdateUpToDate {
+		t.Errorf("Expected main to be up to date, got %+v", results)
+	}
+}
+
+// TestUpdateRebase tests rebasing onto the refreshed default branch, with a
+// conflicting branch restored to where it was
+func TestUpdateRebase(t *testing.T) {
+	testWS, ws, cleanup := setupUpdate(t)
+	defer cleanup()
+	m := newManager(t)
+
+	clash := testWS.Worktree("clash")
+	before := strings.TrimSpace(clash.RunGit(t, "rev-parse", "HEAD"))
+
+	results, err := m.Update(context.Background(), ws, gitmanager.UpdateOptions{Rebase: true})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	states := updateStates(results)
+	want := map[string]gitmanager.UpdateState{
+		"main":    gitmanager.UpdateUpdated,
+		"feature": gitmanager.UpdateUpdated,
+		"clash":   gitmanager.UpdateConflict,
+		"dirty":   gitmanager.UpdateDirty,
+	}
+	for name, state := range want {
+		if states[name] != state {
+			t.Errorf("Expected %s to be %s, got %s", name, state, states[name])
+		}
+	}
+	testWS.Worktree("feature").AssertFileContent(t, "README.md", "# Upstream\n")
+
+	for _, res := range results {
+		if res.State == gitmanager.UpdateConflict && strings.Join(res.Conflicts, ",") != "README.md" {
+			t.Errorf("Expected README.md to conflict, got %v", res.Conflicts)
+		}
+	}
+	if after := strings.TrimSpace(clash.RunGit(t, "rev-parse", "HEAD")); after != before {
+		t.Errorf("Expected the conflicting branch to stay at %s, got %s", before, after)
+	}
+	if status := clash.RunGit(t, "status", "--porcelain"); status != "" {
+		t.Errorf("Expected the conflicting worktree to be clean, got %q", status)
+	}
+	clash.AssertFileContent(t, "README.md", "# Clash\n")
+
+	// Autostash updates the dirty worktree and keeps its changes
+	results, err = m.Update(context.Background(), ws, gitmanager.UpdateOptions{Rebase: true, Autostash: true})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if states := updateStates(results); states["dirty"] != gitmanager.UpdateUpdated {
+		t.Errorf("Expected the dirty worktree to be updated with autostash, got %+v", results)
+	}
+	testWS.Worktree("dirty").AssertFileContent(t, "notes.txt", "wip\n")
+	testWS.Worktree("dirty").AssertFileContent(t, "README.md", "# Upstream\n")
+}
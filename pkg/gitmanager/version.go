@@ -0,0 +1,47 @@
+package gitmanager
+
+import (
+	"context"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+)
+
+// Version is a git release version
+type Version = git.Version
+
+// Capability is a git feature that git-manager uses when the installed git
+// has it
+type Capability = git.Capability
+
+// GitFeatures describes the installed git. Use Has to check for a capability
+// and Require to get an Unsupported error naming the version needed.
+type GitFeatures = git.Features
+
+// The capabilities git-manager knows about
+var (
+	CapWorktreeListZ      = git.PorcelainZ
+	CapMergeTreeWriteTree = git.MergeTreeWriteTree
+	CapWorktreeOrphan     = git.WorktreeOrphan
+	CapRelativeWorktrees  = git.RelativeWorktrees
+)
+
+// Capabilities lists every capability git-manager knows about, oldest first
+func Capabilities() []Capability {
+	return append([]Capability(nil), git.Capabilities...)
+}
+
+// GitFeatures returns the version and capabilities of the installed git. The
+// version is detected once per Manager.
+func (m *Manager) GitFeatures(ctx context.Context) (GitFeatures, error) {
+	return m.features.Features(ctx)
+}
+
+// requireGit returns an Unsupported error when git lacks capability c, which
+// what needs
+func (m *Manager) requireGit(ctx context.Context, c Capability, what string) error {
+	f, err := m.GitFeatures(ctx)
+	if err != nil {
+		return err
+	}
+	return f.Require(c, what)
+}
@@ -0,0 +1,301 @@
+package gitmanager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ingshtrom/git-manager/internal/git"
+	"github.com/ingshtrom/git-manager/internal/plan"
+	"github.com/ingshtrom/git-manager/internal/worktree"
+)
+
+// Worktree is a worktree of a workspace
+type Worktree struct {
+	// Path is the worktree's checkout directory
+	Path string
+
+	// Branch is the checked out branch without refs/heads/, or "" when
+	// HEAD is detached
+	Branch string
+
+	// Commit is the full hash of the checked out commit
+	Commit string
+
+	// IsBare is set for the entry git lists for the bare repository itself
+	IsBare bool
+
+	// Locked is set for worktrees locked with `git worktree lock`, with the
+	// optional reason in LockReason
+	Locked     bool
+	LockReason string
+
+	// Prunable is set when the worktree directory is gone and git would
+	// prune the entry
+	Prunable bool
+}
+
+// ListWorktrees returns the worktrees of ws in the order git lists them,
+// starting with the bare repository
+func (m *Manager) ListWorktrees(ctx context.Context, ws *Workspace) ([]Worktree, error) {
+	infos, err := m.worktreeInfo(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+
+	worktrees := make([]Worktree, len(infos))
+	for i, info := range infos {
+		worktrees[i] = fromInfo(info)
+	}
+	return worktrees, nil
+}
+
+// FindWorktree resolves name to a worktree of ws, by directory name first and
+// then by branch. It returns a NotFound or Ambiguous error when name does not
+// pick exactly one worktree.
+func (m *Manager) FindWorktree(ctx context.Context, ws *Workspace, name string) (*Worktree, error) {
+	infos, err := m.worktreeInfo(ctx, ws)
+	if err != nil {
+		return nil, err
+	}
+	info, err := worktree.Find(infos, name)
+	if err != nil {
+		return nil, err
+	}
+	wt := fromInfo(info)
+	return &wt, nil
+}
+
+// AddOptions configures AddWorktree
+type AddOptions struct {
+	// Branch is checked out in <Root>/<Branch>
+	Branch string
+
+	// Path is the worktree directory relative to the workspace. Empty uses
+	// Branch.
+	Path string
+
+	// CreateBranch creates Branch from Base instead of checking out an
+	// existing branch
+	CreateBranch bool
+
+	// Base is the starting point of a new branch
+	Base string
+
+	// Orphan creates Branch without any history, for unrelated content such
+	// as documentation sites. Base is ignored. It needs git 2.42 or newer.
+	Orphan bool
+
+	// Carry, when set, carries uncommitted changes into the new worktree,
+	// see Carry. Its Target is ignored. If they do not apply cleanly, the
+	// worktree is not created.
+	Carry *CarryOptions
+
+	// DryRun resolves and validates everything but changes nothing
+	DryRun bool
+}
+
+// AddResult describes a worktree created by AddWorktree
+type AddResult struct {
+	// Path is the new worktree's directory
+	Path string
+
+	// Branch is the branch checked out in it
+	Branch string
+
+	// Carried are the paths changed by AddOptions.Carry
+	Carried []string
+
+	// Plan lists the planned steps, in the form printed for a dry run
+	Plan []string
+}
+
+// AddWorktree checks out a branch in a new worktree of ws. It returns a
+// Conflict error when the worktree directory already exists.
+func (m *Manager) AddWorktree(ctx context.Context, ws *Workspace, opts AddOptions) (*AddResult, error) {
+	worktreePath := filepath.Join(ws.Root, opts.Branch)
+	if opts.Path != "" {
+		worktreePath = filepath.Join(ws.Root, opts.Path)
+	}
+
+	// Check if the directory already exists
+	if _, err := os.Stat(worktreePath); err == nil {
+		return nil, newError(Conflict, "directory %s already exists", worktreePath)
+	}
+
+	if opts.Orphan {
+		if err := m.requireGit(ctx, CapWorktreeOrphan, "adding a worktree with an orphan branch"); err != nil {
+			return nil, err
+		}
+	}
+
+	// Refuse to reset an existing branch to the base
+	branchExists, err := m.branchExists(ctx, ws, opts.Branch)
+	if err != nil {
+		return nil, err
+	}
+	if (opts.CreateBranch || opts.Orphan) && branchExists {
+		return nil, newError(Conflict, "branch '%s' already exists, check it out with --create-branch=false", opts.Branch)
+	}
+
+	var step plan.Step
+
+	switch {
+	case opts.Orphan:
+		// Create a worktree on a new branch without history
+		step = m.gitStep(ctx, ws.Root, "error creating worktree", "-C", ws.GitDir, "worktree", "add", "--orphan", "-b", opts.Branch, worktreePath)
+		step.Progress = fmt.Sprintf("Creating new orphan branch '%s' and adding worktree...", opts.Branch)
+	case opts.CreateBranch:
+		// Create a new branch and worktree
+		step = m.gitStep(ctx, ws.Root, "error creating worktree", "-C", ws.GitDir, "worktree", "add", "-b", opts.Branch, worktreePath, opts.Base)
+		step.Progress = fmt.Sprintf("Creating new branch '%s' based on '%s' and adding worktree...", opts.Branch, opts.Base)
+	default:
+		// Add worktree for existing branch
+		step = m.gitStep(ctx, ws.Root, "error creating worktree", "-C", ws.GitDir, "worktree", "add", worktreePath, opts.Branch)
+		step.Progress = fmt.Sprintf("Adding worktree for branch '%s'...", opts.Branch)
+	}
+
+	newBranch := opts.CreateBranch || opts.Orphan
+
+	// An interrupted add can leave the directory, the worktree entry and the
+	// new branch behind
+	prune := m.undoStep(ctx, ws.Root, "-C", ws.GitDir, "worktree", "prune")
+	deleteBranch := m.undoStep(ctx, ws.Root, "-C", ws.GitDir, "branch", "-D", opts.Branch)
+	step.Undo = func() error {
+		if err := os.RemoveAll(worktreePath); err != nil {
+			return err
+		}
+		if err := prune(); err != nil {
+			return err
+		}
+		if exists, _ := m.branchExists(context.WithoutCancel(ctx), ws, opts.Branch); exists && newBranch && !branchExists {
+			return deleteBranch()
+		}
+		return nil
+	}
+
+	res := &AddResult{Path: worktreePath, Branch: opts.Branch}
+	p := &plan.Plan{Steps: []plan.Step{step}}
+	if opts.Carry != nil {
+		carry, err := m.carrySteps(ctx, ws, worktreePath, *opts.Carry, &res.Carried)
+		if err != nil {
+			return nil, err
+		}
+		p.Add(carry...)
+	}
+
+	if res.Plan, err = m.run(p, opts.DryRun); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RemoveOptions configures RemoveWorktree
+type RemoveOptions struct {
+	// Name selects the worktree, see FindWorktree
+	Name string
+
+	// Force removes the worktree even if it has uncommitted changes
+	Force bool
+
+	// DeleteBranch also deletes the worktree's branch
+	DeleteBranch bool
+
+	// DryRun resolves and validates everything but changes nothing
+	DryRun bool
+}
+
+// RemoveResult describes a worktree removed by RemoveWorktree
+type RemoveResult struct {
+	// Worktree is the removed worktree
+	Worktree Worktree
+
+	// Plan lists the planned steps, in the form printed for a dry run
+	Plan []string
+}
+
+// RemoveWorktree removes a worktree of ws. It returns a Dirty error when the
+// worktree has uncommitted changes and Force is not set.
+func (m *Manager) RemoveWorktree(ctx context.Context, ws *Workspace, opts RemoveOptions) (*RemoveResult, error) {
+	wt, err := m.FindWorktree(ctx, ws, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse to throw away uncommitted work unless forced
+	if !opts.Force {
+		dirty, err := worktree.IsDirty(ctx, m.git, wt.Path)
+		if err != nil {
+			return nil, err
+		}
+		if dirty {
+			return nil, newError(Dirty, "worktree '%s' has uncommitted changes, use --force to remove it anyway", opts.Name)
+		}
+	}
+
+	if opts.DeleteBranch && wt.Branch == "" {
+		return nil, newError(NotFound, "worktree '%s' has no branch to delete", opts.Name)
+	}
+
+	var p plan.Plan
+
+	// Remove the worktree
+	args := []string{"-C", ws.GitDir, "worktree", "remove"}
+	if opts.Force {
+		args = append(args, "--force")
+	}
+	args = append(args, wt.Path)
+
+	remove := m.gitStep(ctx, ws.Root, "error removing worktree", args...)
+	remove.Progress = fmt.Sprintf("Removing worktree '%s'...", opts.Name)
+	p.Add(remove)
+
+	// Delete the branch if requested
+	if opts.DeleteBranch {
+		del := m.gitStep(ctx, ws.Root, "error deleting branch", "-C", ws.GitDir, "branch", "-D", wt.Branch)
+		del.Progress = fmt.Sprintf("Deleting branch '%s'...", wt.Branch)
+		p.Add(del)
+	}
+
+	steps, err := m.run(&p, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoveResult{Worktree: *wt, Plan: steps}, nil
+}
+
+// branchExists reports whether ws has a local branch called name
+func (m *Manager) branchExists(ctx context.Context, ws *Workspace, name string) (bool, error) {
+	_, err := git.Output(ctx, m.git, ws.Root, "-C", ws.GitDir, "rev-parse", "--verify", "--quiet", "refs/heads/"+name)
+	switch {
+	case err == nil:
+		return true, nil
+	case ctx.Err() != nil:
+		return false, wrapGit(err, "error looking up branch")
+	default:
+		return false, nil
+	}
+}
+
+// worktreeInfo lists the worktrees of ws through the configured backend
+func (m *Manager) worktreeInfo(ctx context.Context, ws *Workspace) ([]worktree.Info, error) {
+	b, err := m.backend()
+	if err != nil {
+		return nil, err
+	}
+	return b.GetWorktreeInfo(ctx, ws.GitDir)
+}
+
+// fromInfo converts the internal worktree representation
+func fromInfo(info worktree.Info) Worktree {
+	return Worktree{
+		Path:       info.Path,
+		Branch:     info.Branch,
+		Commit:     info.Commit,
+		IsBare:     info.IsBare,
+		Locked:     info.Locked,
+		LockReason: info.LockReason,
+		Prunable:   info.Prunable,
+	}
+}
@@ -0,0 +1,149 @@
+package gitmanager_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ingshtrom/git-manager/internal/testutil"
+	"github.com/ingshtrom/git-manager/pkg/gitmanager"
+)
+
+// TestWorktreeLifecycle tests adding, finding, listing and removing a worktree
+func TestWorktreeLifecycle(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	m := newManager(t)
+	ctx := context.Background()
+
+	ws, err := m.Resolve(ctx, gitmanager.Target{Dir: testWS.Root})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	add, err := m.AddWorktree(ctx, ws, gitmanager.AddOptions{Branch: "feature", CreateBranch: true, Base: "main"})
+	if err != nil {
+		t.Fatalf("AddWorktree failed: %v", err)
+	}
+	if want := filepath.Join(ws.Root, "feature"); add.Path != want {
+		t.Errorf("Path = %s, want %s", add.Path, want)
+	}
+
+	_, err = m.AddWorktree(ctx, ws, gitmanager.AddOptions{Branch: "feature"})
+	if gitmanager.KindOf(err) != gitmanager.Conflict {
+		t.Errorf("Expected a conflict adding an existing worktree, got %v", err)
+	}
+
+	worktrees, err := m.ListWorktrees(ctx, ws)
+	if err != nil {
+		t.Fatalf("ListWorktrees failed: %v", err)
+	}
+	if len(worktrees) != 3 || !worktrees[0].IsBare {
+		t.Errorf("Expected the bare repository and two worktrees, got %+v", worktrees)
+	}
+
+	wt, err := m.FindWorktree(ctx, ws, "feature")
+	if err != nil {
+		t.Fatalf("FindWorktree failed: %v", err)
+	}
+	if wt.Branch != "feature" || len(wt.Commit) != 40 {
+		t.Errorf("FindWorktree = %+v", wt)
+	}
+
+	// Uncommitted work is only thrown away when forced
+	testWS.Worktree("feature").CreateFile(t, "wip.txt", "not committed\n")
+	opts := gitmanager.RemoveOptions{Name: "feature", DeleteBranch: true}
+	if _, err := m.RemoveWorktree(ctx, ws, opts); gitmanager.KindOf(err) != gitmanager.Dirty {
+		t.Fatalf("Expected a dirty error, got %v", err)
+	}
+
+	opts.Force = true
+	opts.DryRun = true
+	res, err := m.RemoveWorktree(ctx, ws, opts)
+	if err != nil {
+		t.Fatalf("RemoveWorktree --dry-run failed: %v", err)
+	}
+	if len(res.Plan) != 2 {
+		t.Errorf("Expected remove and branch delete steps, got %q", res.Plan)
+	}
+	if _, err := os.Stat(add.Path); err != nil {
+		t.Fatalf("Expected a dry run to keep the worktree: %v", err)
+	}
+
+	opts.DryRun = false
+	if _, err := m.RemoveWorktree(ctx, ws, opts); err != nil {
+		t.Fatalf("RemoveWorktree failed: %v", err)
+	}
+	if _, err := os.Stat(add.Path); !os.IsNotExist(err) {
+		t.Errorf("Expected the worktree directory to be removed, got %v", err)
+	}
+	if _, err := m.FindWorktree(ctx, ws, "feature"); gitmanager.KindOf(err) != gitmanager.NotFound {
+		t.Errorf("Expected a not-found error after removal, got %v", err)
+	}
+}
+
+// TestNativeBackend tests that the native backend lists worktrees without
+// running git
+func TestNativeBackend(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	testWS.AddWorktree(t, "feature")
+
+	fake := testutil.NewFakeRunner(t)
+	m := gitmanager.New(gitmanager.Options{
+		Git:        fake,
+		ConfigPath: filepath.Join(t.TempDir(), "config.json"),
+		Backend:    gitmanager.BackendNative,
+	})
+	ctx := context.Background()
+
+	ws, err := m.Resolve(ctx, gitmanager.Target{Dir: testWS.Root})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	worktrees, err := m.ListWorktrees(ctx, ws)
+	if err != nil {
+		t.Fatalf("ListWorktrees failed: %v", err)
+	}
+	if len(worktrees) != 3 || worktrees[1].Branch != "feature" {
+		t.Errorf("Expected bare, feature and main worktrees, got %+v", worktrees)
+	}
+	if !m.IsRepository(ctx, testWS.Root) {
+		t.Error("Expected the workspace to be a repository")
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("Expected no git invocations, got %d", len(calls))
+	}
+}
+
+// TestAddOrphanRequiresGit tests that orphan worktrees are refused with a
+// clear error on a git that is too old
+func TestAddOrphanRequiresGit(t *testing.T) {
+	testWS, cleanup := testutil.SetupWorkspace(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	m := gitmanager.New(gitmanager.Options{
+		Git:        testutil.GitVersion(t, "2.41.0"),
+		ConfigPath: filepath.Join(t.TempDir(), "config.json"),
+	})
+	ws, err := m.Resolve(ctx, gitmanager.Target{Dir: testWS.Root})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	_, err = m.AddWorktree(ctx, ws, gitmanager.AddOptions{Branch: "docs", Orphan: true})
+	if gitmanager.KindOf(err) != gitmanager.Unsupported {
+		t.Fatalf("Expected an unsupported error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "requires git >= 2.42.0, but git 2.41.0 is installed") {
+		t.Errorf("Expected the error to name the versions, got %q", err.Error())
+	}
+
+	features, err := m.GitFeatures(ctx)
+	if err != nil || features.Version.String() != "2.41.0" {
+		t.Errorf("GitFeatures = %+v, %v, want git 2.41.0", features, err)
+	}
+}